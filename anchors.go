@@ -0,0 +1,100 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+)
+
+// leadingAnchors returns the begin anchor (syntax.OpBeginText or syntax.OpBeginLine) that each alternative of
+// regexp, an alternation, starts with, or 0 for alternatives that don't start with one. Returns nil if none do.
+func leadingAnchors(regexp *syntax.Regexp) []syntax.Op {
+	var anchors []syntax.Op
+	for i, sub := range regexp.Sub {
+		if anchor := leadingAnchor(sub); anchor != 0 {
+			if anchors == nil {
+				anchors = make([]syntax.Op, len(regexp.Sub))
+			}
+			anchors[i] = anchor
+		}
+	}
+	return anchors
+}
+
+// leadingAnchor returns the begin anchor that regexp always starts with, or 0 if it doesn't start with one.
+func leadingAnchor(regexp *syntax.Regexp) syntax.Op {
+	switch regexp.Op {
+	case syntax.OpBeginText, syntax.OpBeginLine:
+		return regexp.Op
+	case syntax.OpConcat, syntax.OpCapture:
+		if len(regexp.Sub) > 0 {
+			return leadingAnchor(regexp.Sub[0])
+		}
+	}
+	return 0
+}
+
+// satisfiesAnchor returns true if the output so far allows anchor, a begin anchor from leadingAnchor, to match.
+func (state *generatorState) satisfiesAnchor(anchor syntax.Op) bool {
+	switch anchor {
+	case syntax.OpBeginText:
+		return !state.written
+	case syntax.OpBeginLine:
+		return !state.written || state.lastRune == '\n'
+	}
+	return true
+}
+
+// chooseAnchoredAlternative chooses one of the alternatives of an alternation that starts with anchors, as returned
+// by leadingAnchors, whose anchor can match at the current position, weighted by cumulativeWeights if it isn't nil.
+// It returns false if every alternative can be chosen, so the usual choice can be made, or if none can.
+func (state *generatorState) chooseAnchoredAlternative(anchors []syntax.Op, cumulativeWeights []int) (int, bool) {
+	if anchors == nil {
+		return 0, false
+	}
+
+	weight := func(i int) int {
+		if cumulativeWeights == nil {
+			return 1
+		} else if i == 0 {
+			return cumulativeWeights[0]
+		}
+		return cumulativeWeights[i] - cumulativeWeights[i-1]
+	}
+
+	allowed, totalWeight := 0, 0
+	for i, anchor := range anchors {
+		if state.satisfiesAnchor(anchor) {
+			allowed++
+			totalWeight += weight(i)
+		}
+	}
+	if allowed == len(anchors) || totalWeight == 0 {
+		return 0, false
+	}
+
+	n := state.intn(totalWeight)
+	for i, anchor := range anchors {
+		if !state.satisfiesAnchor(anchor) {
+			continue
+		}
+		if n -= weight(i); n < 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
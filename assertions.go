@@ -0,0 +1,135 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"strings"
+)
+
+// lookaroundPrefixes are the openings of PCRE lookaround assertions, which the standard parser doesn't support.
+var lookaroundPrefixes = []string{"(?=", "(?!", "(?<=", "(?<!"}
+
+// atomicGroupPrefix opens a PCRE atomic group, which matches like a non-capturing group when generating.
+const atomicGroupPrefix = "(?>"
+
+// removeUnsupportedAssertions returns pattern without lookaround assertions, for
+// GeneratorArgs.IgnoreUnsupportedAssertions. Atomic groups are replaced with non-capturing groups.
+// Like replaceBackreferences, it leaves character classes and \Q...\E alone, and the whole pattern if flags
+// contains syntax.Literal.
+func removeUnsupportedAssertions(pattern string, flags syntax.Flags) (string, error) {
+	if flags&syntax.Literal != 0 || !strings.Contains(pattern, "(?") {
+		return pattern, nil
+	}
+
+	var result bytes.Buffer
+	inClass := false
+	// The nesting depth of parentheses inside the assertion being removed, or 0 if there isn't one.
+	removedDepth := 0
+	runes := []rune(pattern)
+
+	// write writes s to the result, unless it's part of an assertion being removed.
+	write := func(s string) {
+		if removedDepth == 0 {
+			result.WriteString(s)
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '[' && !inClass:
+			inClass = true
+			start := i
+			// A ']' at the start of a class (after an optional '^') is a literal.
+			if i+1 < len(runes) && runes[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(runes) && runes[i+1] == ']' {
+				i++
+			}
+			write(string(runes[start : i+1]))
+
+		case r == '[' && inClass && i+1 < len(runes) && runes[i+1] == ':':
+			// Copy named ASCII classes (e.g. [:alpha:]) so their ']' doesn't end the class.
+			end := indexRunePair(runes, i+2, ':', ']')
+			if end < 0 {
+				write(string(r))
+				break
+			}
+			write(string(runes[i : end+2]))
+			i = end + 1
+
+		case r == ']' && inClass:
+			inClass = false
+			write(string(r))
+
+		case r == '\\' && i+1 < len(runes):
+			if runes[i+1] == 'Q' {
+				// Copy quoted text verbatim up to and including \E.
+				end := indexRunePair(runes, i+2, '\\', 'E')
+				if end < 0 {
+					write(string(runes[i:]))
+					i = len(runes)
+					break
+				}
+				write(string(runes[i : end+2]))
+				i = end + 1
+				break
+			}
+			write(string(runes[i : i+2]))
+			i++
+
+		case r == '(' && !inClass:
+			rest := string(runes[i:])
+			if removedDepth > 0 {
+				removedDepth++
+			} else if prefix, ok := lookaroundPrefix(rest); ok {
+				removedDepth = 1
+				i += len([]rune(prefix)) - 1
+			} else if strings.HasPrefix(rest, atomicGroupPrefix) {
+				result.WriteString("(?:")
+				i += len(atomicGroupPrefix) - 1
+			} else {
+				result.WriteRune(r)
+			}
+
+		case r == ')' && !inClass && removedDepth > 0:
+			removedDepth--
+
+		default:
+			write(string(r))
+		}
+	}
+
+	if removedDepth > 0 {
+		return "", generatorError(nil, "missing closing ) in lookaround assertion in /%s/", pattern)
+	}
+	return result.String(), nil
+}
+
+// lookaroundPrefix returns the lookaround assertion pattern starts with, if any.
+func lookaroundPrefix(pattern string) (string, bool) {
+	for _, prefix := range lookaroundPrefixes {
+		if strings.HasPrefix(pattern, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRemoveUnsupportedAssertions(t *testing.T) {
+	t.Parallel()
+
+	Convey("removeUnsupportedAssertions", t, func() {
+		Convey("Removes lookarounds", func() {
+			for pattern, expected := range map[string]string{
+				`foo(?=bar)`:        `foo`,
+				`(?!x)foo`:          `foo`,
+				`(?<=a(b|c))d`:      `d`,
+				`(?<!\))e`:          `e`,
+				`a(?=(?!b)[)]c)d`:   `ad`,
+				`(a)(?=\Q)\E)(?:b)`: `(a)(?:b)`,
+				`(?>ab|a)c`:         `(?:ab|a)c`,
+				`[(?=]\(?=x\)`:      `[(?=]\(?=x\)`,
+				`\Q(?=x)\E`:         `\Q(?=x)\E`,
+				`(?i)foo(?P<n>bar)`: `(?i)foo(?P<n>bar)`,
+				`[[:alpha:](?=]x`:   `[[:alpha:](?=]x`,
+			} {
+				result, err := removeUnsupportedAssertions(pattern, 0)
+				So(err, ShouldBeNil)
+				So(result, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Ignores literal patterns", func() {
+			result, err := removeUnsupportedAssertions(`foo(?=bar)`, syntax.Literal)
+			So(err, ShouldBeNil)
+			So(result, ShouldEqual, `foo(?=bar)`)
+		})
+
+		Convey("Returns error for unclosed assertions", func() {
+			_, err := removeUnsupportedAssertions(`foo(?=bar`, 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestIgnoreUnsupportedAssertions(t *testing.T) {
+	t.Parallel()
+
+	Convey("IgnoreUnsupportedAssertions", t, func() {
+		Convey("Generates without lookaheads", func() {
+			generator, err := NewGenerator(`foo(?=bar)[a-z]{3}`, &GeneratorArgs{
+				RngSource:                   rand.NewSource(0),
+				Flags:                       syntax.Perl,
+				IgnoreUnsupportedAssertions: true,
+			})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				So(str, ShouldStartWith, "foo")
+				So(str, ShouldHaveLength, 6)
+			}
+		})
+
+		Convey("Supports backreferences", func() {
+			generator, err := NewGenerator(`(?<!x)(a|b)(?>c)\1`, &GeneratorArgs{
+				RngSource:                   rand.NewSource(0),
+				Flags:                       syntax.Perl,
+				IgnoreUnsupportedAssertions: true,
+			})
+			So(err, ShouldBeNil)
+			So(generator.Generate(), ShouldBeIn, "aca", "bcb")
+		})
+
+		Convey("Fails by default", func() {
+			_, err := NewGenerator(`foo(?=bar)`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldNotBeNil)
+			So(strings.Contains(err.Error(), "(?="), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+)
+
+/*
+The standard parser doesn't support backreferences, so before parsing, replaceBackreferences
+replaces each backreference (\1 to \9) with a placeholder: a capture group containing a single rune
+from a private use area. (A bare rune could be merged into a character class by the parser, e.g. in "\1|a".)
+After parsing, renumberCaptureGroups gives the real capture groups the numbers they would have had without
+the placeholder groups, and opCapture generates the last output of the referenced group for each placeholder.
+
+Only single-digit backreferences are supported: the parser treats escapes like \12 as octal.
+*/
+const (
+	// backreferencePlaceholderBase+n is the placeholder for backreference \n.
+	backreferencePlaceholderBase rune = 0x10FFF0
+	maxBackreference                  = 9
+)
+
+// replaceBackreferences returns pattern with all backreferences replaced with placeholders, and
+// whether pattern contained any backreferences.
+// Backslashes inside character classes and \Q...\E are not backreferences, and are left alone, as is
+// the whole pattern if flags contains syntax.Literal.
+func replaceBackreferences(pattern string, flags syntax.Flags) (string, bool, error) {
+	if flags&syntax.Literal != 0 || !strings.Contains(pattern, `\`) {
+		return pattern, false, nil
+	}
+
+	var result bytes.Buffer
+	found := false
+	containsPlaceholder := false
+	inClass := false
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if isBackreferencePlaceholder(r) {
+			containsPlaceholder = true
+		}
+
+		switch {
+		case r == '[' && !inClass:
+			inClass = true
+			result.WriteRune(r)
+			// A ']' at the start of a class (after an optional '^') is a literal.
+			if i+1 < len(runes) && runes[i+1] == '^' {
+				i++
+				result.WriteRune(runes[i])
+			}
+			if i+1 < len(runes) && runes[i+1] == ']' {
+				i++
+				result.WriteRune(runes[i])
+			}
+
+		case r == '[' && inClass && i+1 < len(runes) && runes[i+1] == ':':
+			// Copy named ASCII classes (e.g. [:alpha:]) so their ']' doesn't end the class.
+			end := indexRunePair(runes, i+2, ':', ']')
+			if end < 0 {
+				result.WriteRune(r)
+				break
+			}
+			result.WriteString(string(runes[i : end+2]))
+			i = end + 1
+
+		case r == ']' && inClass:
+			inClass = false
+			result.WriteRune(r)
+
+		case r == '\\' && i+1 < len(runes):
+			next := runes[i+1]
+			i++
+
+			if next == 'Q' {
+				// Copy quoted text verbatim up to and including \E.
+				end := indexRunePair(runes, i+1, '\\', 'E')
+				if end < 0 {
+					result.WriteString(string(runes[i-1:]))
+					i = len(runes)
+					break
+				}
+				result.WriteString(string(runes[i-1 : end+2]))
+				i = end + 1
+				break
+			}
+
+			if !inClass && isBackreference(next, runes[i+1:]) {
+				found = true
+				result.WriteRune('(')
+				result.WriteRune(backreferencePlaceholderBase + (next - '0'))
+				result.WriteRune(')')
+				break
+			}
+
+			result.WriteRune(r)
+			result.WriteRune(next)
+
+		default:
+			result.WriteRune(r)
+		}
+	}
+
+	if found && containsPlaceholder {
+		return "", false, generatorError(nil, "patterns containing backreferences may not contain runes %U-%U",
+			backreferencePlaceholderBase+1, backreferencePlaceholderBase+maxBackreference)
+	}
+	return result.String(), found, nil
+}
+
+// indexRunePair returns the index of the first occurrence of a followed by b in runes, starting at start,
+// or -1 if there isn't one.
+func indexRunePair(runes []rune, start int, a, b rune) int {
+	for i := start; i+1 < len(runes); i++ {
+		if runes[i] == a && runes[i+1] == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// isBackreference returns true if digit, followed by rest, is a backreference.
+// \1 to \7 followed by another octal digit are octal escapes.
+func isBackreference(digit rune, rest []rune) bool {
+	if digit < '1' || digit > '9' {
+		return false
+	}
+	if digit <= '7' && len(rest) > 0 && rest[0] >= '0' && rest[0] <= '7' {
+		return false
+	}
+	return true
+}
+
+func isBackreferencePlaceholder(r rune) bool {
+	return r > backreferencePlaceholderBase && r <= backreferencePlaceholderBase+maxBackreference
+}
+
+// backreferenceGroup returns the index of the capture group referenced by regexp if regexp is a
+// backreference placeholder.
+func backreferenceGroup(regexp *syntax.Regexp) (int, bool) {
+	if regexp.Op != syntax.OpCapture || len(regexp.Sub) != 1 {
+		return 0, false
+	}
+
+	sub := regexp.Sub[0]
+	if sub.Op != syntax.OpLiteral || len(sub.Rune) != 1 || !isBackreferencePlaceholder(sub.Rune[0]) {
+		return 0, false
+	}
+	return int(sub.Rune[0]-backreferencePlaceholderBase) - 1, true
+}
+
+// isBackreferencePlaceholderGroup returns true if regexp is a backreference placeholder in an expression parsed with args.
+func isBackreferencePlaceholderGroup(regexp *syntax.Regexp, args *GeneratorArgs) bool {
+	_, ok := backreferenceGroup(regexp)
+	return ok && args.hasBackreferences
+}
+
+// renumberCaptureGroups numbers the capture groups in regexp as if backreference placeholders weren't there.
+// Placeholders get Cap 0.
+func renumberCaptureGroups(regexp *syntax.Regexp) {
+	groups := make([]*syntax.Regexp, regexp.MaxCap()+1)
+	var collect func(r *syntax.Regexp)
+	collect = func(r *syntax.Regexp) {
+		if r.Op == syntax.OpCapture {
+			groups[r.Cap] = r
+		}
+		for _, sub := range r.Sub {
+			collect(sub)
+		}
+	}
+	collect(regexp)
+
+	n := 0
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		if _, ok := backreferenceGroup(group); ok {
+			group.Cap = 0
+			continue
+		}
+		n++
+		group.Cap = n
+	}
+}
+
+// createBackreferenceGenerator creates a generator that generates the last output of the capture group at index,
+// or nothing if that group hasn't generated anything yet.
+func createBackreferenceGenerator(regexp *syntax.Regexp, index int, args *GeneratorArgs) (*internalGenerator, error) {
+	if index >= args.numCaptureGroups {
+		return nil, generatorError(nil, "invalid backreference to group %d", index+1)
+	}
+
+	return &internalGenerator{fmt.Sprintf("\\%d", index+1), regexp, args, nil, func(state *generatorState) error {
+		_, err := state.WriteString(state.captureGroup(index))
+		return err
+	}}, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplaceBackreferences(t *testing.T) {
+	t.Parallel()
+
+	Convey("replaceBackreferences", t, func() {
+		placeholder := func(n rune) string {
+			return "(" + string(backreferencePlaceholderBase+n) + ")"
+		}
+
+		Convey("Ignores patterns without backreferences", func() {
+			for _, pattern := range []string{``, `abc`, `\d\w+`, `\\1`, `\0`, `\12`, `[\1]`, `[]\1]`, `[[:alpha:]\1]`, `\Q\1\E`} {
+				result, found, err := replaceBackreferences(pattern, 0)
+				So(err, ShouldBeNil)
+				So(found, ShouldBeFalse)
+				So(result, ShouldEqual, pattern)
+			}
+		})
+
+		Convey("Replaces backreferences", func() {
+			result, found, err := replaceBackreferences(`(a)(b)\2\1[\1]\9`, 0)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeTrue)
+			So(result, ShouldEqual, `(a)(b)`+placeholder(2)+placeholder(1)+`[\1]`+placeholder(9))
+		})
+
+		Convey("Ignores literal patterns", func() {
+			result, found, err := replaceBackreferences(`(a)\1`, syntax.Literal)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+			So(result, ShouldEqual, `(a)\1`)
+		})
+
+		Convey("Rejects patterns containing placeholders", func() {
+			_, _, err := replaceBackreferences(`(a)\1`+string(backreferencePlaceholderBase+1), 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenBackreferences(t *testing.T) {
+	t.Parallel()
+
+	Convey("Backreferences", t, func() {
+		newGenerator := func(pattern string) Generator {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{
+				Flags: syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates the referenced group", func() {
+			generator := newGenerator(`(foo|bar)-\1`)
+			for i := 0; i < SampleSize; i++ {
+				parts := strings.Split(generator.Generate(), "-")
+				So(parts, ShouldHaveLength, 2)
+				So(parts[0], ShouldEqual, parts[1])
+			}
+		})
+
+		Convey("Generates multiple groups", func() {
+			generator := newGenerator(`(\w{3})(\d{3})\2\1`)
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				So(str[6:9], ShouldEqual, str[3:6])
+				So(str[9:], ShouldEqual, str[:3])
+			}
+		})
+
+		Convey("Can be an alternative", func() {
+			ConveyGeneratesStringMatching(nil, `(x)(\1|a)`, `^x[xa]$`)
+		})
+
+		Convey("Generates nothing for unset groups", func() {
+			ConveyGeneratesStringMatching(nil, `((a)|b)\2`, `^(aa|b)$`)
+		})
+
+		Convey("Doesn't change capture group indices", func() {
+			var indices []int
+			generator, err := NewGenerator(`(a)\1(b)`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					indices = append(indices, index)
+					return strings.ToUpper(generator.Generate())
+				},
+			})
+			So(err, ShouldBeNil)
+
+			So(generator.Generate(), ShouldEqual, "AAB")
+			So(indices, ShouldResemble, []int{0, 1})
+		})
+
+		Convey("Returns error for invalid group", func() {
+			_, err := NewGenerator(`(a)\2`, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
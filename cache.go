@@ -0,0 +1,60 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+	"sync"
+)
+
+type generatorCacheKey struct {
+	pattern string
+	flags   syntax.Flags
+}
+
+// generatorCache maps generatorCacheKeys to Generators created by NewGeneratorCached.
+var generatorCache sync.Map
+
+/*
+NewGeneratorCached is like NewGenerator, but only creates one generator for each pattern and flags, and returns
+the same generator every time it's called with them again. This avoids parsing the pattern and building the
+generator every time when generating from the same pattern repeatedly, e.g. in a loop that calls Generate.
+
+Cached generators are created with the default GeneratorArgs, except for Flags and RngPool, which is set so they
+can be used by multiple goroutines. Since they are shared, their Reseed method must not be called, and since they use
+a pool of RNGs, the strings they generate can't be reproduced with a seed.
+Errors aren't cached. The cache is never cleared, so it shouldn't be used with an unbounded number of patterns.
+*/
+func NewGeneratorCached(pattern string, flags syntax.Flags) (Generator, error) {
+	key := generatorCacheKey{pattern, flags}
+	if generator, ok := generatorCache.Load(key); ok {
+		return generator.(Generator), nil
+	}
+
+	generator, err := NewGenerator(pattern, &GeneratorArgs{
+		Flags:   flags,
+		RngPool: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// If another goroutine created a generator for the same key first, use that one, so every caller gets the same
+	// generator.
+	actual, _ := generatorCache.LoadOrStore(key, generator)
+	return actual.(Generator), nil
+}
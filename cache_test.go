@@ -0,0 +1,106 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewGeneratorCached(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewGeneratorCached", t, func() {
+		Convey("Returns the same generator for the same pattern and flags", func() {
+			first, err := NewGeneratorCached(`cached-[a-z]{5}`, 0)
+			So(err, ShouldBeNil)
+			second, err := NewGeneratorCached(`cached-[a-z]{5}`, 0)
+			So(err, ShouldBeNil)
+			So(second, ShouldEqual, first)
+
+			for i := 0; i < SampleSize; i++ {
+				So(first.Generate(), ShouldHaveLength, len("cached-")+5)
+			}
+		})
+
+		Convey("Returns different generators for different flags", func() {
+			posix, err := NewGeneratorCached(`cached-a+`, 0)
+			So(err, ShouldBeNil)
+			perl, err := NewGeneratorCached(`cached-a+`, syntax.Perl)
+			So(err, ShouldBeNil)
+			So(perl, ShouldNotEqual, posix)
+
+			perlClass, err := NewGeneratorCached(`cached-\d`, syntax.Perl)
+			So(err, ShouldBeNil)
+			So(perlClass.Generate(), ShouldStartWith, "cached-")
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGeneratorCached(`cached-\d`, 0)
+			So(err, ShouldNotBeNil)
+			_, err = NewGeneratorCached(`cached-\d`, 0)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Is safe for concurrent use", func() {
+			var wg sync.WaitGroup
+			generators := make([]Generator, 8)
+			for i := range generators {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					generator, err := NewGeneratorCached(`cached-concurrent-\w{10}`, syntax.Perl)
+					if err != nil {
+						panic(err)
+					}
+					for j := 0; j < 100; j++ {
+						generator.Generate()
+					}
+					generators[i] = generator
+				}(i)
+			}
+			wg.Wait()
+
+			for _, generator := range generators {
+				So(generator, ShouldEqual, generators[0])
+			}
+		})
+	})
+}
+
+func BenchmarkNewGeneratorRepeated(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		generator, err := NewGenerator(BigFancyRegexp, nil)
+		if err != nil {
+			panic(err)
+		}
+		generator.Generate()
+	}
+}
+
+func BenchmarkNewGeneratorCachedRepeated(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		generator, err := NewGeneratorCached(BigFancyRegexp, 0)
+		if err != nil {
+			panic(err)
+		}
+		generator.Generate()
+	}
+}
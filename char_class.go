@@ -18,13 +18,54 @@ package regen
 
 import (
 	"fmt"
+	"regexp/syntax"
+	"sort"
+	"sync"
+	"unicode"
 )
 
+// Classes generated by ".". Surrogates (0xD800-0xDFFF) aren't valid runes, so they are excluded.
+var (
+	anyCharClass       = parseCharClass([]rune{1, 0xD7FF, 0xE000, unicode.MaxRune})
+	anyCharNotNLClass  = parseCharClass([]rune{1, '\n' - 1, '\n' + 1, 0xD7FF, 0xE000, unicode.MaxRune})
+	asciiClass         = newCharClass(1, unicode.MaxASCII)
+	printableClass     *tCharClass
+	printableClassOnce sync.Once
+)
+
+// getPrintableCharClass returns the class of all runes for which unicode.IsPrint is true.
+// It is built the first time it is needed, since it takes a while to build.
+func getPrintableCharClass() *tCharClass {
+	printableClassOnce.Do(func() {
+		var runes []rune
+		inRange := false
+		for r := rune(1); r <= unicode.MaxRune; r++ {
+			if unicode.IsPrint(r) == inRange {
+				continue
+			}
+			inRange = !inRange
+			if inRange {
+				runes = append(runes, r)
+			} else {
+				runes = append(runes, r-1)
+			}
+		}
+		if inRange {
+			runes = append(runes, unicode.MaxRune)
+		}
+		printableClass = parseCharClass(runes)
+	})
+	return printableClass
+}
+
 // CharClass represents a regular expression character class as a list of ranges.
 // The runes contained in the class can be accessed by index.
 type tCharClass struct {
 	Ranges    []tCharClassRange
 	TotalSize int32
+	// offsets[i] is the index of the first rune of Ranges[i], so GetRuneAt can binary search for the range
+	// containing an index.
+	offsets []int32
 }
 
 // CharClassRange represents a single range of characters in a character class.
@@ -35,11 +76,21 @@ type tCharClassRange struct {
 
 // NewCharClass creates a character class with a single range.
 func newCharClass(start rune, end rune) *tCharClass {
-	charRange := newCharClassRange(start, end)
-	return &tCharClass{
-		Ranges:    []tCharClassRange{charRange},
-		TotalSize: charRange.Size,
+	class := &tCharClass{}
+	class.add(start, end)
+	return class
+}
+
+// newCharClassFromRunes creates a character class containing runes, which may be in any order and contain
+// duplicates. Runes less than 1 are ignored. The result may be empty.
+func newCharClassFromRunes(runes []rune) *tCharClass {
+	ranges := make([]tCharClassRange, 0, len(runes))
+	for _, r := range runes {
+		if r >= 1 {
+			ranges = append(ranges, newCharClassRange(r, r))
+		}
 	}
+	return newCharClassFromRanges(ranges)
 }
 
 /*
@@ -54,10 +105,14 @@ e.g.
 
 "[^a-z]" -> "…" -> 0-(a-1), (z+1)-(max rune)
 */
+//
+// runes must be valid: see checkCharClassRunes.
 func parseCharClass(runes []rune) *tCharClass {
-	var totalSize int32
 	numRanges := len(runes) / 2
-	ranges := make([]tCharClassRange, numRanges, numRanges)
+	class := &tCharClass{
+		Ranges:  make([]tCharClassRange, 0, numRanges),
+		offsets: make([]int32, 0, numRanges),
+	}
 
 	for i := 0; i < numRanges; i++ {
 		start := runes[i*2]
@@ -69,25 +124,135 @@ func parseCharClass(runes []rune) *tCharClass {
 			// no less than 1.
 			start = 1
 		}
+		if end < start {
+			// The range only contained a null byte, e.g. in "[\x00a]".
+			continue
+		}
 
-		r := newCharClassRange(start, end)
-
-		ranges[i] = r
-		totalSize += r.Size
+		class.add(start, end)
 	}
 
-	return &tCharClass{ranges, totalSize}
+	return class
+}
+
+// checkCharClassRunes returns an error if runes isn't a valid encoding of a character class: pairs of runes
+// between 0 and unicode.MaxRune, where each range's start isn't greater than its end. The parser always produces
+// valid classes, but expressions built by hand may not be.
+func checkCharClassRunes(runes []rune) error {
+	if len(runes)%2 != 0 {
+		return fmt.Errorf("character class has an odd number of runes: %d", len(runes))
+	}
+	for i := 0; i < len(runes); i += 2 {
+		lo, hi := runes[i], runes[i+1]
+		if lo < 0 || hi > unicode.MaxRune {
+			return fmt.Errorf("character class range %U-%U contains invalid runes", lo, hi)
+		}
+		if lo > hi {
+			return fmt.Errorf("character class range %U-%U has a start greater than its end", lo, hi)
+		}
+	}
+	return nil
 }
 
 // GetRuneAt gets a rune from CharClass as a contiguous array of runes.
 func (class *tCharClass) GetRuneAt(i int32) rune {
-	for _, r := range class.Ranges {
-		if i < r.Size {
-			return r.Start + rune(i)
+	if i < 0 || i >= class.TotalSize {
+		panic("index out of bounds")
+	}
+	// Find the last range that starts at or before i.
+	n := sort.Search(len(class.offsets), func(k int) bool { return class.offsets[k] > i }) - 1
+	return class.Ranges[n].Start + rune(i-class.offsets[n])
+}
+
+// The set operations below treat classes as sets of runes. Their results have sorted, non-overlapping ranges,
+// and may be empty.
+
+// union returns a new class containing the runes that are in class, other, or both.
+func (class *tCharClass) union(other *tCharClass) *tCharClass {
+	ranges := make([]tCharClassRange, 0, len(class.Ranges)+len(other.Ranges))
+	ranges = append(ranges, class.Ranges...)
+	ranges = append(ranges, other.Ranges...)
+	return newCharClassFromRanges(ranges)
+}
+
+// intersect returns a new class containing the runes that are in both class and other.
+func (class *tCharClass) intersect(other *tCharClass) *tCharClass {
+	a, b := class.normalized(), other.normalized()
+	result := &tCharClass{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start := maxRune(a[i].Start, b[j].Start)
+		end := minRune(a[i].end(), b[j].end())
+		if start <= end {
+			result.add(start, end)
+		}
+		// Move past whichever range ends first, since it can't overlap anything else in the other class.
+		if a[i].end() < b[j].end() {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// subtract returns a new class containing the runes in class that aren't in other.
+func (class *tCharClass) subtract(other *tCharClass) *tCharClass {
+	excluded := other.normalized()
+	result := &tCharClass{}
+	j := 0
+	for _, r := range class.normalized() {
+		// Use int64s, since the end of a range plus one may overflow a rune.
+		start, end := int64(r.Start), int64(r.end())
+		for j < len(excluded) && int64(excluded[j].end()) < start {
+			j++
+		}
+		for k := j; k < len(excluded) && int64(excluded[k].Start) <= end; k++ {
+			if int64(excluded[k].Start) > start {
+				result.add(rune(start), excluded[k].Start-1)
+			}
+			start = int64(excluded[k].end()) + 1
+		}
+		if start <= end {
+			result.add(rune(start), rune(end))
+		}
+	}
+	return result
+}
+
+// normalized returns the class's ranges sorted, with overlapping and adjacent ranges merged.
+func (class *tCharClass) normalized() []tCharClassRange {
+	return newCharClassFromRanges(class.Ranges).Ranges
+}
+
+// newCharClassFromRanges creates a character class containing the runes in ranges, which may be in any order
+// and overlap.
+func newCharClassFromRanges(ranges []tCharClassRange) *tCharClass {
+	sorted := make([]tCharClassRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	result := &tCharClass{}
+	for _, r := range sorted {
+		if n := len(result.Ranges); n > 0 && int64(result.Ranges[n-1].end())+1 >= int64(r.Start) {
+			last := &result.Ranges[n-1]
+			if r.end() > last.end() {
+				grow := r.end() - last.end()
+				last.Size += grow
+				result.TotalSize += grow
+			}
+			continue
 		}
-		i -= r.Size
+		result.add(r.Start, r.end())
 	}
-	panic("index out of bounds")
+	return result
+}
+
+// add appends the range [start, end] to class. All construction should go through add, to keep offsets up to date.
+func (class *tCharClass) add(start, end rune) {
+	r := newCharClassRange(start, end)
+	class.Ranges = append(class.Ranges, r)
+	class.offsets = append(class.offsets, class.TotalSize)
+	class.TotalSize += r.Size
 }
 
 func (class *tCharClass) String() string {
@@ -111,6 +276,25 @@ func newCharClassRange(start rune, end rune) tCharClassRange {
 	}
 }
 
+// end returns the last rune in the range.
+func (r tCharClassRange) end() rune {
+	return r.Start + rune(r.Size-1)
+}
+
+func maxRune(a, b rune) rune {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minRune(a, b rune) rune {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func (r tCharClassRange) String() string {
 	if r.Size == 1 {
 		return fmt.Sprintf("%s:1", runesToString(r.Start))
@@ -118,3 +302,103 @@ func (r tCharClassRange) String() string {
 	return fmt.Sprintf("%s-%s:%d", runesToString(r.Start), runesToString(r.Start+rune(r.Size-1)), r.Size)
 
 }
+
+// sampleCharClass returns a class of up to args.MaxDistinctRunesPerClass runes chosen at random from charClass,
+// the class generated by regexp. The same sample is returned for every call with the same regexp, so the copies
+// of an expression made by Simplify (e.g. for `\pL{5}`) share it.
+func sampleCharClass(regexp *syntax.Regexp, charClass *tCharClass, args *GeneratorArgs) *tCharClass {
+	if sample, ok := args.sampledClasses[regexp]; ok {
+		return sample
+	}
+
+	n := int32(args.MaxDistinctRunesPerClass)
+	sample := charClass
+	if charClass.TotalSize > n {
+		// Floyd's algorithm chooses n distinct indices with exactly n random numbers.
+		chosen := make(map[int32]bool, n)
+		runes := make([]rune, 0, n)
+		for i := charClass.TotalSize - n; i < charClass.TotalSize; i++ {
+			j := i
+			if !args.Deterministic {
+				j = args.rng.Int31n(i + 1)
+			}
+			if chosen[j] {
+				j = i
+			}
+			chosen[j] = true
+			runes = append(runes, charClass.GetRuneAt(j))
+		}
+		sample = newCharClassFromRunes(runes)
+	}
+
+	if args.sampledClasses == nil {
+		args.sampledClasses = make(map[*syntax.Regexp]*tCharClass)
+	}
+	args.sampledClasses[regexp] = sample
+	return sample
+}
+
+// indexOf returns the index of r in class, as used by GetRuneAt, or false if class doesn't contain r.
+func (class *tCharClass) indexOf(r rune) (int32, bool) {
+	for i, rng := range class.Ranges {
+		if r >= rng.Start && r <= rng.end() {
+			return class.offsets[i] + int32(r-rng.Start), true
+		}
+	}
+	return 0, false
+}
+
+// weightedCharClass chooses runes from a class using GeneratorArgs.RuneWeights.
+type weightedCharClass struct {
+	class *tCharClass
+	// Indices in class of the runes that have weights, in ascending order, and their cumulative weights.
+	indices           []int32
+	cumulativeWeights []int
+	// The sum of the weights of all the runes in class: runes without weights have a weight of 1.
+	totalWeight int
+}
+
+// newWeightedCharClass returns a weightedCharClass for charClass, the class generated by regexp, or an error if
+// every rune in it has a weight of 0.
+func newWeightedCharClass(regexp *syntax.Regexp, charClass *tCharClass, weights map[rune]int) (*weightedCharClass,
+	error) {
+	class := &weightedCharClass{class: charClass}
+	for r := range weights {
+		if i, ok := charClass.indexOf(r); ok {
+			class.indices = append(class.indices, i)
+		}
+	}
+	sort.Slice(class.indices, func(i, j int) bool { return class.indices[i] < class.indices[j] })
+
+	for _, i := range class.indices {
+		class.totalWeight += weights[charClass.GetRuneAt(i)]
+		class.cumulativeWeights = append(class.cumulativeWeights, class.totalWeight)
+	}
+	class.totalWeight += int(charClass.TotalSize) - len(class.indices)
+	if class.totalWeight == 0 {
+		return nil, generatorError(nil, "every rune in character class %s has a weight of 0", regexp)
+	}
+	return class, nil
+}
+
+// choose returns the index in the class of a random rune.
+func (class *weightedCharClass) choose(state *generatorState) int32 {
+	n := state.intn(class.totalWeight)
+	if len(class.indices) > 0 && n < class.cumulativeWeights[len(class.cumulativeWeights)-1] {
+		k := sort.Search(len(class.cumulativeWeights), func(k int) bool { return class.cumulativeWeights[k] > n })
+		return class.indices[k]
+	}
+
+	// Choose one of the runes without weights, skipping the ones with weights.
+	i := int32(n)
+	if len(class.indices) > 0 {
+		i -= int32(class.cumulativeWeights[len(class.cumulativeWeights)-1])
+	}
+	for _, weighted := range class.indices {
+		if weighted > i {
+			break
+		}
+		i++
+	}
+	return i
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math"
+	"regexp/syntax"
+	"testing"
+	"unicode"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// classOf creates a class from pairs of runes, like parseCharClass.
+func classOf(runes ...rune) *tCharClass {
+	return parseCharClass(runes)
+}
+
+// classRunes returns every rune in class, in GetRuneAt order.
+func classRunes(class *tCharClass) string {
+	runes := make([]rune, class.TotalSize)
+	for i := range runes {
+		runes[i] = class.GetRuneAt(int32(i))
+	}
+	return string(runes)
+}
+
+func TestParseCharClass(t *testing.T) {
+	t.Parallel()
+
+	Convey("parseCharClass", t, func() {
+		parse := func(pattern string) *tCharClass {
+			regexp, err := syntax.Parse(pattern, syntax.Perl)
+			So(err, ShouldBeNil)
+			So(regexp.Op, ShouldEqual, syntax.OpCharClass)
+			return parseCharClass(regexp.Rune)
+		}
+
+		Convey("Parses POSIX classes", func() {
+			So(classRunes(parse(`[[:digit:]]`)), ShouldEqual, "0123456789")
+			So(classRunes(parse(`[[:xdigit:]]`)), ShouldEqual, "0123456789ABCDEFabcdef")
+			So(classRunes(parse(`[[:blank:]]`)), ShouldEqual, "\t ")
+			So(parse(`[[:alnum:]]`).TotalSize, ShouldEqual, 62)
+		})
+
+		Convey("Excludes null bytes", func() {
+			notDigit := parse(`[[:^digit:]]`)
+			So(notDigit.GetRuneAt(0), ShouldEqual, 1)
+			So(notDigit.TotalSize, ShouldEqual, unicode.MaxRune-10)
+
+			So(classRunes(parse(`[\x00a]`)), ShouldEqual, "a")
+			So(classRunes(parse(`[\x00-\x02a]`)), ShouldEqual, "\x01\x02a")
+		})
+	})
+}
+
+func TestCheckCharClassRunes(t *testing.T) {
+	t.Parallel()
+
+	Convey("checkCharClassRunes", t, func() {
+		Convey("Accepts classes from the parser", func() {
+			for _, pattern := range []string{`[a-z0-9]`, `[^a]`, `[\x00a]`, `\pL`, `[[:^digit:]]`} {
+				regexp, err := syntax.Parse(pattern, syntax.Perl)
+				So(err, ShouldBeNil)
+				So(checkCharClassRunes(regexp.Rune), ShouldBeNil)
+			}
+			So(checkCharClassRunes(nil), ShouldBeNil)
+		})
+
+		Convey("Rejects odd-length slices", func() {
+			So(checkCharClassRunes([]rune{'a'}), ShouldNotBeNil)
+			So(checkCharClassRunes([]rune{'a', 'c', 'x'}), ShouldNotBeNil)
+		})
+
+		Convey("Rejects ranges with a start greater than their end", func() {
+			So(checkCharClassRunes([]rune{'z', 'a'}), ShouldNotBeNil)
+			So(checkCharClassRunes([]rune{'a', 'c', 'q', 'p'}), ShouldNotBeNil)
+		})
+
+		Convey("Rejects invalid runes", func() {
+			So(checkCharClassRunes([]rune{-1, 'a'}), ShouldNotBeNil)
+			So(checkCharClassRunes([]rune{'a', unicode.MaxRune + 1}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNewCharClassFromRunes(t *testing.T) {
+	t.Parallel()
+
+	Convey("newCharClassFromRunes", t, func() {
+		Convey("Contains each rune once", func() {
+			class := newCharClassFromRunes([]rune("cabbage 日本語é日e"))
+			So(class.TotalSize, ShouldEqual, 10)
+			So(classRunes(class), ShouldEqual, " abcegé日本語")
+		})
+
+		Convey("Merges adjacent runes into ranges", func() {
+			class := newCharClassFromRunes([]rune("dcbaxz"))
+			So(class.Ranges, ShouldHaveLength, 3)
+			So(classRunes(class), ShouldEqual, "abcdxz")
+		})
+
+		Convey("Ignores runes less than 1", func() {
+			So(classRunes(newCharClassFromRunes([]rune{0, -1, 'a'})), ShouldEqual, "a")
+			So(newCharClassFromRunes(nil).TotalSize, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestCharClassGetRuneAt(t *testing.T) {
+	t.Parallel()
+
+	Convey("GetRuneAt", t, func() {
+		// bruteForceRuneAt looks up i by walking the ranges.
+		bruteForceRuneAt := func(class *tCharClass, i int32) rune {
+			for _, r := range class.Ranges {
+				if i < r.Size {
+					return r.Start + rune(i)
+				}
+				i -= r.Size
+			}
+			panic("index out of bounds")
+		}
+
+		regexp, err := syntax.Parse(`[\pL\pN]`, syntax.Perl)
+		So(err, ShouldBeNil)
+		classes := []*tCharClass{
+			parseCharClass(regexp.Rune),
+			newCharClass('a', 'z'),
+			classOf('a', 'a', 'c', 'c', 'e', 'e'),
+			anyCharNotNLClass,
+			getPrintableCharClass(),
+			classOf('a', 'z').subtract(classOf('e', 'f', 'q', 'q')).union(classOf('0', '9')),
+		}
+		So(len(classes[0].Ranges), ShouldBeGreaterThan, 500)
+
+		Convey("Agrees with a linear search", func() {
+			for _, class := range classes {
+				step := class.TotalSize/5000 + 1
+				for i := int32(0); i < class.TotalSize; i += step {
+					So(class.GetRuneAt(i), ShouldEqual, bruteForceRuneAt(class, i))
+				}
+				last := class.TotalSize - 1
+				So(class.GetRuneAt(last), ShouldEqual, bruteForceRuneAt(class, last))
+			}
+		})
+
+		Convey("Panics when out of bounds", func() {
+			class := newCharClass('a', 'c')
+			So(func() { class.GetRuneAt(3) }, ShouldPanic)
+			So(func() { class.GetRuneAt(-1) }, ShouldPanic)
+			So(func() { classOf().GetRuneAt(0) }, ShouldPanic)
+		})
+	})
+}
+
+func BenchmarkCharClassGetRuneAt(b *testing.B) {
+	regexp, err := syntax.Parse(`\pL`, syntax.Perl)
+	if err != nil {
+		panic(err)
+	}
+	class := parseCharClass(regexp.Rune)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		class.GetRuneAt(int32(i) % class.TotalSize)
+	}
+}
+
+func TestCharClassSetOperations(t *testing.T) {
+	t.Parallel()
+
+	Convey("Char class set operations", t, func() {
+		abc := classOf('a', 'c')
+		cde := classOf('c', 'e')
+		def := classOf('d', 'f')
+		xyz := classOf('x', 'z')
+		empty := classOf()
+
+		Convey("Union", func() {
+			So(classRunes(abc.union(cde)), ShouldEqual, "abcde")
+			So(abc.union(cde).Ranges, ShouldHaveLength, 1)
+
+			// Adjacent ranges are merged.
+			So(classRunes(abc.union(def)), ShouldEqual, "abcdef")
+			So(abc.union(def).Ranges, ShouldHaveLength, 1)
+
+			So(classRunes(xyz.union(abc)), ShouldEqual, "abcxyz")
+			So(xyz.union(abc).Ranges, ShouldHaveLength, 2)
+
+			So(classRunes(abc.union(empty)), ShouldEqual, "abc")
+			So(empty.union(empty).TotalSize, ShouldEqual, 0)
+		})
+
+		Convey("Intersect", func() {
+			So(classRunes(abc.intersect(cde)), ShouldEqual, "c")
+			So(classRunes(classOf('a', 'z').intersect(classOf('b', 'c', 'x', 'x'))), ShouldEqual, "bcx")
+			So(classRunes(classOf('a', 'c', 'e', 'g').intersect(classOf('b', 'f'))), ShouldEqual, "bcef")
+
+			So(abc.intersect(def).TotalSize, ShouldEqual, 0)
+			So(abc.intersect(xyz).TotalSize, ShouldEqual, 0)
+			So(abc.intersect(empty).TotalSize, ShouldEqual, 0)
+		})
+
+		Convey("Subtract", func() {
+			So(classRunes(abc.subtract(cde)), ShouldEqual, "ab")
+			So(classRunes(cde.subtract(abc)), ShouldEqual, "de")
+			So(classRunes(classOf('a', 'g').subtract(classOf('b', 'b', 'd', 'e'))), ShouldEqual, "acfg")
+
+			So(classRunes(abc.subtract(def)), ShouldEqual, "abc")
+			So(classRunes(abc.subtract(xyz)), ShouldEqual, "abc")
+			So(classRunes(abc.subtract(empty)), ShouldEqual, "abc")
+
+			So(abc.subtract(abc).TotalSize, ShouldEqual, 0)
+			So(abc.subtract(classOf('a', 'z')).TotalSize, ShouldEqual, 0)
+			So(empty.subtract(abc).TotalSize, ShouldEqual, 0)
+		})
+
+		Convey("Handles the largest runes", func() {
+			all := newCharClass(1, math.MaxInt32)
+			last := newCharClass(math.MaxInt32, math.MaxInt32)
+
+			So(all.subtract(last).TotalSize, ShouldEqual, math.MaxInt32-1)
+			So(all.intersect(last).TotalSize, ShouldEqual, 1)
+			So(all.union(last).TotalSize, ShouldEqual, math.MaxInt32)
+		})
+
+		Convey("Operations agree with each other", func() {
+			a := classOf('a', 'k', 'm', 'p', 'x', 'z')
+			b := classOf('c', 'n', 'q', 'y')
+
+			So(classRunes(a.subtract(b).union(a.intersect(b))), ShouldEqual, classRunes(a))
+			So(a.union(b).TotalSize, ShouldEqual, a.TotalSize+b.TotalSize-a.intersect(b).TotalSize)
+		})
+
+		Convey("Normalizes unsorted and overlapping ranges", func() {
+			class := newCharClassFromRanges([]tCharClassRange{
+				newCharClassRange('x', 'z'),
+				newCharClassRange('a', 'c'),
+				newCharClassRange('b', 'd'),
+			})
+			So(classRunes(class), ShouldEqual, "abcdxyz")
+			So(class.Ranges, ShouldHaveLength, 2)
+
+			So(classRunes(newCharClassFromRunes([]rune("cabbage\x00"))), ShouldEqual, "abceg")
+		})
+	})
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Command regen prints random strings matching a regular expression, one per line.
+
+Usage:
+
+	regen [-n count] [-seed seed] [-flags flags] pattern
+
+Flags is a comma-separated list of regexp/syntax flag names accepted by regen.ParseFlags, e.g. "perl,matchnl".
+If -seed isn't given, a random seed is used.
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zach-klippenstein/goregen"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments (not including the program name), and returns the exit code.
+func run(arguments []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("regen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: regen [-n count] [-seed seed] [-flags flags] pattern")
+		flags.PrintDefaults()
+	}
+	count := flags.Int("n", 1, "number of strings to generate")
+	seed := flags.Int64("seed", 0, "seed for the random number generator (default random)")
+	flagNames := flags.String("flags", "", "comma-separated syntax flag names, e.g. perl,matchnl (one of "+
+		strings.Join(flagNameList(), ", ")+")")
+
+	if err := flags.Parse(arguments); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *count < 0 {
+		fmt.Fprintf(stderr, "regen: invalid count %d\n", *count)
+		return 2
+	}
+
+	args := &regen.GeneratorArgs{}
+	var err error
+	if args.Flags, err = regen.ParseFlags(strings.Split(*flagNames, ",")); err != nil {
+		fmt.Fprintf(stderr, "regen: %s\n", err)
+		return 2
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			args.RngSource = rand.NewSource(*seed)
+		}
+	})
+
+	generator, err := regen.NewGenerator(flags.Arg(0), args)
+	if err != nil {
+		fmt.Fprintf(stderr, "regen: %s\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(stdout)
+	for i := 0; i < *count; i++ {
+		out.WriteString(generator.Generate())
+		out.WriteByte('\n')
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(stderr, "regen: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// flagNameList returns the names of the syntax flags accepted by -flags, sorted.
+func flagNameList() []string {
+	var names []string
+	for name := range regen.AvailableFlags() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
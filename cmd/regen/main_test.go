@@ -0,0 +1,104 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func runCommand(arguments ...string) (code int, lines []string, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(arguments, &out, &errOut)
+	lines = strings.Split(out.String(), "\n")
+	// Every line ends with a newline, so the last element is always empty.
+	return code, lines[:len(lines)-1], errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	Convey("run", t, func() {
+		Convey("Generates one string by default", func() {
+			code, lines, stderr := runCommand(`a{3}`)
+			So(code, ShouldEqual, 0)
+			So(stderr, ShouldBeEmpty)
+			So(lines, ShouldResemble, []string{"aaa"})
+		})
+
+		Convey("Generates n matching strings", func() {
+			for _, pattern := range []string{`[a-z]{5}`, `(foo|bar)\d*`, `x?y+`} {
+				code, lines, _ := runCommand("-n", "20", "-flags", "perl", pattern)
+				So(code, ShouldEqual, 0)
+				So(len(lines), ShouldEqual, 20)
+
+				matcher := regexp.MustCompile(`^(?:` + pattern + `)$`)
+				for _, line := range lines {
+					So(matcher.MatchString(line), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Generates nothing when n is 0", func() {
+			code, lines, _ := runCommand("-n", "0", "a")
+			So(code, ShouldEqual, 0)
+			So(lines, ShouldBeEmpty)
+		})
+
+		Convey("Is repeatable with a seed", func() {
+			_, first, _ := runCommand("-n", "5", "-seed", "42", "[a-z]{10}")
+			_, second, _ := runCommand("-n", "5", "-seed", "42", "[a-z]{10}")
+			So(first, ShouldResemble, second)
+		})
+
+		Convey("Fails on invalid patterns", func() {
+			code, lines, stderr := runCommand("a(")
+			So(code, ShouldNotEqual, 0)
+			So(lines, ShouldBeEmpty)
+			So(stderr, ShouldContainSubstring, "missing closing")
+		})
+
+		Convey("Fails on Perl syntax without the perl flag", func() {
+			code, _, stderr := runCommand(`\d`)
+			So(code, ShouldNotEqual, 0)
+			So(stderr, ShouldNotBeEmpty)
+		})
+
+		Convey("Fails on unknown flags", func() {
+			code, _, stderr := runCommand("-flags", "perl,bogus", "a")
+			So(code, ShouldNotEqual, 0)
+			So(stderr, ShouldContainSubstring, `unknown flag "bogus"`)
+		})
+
+		Convey("Lists the available flags in the usage", func() {
+			_, _, stderr := runCommand("-h")
+			So(stderr, ShouldContainSubstring, "matchnl")
+			So(stderr, ShouldContainSubstring, "foldcase")
+		})
+
+		Convey("Fails without exactly one pattern", func() {
+			code, _, _ := runCommand()
+			So(code, ShouldNotEqual, 0)
+			code, _, _ = runCommand("a", "b")
+			So(code, ShouldNotEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// syntaxFlagNames maps the names accepted by ParseFlags to parser flags.
+// WasDollar isn't included, since it's only set by the parser.
+var syntaxFlagNames = map[string]syntax.Flags{
+	"foldcase":      syntax.FoldCase,
+	"literal":       syntax.Literal,
+	"classnl":       syntax.ClassNL,
+	"dotnl":         syntax.DotNL,
+	"oneline":       syntax.OneLine,
+	"nongreedy":     syntax.NonGreedy,
+	"perlx":         syntax.PerlX,
+	"unicodegroups": syntax.UnicodeGroups,
+	"simple":        syntax.Simple,
+	"matchnl":       syntax.MatchNL,
+	"perl":          syntax.Perl,
+	"posix":         syntax.POSIX,
+}
+
+// AvailableFlags returns the flag names accepted by ParseFlags, in lower case, and their values.
+// The returned map is a copy, so it may be modified.
+func AvailableFlags() map[string]syntax.Flags {
+	flags := make(map[string]syntax.Flags, len(syntaxFlagNames))
+	for name, flag := range syntaxFlagNames {
+		flags[name] = flag
+	}
+	return flags
+}
+
+// ParseFlags returns the combination of the syntax.Flags constants named by names. Names are the constant
+// names in any case, e.g. "perl", "MatchNL", or "foldcase" (see AvailableFlags). Empty names are ignored.
+func ParseFlags(names []string) (syntax.Flags, error) {
+	var flags syntax.Flags
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		flag, ok := syntaxFlagNames[strings.ToLower(name)]
+		if !ok {
+			return 0, generatorError(nil, "unknown flag %q", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so RepeatDistributions are encoded as "uniform" or "geometric"
+// in JSON.
+func (d RepeatDistribution) MarshalText() ([]byte, error) {
+	switch d {
+	case UniformRepeatDistribution:
+		return []byte("uniform"), nil
+	case GeometricRepeatDistribution:
+		return []byte("geometric"), nil
+	}
+	return nil, generatorError(nil, "invalid RepeatDistribution %d", int(d))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *RepeatDistribution) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "uniform":
+		*d = UniformRepeatDistribution
+	case "geometric":
+		*d = GeometricRepeatDistribution
+	default:
+		return generatorError(nil, "unknown RepeatDistribution %q", text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so CharClassStrategies are encoded as "random" or "sequential"
+// in JSON.
+func (s CharClassStrategy) MarshalText() ([]byte, error) {
+	switch s {
+	case RandomCharClassStrategy:
+		return []byte("random"), nil
+	case SequentialCharClassStrategy:
+		return []byte("sequential"), nil
+	}
+	return nil, generatorError(nil, "invalid CharClassStrategy %d", int(s))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *CharClassStrategy) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "random":
+		*s = RandomCharClassStrategy
+	case "sequential":
+		*s = SequentialCharClassStrategy
+	default:
+		return generatorError(nil, "unknown CharClassStrategy %q", text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so NormalizeForms are encoded as "none", "nfc", or "nfd" in JSON.
+func (form NormalizeForm) MarshalText() ([]byte, error) {
+	switch form {
+	case NoNormalization:
+		return []byte("none"), nil
+	case NFC:
+		return []byte("nfc"), nil
+	case NFD:
+		return []byte("nfd"), nil
+	}
+	return nil, generatorError(nil, "invalid NormalizeForm %d", int(form))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (form *NormalizeForm) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "none":
+		*form = NoNormalization
+	case "nfc":
+		*form = NFC
+	case "nfd":
+		*form = NFD
+	default:
+		return generatorError(nil, "unknown NormalizeForm %q", text)
+	}
+	return nil
+}
+
+/*
+GeneratorConfig is a JSON-serializable version of GeneratorArgs, for driving generation from config files and
+other tools. E.g.
+
+	{"flags": ["perl", "matchnl"], "seed": 42, "maxUnboundedRepeatCount": 8, "repeatDistribution": "geometric"}
+
+AllowRunes and ExcludeRunes are strings of runes, e.g. "abc", the keys of RuneWeights are single runes, e.g.
+{"e": 10}, and Timeout is a duration accepted by time.ParseDuration, e.g. "1.5s". Options that are functions or
+interfaces (Rand, AlternateWeight, PatternWeight, CaptureGroupHandler, and Overrides) aren't included; Seed is
+used instead of RngSource.
+*/
+type GeneratorConfig struct {
+	// Names of syntax flags, as accepted by ParseFlags.
+	Flags []string `json:"flags,omitempty"`
+
+	// If set, the RNG is seeded with it. Otherwise a random seed is used.
+	Seed *int64 `json:"seed,omitempty"`
+	// Used as GeneratorArgs.SeedString if Seed isn't set.
+	SeedString string `json:"seedString,omitempty"`
+
+	MaxUnboundedRepeatCount int                `json:"maxUnboundedRepeatCount,omitempty"`
+	MinUnboundedRepeatCount int                `json:"minUnboundedRepeatCount,omitempty"`
+	MinRepeatOverride       int                `json:"minRepeatOverride,omitempty"`
+	MaxGenerateAllCount     int                `json:"maxGenerateAllCount,omitempty"`
+	MaxTotalLength          int                `json:"maxTotalLength,omitempty"`
+	SoftMaxLength           int                `json:"softMaxLength,omitempty"`
+	MaxDepth                int                `json:"maxDepth,omitempty"`
+	MaxAlternateDepth       int                `json:"maxAlternateDepth,omitempty"`
+	RepeatDistribution      RepeatDistribution `json:"repeatDistribution,omitempty"`
+
+	MaxDistinctRunesPerClass int               `json:"maxDistinctRunesPerClass,omitempty"`
+	DigitScript              DigitScript       `json:"digitScript,omitempty"`
+	CharClassStrategy        CharClassStrategy `json:"charClassStrategy,omitempty"`
+	NormalizeForm            NormalizeForm     `json:"normalizeForm,omitempty"`
+
+	AllowRunes          string         `json:"allowRunes,omitempty"`
+	ExcludeRunes        string         `json:"excludeRunes,omitempty"`
+	RuneWeights         map[string]int `json:"runeWeights,omitempty"`
+	ForbiddenSubstrings []string       `json:"forbiddenSubstrings,omitempty"`
+	Timeout             string         `json:"timeout,omitempty"`
+
+	Deterministic bool `json:"deterministic,omitempty"`
+	ByteMode      bool `json:"byteMode,omitempty"`
+	PrintableOnly bool `json:"printableOnly,omitempty"`
+	RawAnyChar    bool `json:"rawAnyChar,omitempty"`
+	ASCIIOnly     bool `json:"asciiOnly,omitempty"`
+	Concurrent    bool `json:"concurrent,omitempty"`
+	RngPool       bool `json:"rngPool,omitempty"`
+	Validate      bool `json:"validate,omitempty"`
+
+	WordBoundaries              bool `json:"wordBoundaries,omitempty"`
+	DedupeAlternates            bool `json:"dedupeAlternates,omitempty"`
+	AvoidEmptyAlternates        bool `json:"avoidEmptyAlternates,omitempty"`
+	NonEmpty                    bool `json:"nonEmpty,omitempty"`
+	IgnoreUnsupportedAssertions bool `json:"ignoreUnsupportedAssertions,omitempty"`
+	AllowDescendingRanges       bool `json:"allowDescendingRanges,omitempty"`
+}
+
+// GeneratorArgs returns the GeneratorArgs described by the config, or an error if it contains unknown flag names,
+// negative bounds, rune weight keys that aren't single runes, or an invalid timeout.
+func (c *GeneratorConfig) GeneratorArgs() (*GeneratorArgs, error) {
+	flags, err := ParseFlags(c.Flags)
+	if err != nil {
+		return nil, err
+	}
+	if c.MaxUnboundedRepeatCount < 0 || c.MinUnboundedRepeatCount < 0 {
+		return nil, generatorError(nil, "invalid unbounded repeat counts [%d, %d]",
+			c.MinUnboundedRepeatCount, c.MaxUnboundedRepeatCount)
+	}
+
+	args := &GeneratorArgs{
+		Flags:                       flags,
+		SeedString:                  c.SeedString,
+		MaxUnboundedRepeatCount:     uint(c.MaxUnboundedRepeatCount),
+		MinUnboundedRepeatCount:     uint(c.MinUnboundedRepeatCount),
+		MinRepeatOverride:           c.MinRepeatOverride,
+		MaxGenerateAllCount:         c.MaxGenerateAllCount,
+		MaxTotalLength:              c.MaxTotalLength,
+		SoftMaxLength:               c.SoftMaxLength,
+		MaxDepth:                    c.MaxDepth,
+		MaxAlternateDepth:           c.MaxAlternateDepth,
+		RepeatDistribution:          c.RepeatDistribution,
+		MaxDistinctRunesPerClass:    c.MaxDistinctRunesPerClass,
+		DigitScript:                 c.DigitScript,
+		CharClassStrategy:           c.CharClassStrategy,
+		NormalizeForm:               c.NormalizeForm,
+		ForbiddenSubstrings:         c.ForbiddenSubstrings,
+		Deterministic:               c.Deterministic,
+		ByteMode:                    c.ByteMode,
+		PrintableOnly:               c.PrintableOnly,
+		RawAnyChar:                  c.RawAnyChar,
+		ASCIIOnly:                   c.ASCIIOnly,
+		Concurrent:                  c.Concurrent,
+		RngPool:                     c.RngPool,
+		Validate:                    c.Validate,
+		WordBoundaries:              c.WordBoundaries,
+		DedupeAlternates:            c.DedupeAlternates,
+		AvoidEmptyAlternates:        c.AvoidEmptyAlternates,
+		NonEmpty:                    c.NonEmpty,
+		IgnoreUnsupportedAssertions: c.IgnoreUnsupportedAssertions,
+		AllowDescendingRanges:       c.AllowDescendingRanges,
+	}
+	if c.Seed != nil {
+		args.RngSource = rand.NewSource(*c.Seed)
+	}
+	if c.AllowRunes != "" {
+		args.AllowRunes = []rune(c.AllowRunes)
+	}
+	if c.ExcludeRunes != "" {
+		args.ExcludeRunes = []rune(c.ExcludeRunes)
+	}
+	if len(c.RuneWeights) > 0 {
+		args.RuneWeights = make(map[rune]int, len(c.RuneWeights))
+		for key, weight := range c.RuneWeights {
+			r, size := utf8.DecodeRuneInString(key)
+			if size == 0 || size != len(key) {
+				return nil, generatorError(nil, "rune weight key %q isn't a single rune", key)
+			}
+			args.RuneWeights[r] = weight
+		}
+	}
+	if c.Timeout != "" {
+		if args.Timeout, err = time.ParseDuration(c.Timeout); err != nil {
+			return nil, generatorError(err, "invalid timeout %q", c.Timeout)
+		}
+		if args.Timeout < 0 {
+			return nil, generatorError(nil, "negative timeout %q", c.Timeout)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"encoding/json"
+	"regexp/syntax"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseFlags(t *testing.T) {
+	t.Parallel()
+
+	Convey("ParseFlags", t, func() {
+		Convey("No names", func() {
+			flags, err := ParseFlags(nil)
+			So(err, ShouldBeNil)
+			So(flags, ShouldEqual, syntax.Flags(0))
+		})
+
+		Convey("Combines names", func() {
+			flags, err := ParseFlags([]string{"perl", "MatchNL", " foldcase ", ""})
+			So(err, ShouldBeNil)
+			So(flags, ShouldEqual, syntax.Perl|syntax.MatchNL|syntax.FoldCase)
+		})
+
+		Convey("Parses every available flag", func() {
+			available := AvailableFlags()
+			So(available, ShouldContainKey, "perl")
+			So(available, ShouldContainKey, "matchnl")
+			So(available, ShouldContainKey, "dotnl")
+			So(available, ShouldContainKey, "oneline")
+			So(available, ShouldContainKey, "classnl")
+
+			for name, value := range available {
+				flags, err := ParseFlags([]string{name})
+				So(err, ShouldBeNil)
+				So(flags, ShouldEqual, value)
+			}
+		})
+
+		Convey("AvailableFlags returns a copy", func() {
+			AvailableFlags()["perl"] = 0
+			So(AvailableFlags()["perl"], ShouldEqual, syntax.Perl)
+		})
+
+		Convey("Unknown name", func() {
+			_, err := ParseFlags([]string{"perl", "bogus"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, `"bogus"`)
+		})
+	})
+}
+
+func TestGeneratorConfig(t *testing.T) {
+	t.Parallel()
+
+	Convey("GeneratorConfig", t, func() {
+		Convey("Round trips through JSON", func() {
+			seed := int64(42)
+			config := GeneratorConfig{
+				Flags:                   []string{"perl", "dotnl"},
+				Seed:                    &seed,
+				MaxUnboundedRepeatCount: 8,
+				RepeatDistribution:      GeometricRepeatDistribution,
+				CharClassStrategy:       SequentialCharClassStrategy,
+				NormalizeForm:           NFC,
+				ASCIIOnly:               true,
+				AllowRunes:              "abcé",
+				ExcludeRunes:            "b",
+				RuneWeights:             map[string]int{"a": 3, "é": 0},
+				ForbiddenSubstrings:     []string{"cab"},
+				Timeout:                 "1.5s",
+			}
+
+			data, err := json.Marshal(&config)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, `"repeatDistribution":"geometric"`)
+			So(string(data), ShouldContainSubstring, `"charClassStrategy":"sequential"`)
+			So(string(data), ShouldContainSubstring, `"normalizeForm":"nfc"`)
+			So(string(data), ShouldContainSubstring, `"runeWeights":{"a":3,"é":0}`)
+			So(string(data), ShouldContainSubstring, `"timeout":"1.5s"`)
+
+			var decoded GeneratorConfig
+			So(json.Unmarshal(data, &decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, config)
+
+			args, err := decoded.GeneratorArgs()
+			So(err, ShouldBeNil)
+			So(args.Flags, ShouldEqual, syntax.Perl|syntax.DotNL)
+			So(args.MaxUnboundedRepeatCount, ShouldEqual, 8)
+			So(args.RepeatDistribution, ShouldEqual, GeometricRepeatDistribution)
+			So(args.CharClassStrategy, ShouldEqual, SequentialCharClassStrategy)
+			So(args.NormalizeForm, ShouldEqual, NFC)
+			So(args.ASCIIOnly, ShouldBeTrue)
+			So(args.RngSource, ShouldNotBeNil)
+			So(args.AllowRunes, ShouldResemble, []rune("abcé"))
+			So(args.ExcludeRunes, ShouldResemble, []rune("b"))
+			So(args.RuneWeights, ShouldResemble, map[rune]int{'a': 3, 'é': 0})
+			So(args.ForbiddenSubstrings, ShouldResemble, []string{"cab"})
+			So(args.Timeout, ShouldEqual, 1500*time.Millisecond)
+		})
+
+		Convey("Seeds the RNG", func() {
+			var config GeneratorConfig
+			So(json.Unmarshal([]byte(`{"flags": ["perl"], "seed": 7}`), &config), ShouldBeNil)
+
+			generate := func() string {
+				args, err := config.GeneratorArgs()
+				So(err, ShouldBeNil)
+				generator, err := NewGenerator(`\w{20}`, args)
+				So(err, ShouldBeNil)
+				return generator.Generate()
+			}
+			So(generate(), ShouldEqual, generate())
+
+			config = GeneratorConfig{Flags: []string{"perl"}, SeedString: "fixture"}
+			So(generate(), ShouldEqual, generate())
+		})
+
+		Convey("Empty config", func() {
+			var config GeneratorConfig
+			So(json.Unmarshal([]byte(`{}`), &config), ShouldBeNil)
+
+			args, err := config.GeneratorArgs()
+			So(err, ShouldBeNil)
+			So(*args, ShouldResemble, GeneratorArgs{})
+		})
+
+		Convey("Unknown flag", func() {
+			config := GeneratorConfig{Flags: []string{"matchnl", "nope"}}
+			_, err := config.GeneratorArgs()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid rune weights and timeouts", func() {
+			for _, config := range []GeneratorConfig{
+				{RuneWeights: map[string]int{"ab": 1}},
+				{RuneWeights: map[string]int{"": 1}},
+				{Timeout: "soon"},
+				{Timeout: "-1s"},
+			} {
+				_, err := config.GeneratorArgs()
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Negative bounds", func() {
+			config := GeneratorConfig{MaxUnboundedRepeatCount: -1}
+			_, err := config.GeneratorArgs()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Unknown repeat distribution", func() {
+			var config GeneratorConfig
+			err := json.Unmarshal([]byte(`{"repeatDistribution": "normal"}`), &config)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Unknown normalize form", func() {
+			var config GeneratorConfig
+			err := json.Unmarshal([]byte(`{"normalizeForm": "nfkc"}`), &config)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/big"
+)
+
+// seedCorpusAttempts is the number of strings SeedCorpus generates for each string it returns, at most,
+// when looking for distinct strings.
+const seedCorpusAttempts = 10
+
+/*
+SeedCorpus returns up to n distinct strings that match the regular expression pattern, for seeding a fuzzer
+(e.g. with testing.F.Add). If args is nil, default values are used.
+
+The strings are chosen to be as different as possible: if pattern matches at most n strings, all of them are returned.
+Otherwise, half of the strings are generated with GeometricRepeatDistribution, so they are short, and half with
+UniformRepeatDistribution, so they are long. Fewer than n strings are returned if n distinct strings can't be found.
+*/
+func SeedCorpus(pattern string, n int, args *GeneratorArgs) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	// If there aren't many matching strings, just return all of them. GenerateAll must be allowed to generate
+	// all n of them, even if that's more than MaxGenerateAllCount.
+	if count, err := CountMatches(pattern, args); err == nil && count.Cmp(big.NewInt(int64(n))) <= 0 {
+		var allArgs GeneratorArgs
+		if args != nil {
+			allArgs = *args
+		}
+		if allArgs.MaxGenerateAllCount < n {
+			allArgs.MaxGenerateAllCount = n
+		}
+		return GenerateAll(pattern, &allArgs)
+	}
+
+	var shortArgs, longArgs GeneratorArgs
+	if args != nil {
+		shortArgs = *args
+		longArgs = *args
+	}
+	shortArgs.RepeatDistribution = GeometricRepeatDistribution
+	longArgs.RepeatDistribution = UniformRepeatDistribution
+
+	short, err := NewGenerator(pattern, &shortArgs)
+	if err != nil {
+		return nil, err
+	}
+	long, err := NewGenerator(pattern, &longArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < n*seedCorpusAttempts && len(strs) < n; i++ {
+		generator := short
+		if i%2 == 1 {
+			generator = long
+		}
+
+		str := generator.Generate()
+		if !seen[str] {
+			seen[str] = true
+			strs = append(strs, str)
+		}
+	}
+	return strs, nil
+}
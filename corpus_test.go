@@ -0,0 +1,95 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSeedCorpus(t *testing.T) {
+	t.Parallel()
+
+	Convey("SeedCorpus", t, func() {
+		Convey("Returns distinct matching strings", func() {
+			pattern := `(GET|POST|DELETE) /[a-z]+(/\d{1,5})*( HTTP/1\.[01])?`
+			strs, err := SeedCorpus(pattern, 50, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 50)
+
+			re := regexp.MustCompile("^" + pattern + "$")
+			seen := make(map[string]bool)
+			methods := make(map[string]bool)
+			for _, str := range strs {
+				So(re.MatchString(str), ShouldBeTrue)
+				seen[str] = true
+				methods[re.FindStringSubmatch(str)[1]] = true
+			}
+			So(seen, ShouldHaveLength, 50)
+			So(methods, ShouldHaveLength, 3)
+		})
+
+		Convey("Varies lengths", func() {
+			strs, err := SeedCorpus(`a+`, 20, nil)
+			So(err, ShouldBeNil)
+
+			shortest, longest := len(strs[0]), len(strs[0])
+			for _, str := range strs {
+				if len(str) < shortest {
+					shortest = len(str)
+				}
+				if len(str) > longest {
+					longest = len(str)
+				}
+			}
+			So(shortest, ShouldBeLessThan, 5)
+			So(longest, ShouldBeGreaterThan, 100)
+		})
+
+		Convey("Returns every string of small patterns", func() {
+			strs, err := SeedCorpus(`(foo|bar)[01]`, 10, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"foo0", "foo1", "bar0", "bar1"})
+		})
+
+		Convey("Returns every string of patterns with more than MaxGenerateAllCount strings", func() {
+			strs, err := SeedCorpus(`[0-9]{5}`, 100000, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 100000)
+			So(strs[0], ShouldEqual, "00000")
+			So(strs[99999], ShouldEqual, "99999")
+
+			strs, err = SeedCorpus(`[ab]{4}`, 20, &GeneratorArgs{MaxGenerateAllCount: 5})
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 16)
+		})
+
+		Convey("Returns nothing for n <= 0", func() {
+			strs, err := SeedCorpus(`a+`, 0, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldBeEmpty)
+		})
+
+		Convey("Returns parse errors", func() {
+			_, err := SeedCorpus(`[`, 10, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+)
+
+// alternateCoverage records which alternatives of each alternation, and which runes of each character class,
+// have been chosen, for GenerateCovering.
+type alternateCoverage map[*syntax.Regexp]*choices
+
+// choices records which of a number of choices have been made.
+type choices struct {
+	chosen    []bool
+	remaining int
+}
+
+// chooseUncovered returns one of n choices of regexp (an alternative of an alternation, or a rune of a character
+// class) that hasn't been chosen yet, using intn to choose between them, and records it as chosen. It returns false
+// if every choice has been made.
+func (coverage alternateCoverage) chooseUncovered(regexp *syntax.Regexp, n int, intn func(n int) int) (int, bool) {
+	c, ok := coverage[regexp]
+	if !ok {
+		c = &choices{make([]bool, n), n}
+		coverage[regexp] = c
+	}
+	if c.remaining == 0 {
+		return 0, false
+	}
+
+	// Start at a random choice and take the next one that hasn't been made, so classes with many runes don't need
+	// a list of the remaining ones.
+	i := intn(n)
+	for c.chosen[i] {
+		i = (i + 1) % n
+	}
+	c.chosen[i] = true
+	c.remaining--
+	return i, true
+}
+
+// chooseUncovered is like alternateCoverage.chooseUncovered, but returns false if state isn't recording coverage.
+func (state *generatorState) chooseUncovered(regexp *syntax.Regexp, n int) (int, bool) {
+	if state.coverage == nil {
+		return 0, false
+	}
+	return state.coverage.chooseUncovered(regexp, n, state.intn)
+}
+
+func (gen *internalGenerator) GenerateCovering(n int) ([]string, error) {
+	return generateCovering(n, func(coverage alternateCoverage) (string, error) {
+		return gen.generateCovering(coverage)
+	})
+}
+
+// generateCovering generates a string, choosing alternatives that aren't in coverage yet.
+func (gen *internalGenerator) generateCovering(coverage alternateCoverage) (string, error) {
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	state := gen.newState(buffer, nil)
+	state.coverage = coverage
+	if err := gen.generate(state); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// generateCovering returns n strings from generate, which is passed the same coverage for every string.
+func generateCovering(n int, generate func(coverage alternateCoverage) (string, error)) ([]string, error) {
+	if n < 0 {
+		return nil, generatorError(nil, "negative number of strings %d", n)
+	}
+
+	coverage := make(alternateCoverage)
+	strs := make([]string, n)
+	for i := range strs {
+		var err error
+		if strs[i], err = generate(coverage); err != nil {
+			return nil, err
+		}
+	}
+	return strs, nil
+}
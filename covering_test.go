@@ -0,0 +1,110 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateCovering(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateCovering", t, func() {
+		Convey("Chooses every alternative", func() {
+			for seed := int64(0); seed < 20; seed++ {
+				generator, err := NewGenerator(`(a|b|c)x`, &GeneratorArgs{RngSource: rand.NewSource(seed)})
+				So(err, ShouldBeNil)
+				strs, err := generator.(TestDataGenerator).GenerateCovering(3)
+				So(err, ShouldBeNil)
+				So(strs, ShouldContain, "ax")
+				So(strs, ShouldContain, "bx")
+				So(strs, ShouldContain, "cx")
+			}
+		})
+
+		seen := func(strs []string) map[string]bool {
+			seen := make(map[string]bool)
+			for _, str := range strs {
+				for _, r := range str {
+					seen[string(r)] = true
+				}
+			}
+			return seen
+		}
+
+		Convey("Chooses nested alternatives", func() {
+			generator, err := NewGenerator(`(a|b(c|d|e))(f|g)`, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+			strs, err := generator.(TestDataGenerator).GenerateCovering(10)
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 10)
+			for _, r := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+				So(seen(strs), ShouldContainKey, r)
+			}
+		})
+
+		Convey("Chooses weighted alternatives", func() {
+			generator, err := NewGenerator(`(ab|cd)(ef|gh)`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				AlternateWeight: func(index, total int) int {
+					if index == 0 {
+						return 1000
+					}
+					return 1
+				},
+			})
+			So(err, ShouldBeNil)
+			strs, err := generator.(TestDataGenerator).GenerateCovering(2)
+			So(err, ShouldBeNil)
+			for _, r := range []string{"a", "c", "e", "g"} {
+				So(seen(strs), ShouldContainKey, r)
+			}
+		})
+
+		Convey("Fills the rest at random", func() {
+			generator, err := NewGenerator(`a|b`, nil)
+			So(err, ShouldBeNil)
+			strs, err := generator.(TestDataGenerator).GenerateCovering(10)
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 10)
+			So(strs[:2], ShouldContain, "a")
+			So(strs[:2], ShouldContain, "b")
+		})
+
+		Convey("Chooses every pattern", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a`, `b|c`}, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+			strs, err := generator.(TestDataGenerator).GenerateCovering(3)
+			So(err, ShouldBeNil)
+			So(strs, ShouldContain, "a")
+			So(strs, ShouldContain, "b")
+			So(strs, ShouldContain, "c")
+		})
+
+		Convey("Returns error for negative n", func() {
+			generator, err := NewGenerator(`a`, nil)
+			So(err, ShouldBeNil)
+			_, err = generator.(TestDataGenerator).GenerateCovering(-1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
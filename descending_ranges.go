@@ -0,0 +1,174 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// classEscapes are the escapes for single runes that can be used in character classes, other than punctuation,
+// octal, and hex escapes.
+var classEscapes = map[rune]rune{'a': '\a', 'f': '\f', 't': '\t', 'n': '\n', 'r': '\r', 'v': '\v'}
+
+// ascendRanges returns pattern with each descending range in its character classes (e.g. "z-a") reversed, for
+// GeneratorArgs.AllowDescendingRanges, so the parser accepts it. Range ends can be written as runes or escapes
+// (e.g. "\x7f" or "\]"). Like removeUnsupportedAssertions, it leaves \Q...\E alone, and the whole pattern if flags
+// contains syntax.Literal.
+func ascendRanges(pattern string, flags syntax.Flags) string {
+	if flags&syntax.Literal != 0 || !strings.Contains(pattern, "-") {
+		return pattern
+	}
+
+	var result bytes.Buffer
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '[':
+			i = ascendClassRanges(runes, i, &result) - 1
+
+		case r == '\\' && i+1 < len(runes):
+			if runes[i+1] == 'Q' {
+				// Copy quoted text verbatim up to and including \E.
+				end := indexRunePair(runes, i+2, '\\', 'E')
+				if end < 0 {
+					result.WriteString(string(runes[i:]))
+					i = len(runes)
+					break
+				}
+				result.WriteString(string(runes[i : end+2]))
+				i = end + 1
+				break
+			}
+			result.WriteString(string(runes[i : i+2]))
+			i++
+
+		default:
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+// ascendClassRanges writes the character class starting at runes[start] to result with its descending ranges
+// reversed, and returns the index after the class.
+func ascendClassRanges(runes []rune, start int, result *bytes.Buffer) int {
+	i := start + 1
+	// A ']' at the start of a class (after an optional '^') is a literal.
+	if i < len(runes) && runes[i] == '^' {
+		i++
+	}
+	result.WriteString(string(runes[start:i]))
+	first := true
+
+	for i < len(runes) && (runes[i] != ']' || first) {
+		first = false
+
+		lo, loEnd, loOk := classRune(runes, i)
+		if loEnd+1 < len(runes) && runes[loEnd] == '-' && runes[loEnd+1] != ']' {
+			hi, hiEnd, hiOk := classRune(runes, loEnd+1)
+			if loOk && hiOk && lo > hi {
+				result.WriteString(string(runes[loEnd+1 : hiEnd]))
+				result.WriteRune('-')
+				result.WriteString(string(runes[i:loEnd]))
+			} else {
+				result.WriteString(string(runes[i:hiEnd]))
+			}
+			i = hiEnd
+			continue
+		}
+		result.WriteString(string(runes[i:loEnd]))
+		i = loEnd
+	}
+
+	if i < len(runes) {
+		result.WriteRune(']')
+		i++
+	}
+	return i
+}
+
+// classRune parses the item of a character class at runes[i]. It returns the index after the item, and the rune
+// it stands for, if it's a single rune that can start or end a range, rather than e.g. "\d" or "[:alpha:]".
+func classRune(runes []rune, i int) (rune, int, bool) {
+	r := runes[i]
+	if r == '[' && i+1 < len(runes) && runes[i+1] == ':' {
+		// Named ASCII classes (e.g. [:alpha:]) contain a ']' that doesn't end the class.
+		if end := indexRunePair(runes, i+2, ':', ']'); end >= 0 {
+			return 0, end + 2, false
+		}
+	}
+	if r != '\\' || i+1 >= len(runes) {
+		return r, i + 1, true
+	}
+
+	c := runes[i+1]
+	switch {
+	case c == 'x' && i+2 < len(runes) && runes[i+2] == '{':
+		end := indexRuneFrom(runes, i+3, '}')
+		if end < 0 {
+			return 0, len(runes), false
+		}
+		value, err := strconv.ParseUint(string(runes[i+3:end]), 16, 32)
+		return rune(value), end + 1, err == nil
+
+	case c == 'x' && i+3 < len(runes):
+		value, err := strconv.ParseUint(string(runes[i+2:i+4]), 16, 8)
+		return rune(value), i + 4, err == nil
+
+	case c >= '0' && c <= '7':
+		end := i + 2
+		for end < len(runes) && end < i+4 && runes[end] >= '0' && runes[end] <= '7' {
+			end++
+		}
+		value, err := strconv.ParseUint(string(runes[i+1:end]), 8, 32)
+		return rune(value), end, err == nil
+
+	case c < unicode.MaxASCII && (unicode.IsPunct(c) || unicode.IsSymbol(c)):
+		return c, i + 2, true
+	}
+
+	if escaped, ok := classEscapes[c]; ok {
+		return escaped, i + 2, true
+	}
+	if (c == 'p' || c == 'P') && i+2 < len(runes) {
+		// Skip Unicode classes (e.g. \pL or \p{Greek}), so their names aren't read as the next items.
+		if runes[i+2] == '{' {
+			if end := indexRuneFrom(runes, i+3, '}'); end >= 0 {
+				return 0, end + 1, false
+			}
+		}
+		return 0, i + 3, false
+	}
+	return 0, i + 2, false
+}
+
+// indexRuneFrom returns the index of the first r in runes at or after start, or -1 if there isn't one.
+func indexRuneFrom(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAscendRanges(t *testing.T) {
+	t.Parallel()
+
+	Convey("ascendRanges", t, func() {
+		Convey("Reverses descending ranges", func() {
+			for pattern, expected := range map[string]string{
+				`[z-a]`:             `[a-z]`,
+				`[^9-0_]+`:          `[^0-9_]+`,
+				`x[a-cZ-A]y[f-d]`:   `x[a-cA-Z]y[d-f]`,
+				`[\x{3b1}-\x07]`:    `[\x07-\x{3b1}]`,
+				`[\x7f-\x20\]-\-]`:  `[\x20-\x7f\--\]]`,
+				`[\101-\t]`:         `[\t-\101]`,
+				`[]z-a]`:            `[]a-z]`,
+				`[[:alpha:]9-0]`:    `[[:alpha:]0-9]`,
+				`[\pLz-a\p{Greek}]`: `[\pLa-z\p{Greek}]`,
+				`[\d-a]`:            `[\d-a]`,
+				`[a-][-z]`:          `[a-][-z]`,
+				`z-a\[z-a\]`:        `z-a\[z-a\]`,
+				`\Q[z-a]\E[z-a]`:    `\Q[z-a]\E[a-z]`,
+				`[a-z0-9]`:          `[a-z0-9]`,
+				`(?i)[日-本]`:         `(?i)[日-本]`,
+				`[本-日]`:             `[日-本]`,
+			} {
+				So(ascendRanges(pattern, syntax.Perl), ShouldEqual, expected)
+			}
+		})
+
+		Convey("Ignores literal patterns", func() {
+			So(ascendRanges(`[z-a]`, syntax.Literal), ShouldEqual, `[z-a]`)
+		})
+	})
+}
+
+func TestAllowDescendingRanges(t *testing.T) {
+	t.Parallel()
+
+	Convey("AllowDescendingRanges", t, func() {
+		Convey("Generates from descending ranges", func() {
+			generator, err := NewGenerator(`[z-a]{5}-[9-0]{3}`, &GeneratorArgs{
+				RngSource:             rand.NewSource(0),
+				Flags:                 syntax.Perl,
+				AllowDescendingRanges: true,
+			})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^[a-z]{5}-[0-9]{3}$`)
+			for i := 0; i < SampleSize; i++ {
+				So(matcher.MatchString(generator.Generate()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Is off by default", func() {
+			_, err := NewGenerator(`[z-a]`, nil)
+			So(err, ShouldNotBeNil)
+			So(CanGenerate(`[z-a]`, &GeneratorArgs{AllowDescendingRanges: true}), ShouldBeNil)
+		})
+	})
+}
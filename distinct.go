@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/big"
+)
+
+// distinctAttemptsPerString is the number of strings GenerateDistinct generates for each distinct string requested
+// before giving up.
+const distinctAttemptsPerString = 100
+
+// minDistinctAttempts is the smallest number of strings GenerateDistinct generates before giving up.
+const minDistinctAttempts = 1000
+
+/*
+GenerateDistinct is like GenerateN, but returns n distinct strings, e.g. for unique keys, in the order they were
+first generated. Duplicates are discarded, and generation is retried until n distinct strings have been generated.
+
+An error is returned if the expression can't match n strings (counted like CountMatches, for generators
+created without Overrides or a CaptureGroupHandler), or if n distinct strings still haven't been generated after
+100 attempts per string. If n <= 0, an empty slice is returned.
+*/
+func GenerateDistinct(generator Generator, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	if counter, ok := generator.(matchCounter); ok {
+		if count, ok := counter.matchCount(); ok && count.Cmp(big.NewInt(int64(n))) < 0 {
+			return nil, generatorError(nil, "/%s/ only matches %s strings, fewer than %d", generator, count, n)
+		}
+	}
+
+	maxAttempts := n * distinctAttemptsPerString
+	if maxAttempts < minDistinctAttempts {
+		maxAttempts = minDistinctAttempts
+	}
+
+	results := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for attempts := 0; len(results) < n; attempts++ {
+		if attempts == maxAttempts {
+			return nil, generatorError(nil, "only generated %d distinct strings of %d after %d attempts", len(results),
+				n, attempts)
+		}
+
+		str := generator.Generate()
+		if !seen[str] {
+			seen[str] = true
+			results = append(results, str)
+		}
+	}
+	return results, nil
+}
+
+// matchCounter is implemented by generators that can count the strings they can generate.
+type matchCounter interface {
+	// matchCount returns an upper bound on the number of distinct strings the generator can generate, or false
+	// if it can't be counted.
+	matchCount() (*big.Int, bool)
+}
+
+func (gen *internalGenerator) matchCount() (*big.Int, bool) {
+	// Overrides and capture group handlers can generate anything.
+	if gen.args.Overrides != nil || gen.args.CaptureGroupHandler != nil {
+		return nil, false
+	}
+	count, err := countMatches(gen.regexp, gen.args)
+	return count, err == nil
+}
+
+func (gen *multiPatternGenerator) matchCount() (*big.Int, bool) {
+	total := new(big.Int)
+	for _, generator := range gen.generators {
+		count, ok := generator.matchCount()
+		if !ok {
+			return nil, false
+		}
+		total.Add(total, count)
+	}
+	return total, true
+}
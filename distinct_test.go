@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateDistinct(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateDistinct", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates every string if n is the number of matches", func() {
+			strs, err := GenerateDistinct(newGenerator(`[01]{2}`, &GeneratorArgs{RngSource: rand.NewSource(0)}), 4)
+			So(err, ShouldBeNil)
+			sort.Strings(strs)
+			So(strs, ShouldResemble, []string{"00", "01", "10", "11"})
+		})
+
+		Convey("Generates distinct strings from infinite expressions", func() {
+			strs, err := GenerateDistinct(newGenerator(`[a-z]+`, &GeneratorArgs{RngSource: rand.NewSource(0)}), 100)
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 100)
+			seen := make(map[string]bool)
+			for _, str := range strs {
+				So(seen, ShouldNotContainKey, str)
+				seen[str] = true
+			}
+		})
+
+		Convey("Returns error if there aren't enough matches", func() {
+			_, err := GenerateDistinct(newGenerator(`[01]{2}`, nil), 5)
+			So(err, ShouldNotBeNil)
+
+			multi, err := NewGeneratorFromPatterns([]string{`a|b`, `c`}, nil)
+			So(err, ShouldBeNil)
+			_, err = GenerateDistinct(multi, 4)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Counts matches when it can", func() {
+			count, ok := newGenerator(`[01]{2}`, nil).(matchCounter).matchCount()
+			So(ok, ShouldBeTrue)
+			So(count.Int64(), ShouldEqual, 4)
+
+			_, ok = newGenerator(`a+`, nil).(matchCounter).matchCount()
+			So(ok, ShouldBeFalse)
+
+			_, ok = newGenerator(`([01]{2})`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator,
+					args *GeneratorArgs) string {
+					return "x"
+				},
+			}).(matchCounter).matchCount()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Gives up after a bounded number of attempts if the matches can't be counted", func() {
+			generator := &countingGenerator{Generator: newGenerator(`[01]{2}`, nil)}
+			_, err := GenerateDistinct(generator, 5)
+			So(err, ShouldNotBeNil)
+			So(generator.count, ShouldEqual, minDistinctAttempts)
+		})
+
+		Convey("Returns an empty slice if n <= 0", func() {
+			strs, err := GenerateDistinct(newGenerator(`a`, nil), 0)
+			So(err, ShouldBeNil)
+			So(strs, ShouldNotBeNil)
+			So(strs, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+/*
+EstimateMaxLength returns the maximum length, in bytes, of the strings NewGenerator(pattern, args) can generate,
+without generating anything, e.g. to reject user patterns that could generate megabytes. The returned bool is false
+if the expression contains an unbounded repeat (e.g. "x*", "x+", or "x{2,}"), in which case the length is the
+maximum with unbounded repeats limited to args.MaxUnboundedRepeatCount, as they are by Generate. If args is nil,
+default values are used. An error is returned if NewGenerator would return one.
+
+The length is an upper bound: each character class is assumed to generate its longest rune, and alternations their
+longest alternative. args.CaptureGroupHandler and args.Overrides aren't called, so the length of what they generate
+isn't included, and normalization by args.NormalizeForm isn't either. Lengths too large for an int are returned as
+math.MaxInt.
+*/
+func EstimateMaxLength(pattern string, args *GeneratorArgs) (int, bool, error) {
+	regexp, initializedArgs, err := parsePattern(pattern, args)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := checkGeneratable(regexp, initializedArgs, 1, make(map[*syntax.Regexp]int)); err != nil {
+		return 0, false, err
+	}
+	length, bounded := estimateMaxLength(regexp, initializedArgs)
+	return length, bounded, nil
+}
+
+// estimateMaxLength returns EstimateMaxLength's results for regexp, which was parsed with args.
+func estimateMaxLength(regexp *syntax.Regexp, args *GeneratorArgs) (int, bool) {
+	e := &lengthEstimator{
+		args:     args,
+		groups:   make(map[int]*syntax.Regexp),
+		lengths:  make(map[*syntax.Regexp]int),
+		visiting: make(map[*syntax.Regexp]bool),
+		bounded:  true,
+	}
+	regexp = regexp.Simplify()
+	e.collectGroups(regexp)
+	return e.maxLength(regexp), e.bounded
+}
+
+// lengthEstimator computes the maximum length of the strings generated by an expression, for EstimateMaxLength.
+type lengthEstimator struct {
+	args *GeneratorArgs
+	// The capture groups, indexed like backreferences.
+	groups map[int]*syntax.Regexp
+	// The maximum length, in bytes, of each expression.
+	lengths map[*syntax.Regexp]int
+	// The expressions whose lengths are being computed, to detect backreferences inside their own group.
+	visiting map[*syntax.Regexp]bool
+	// False if the expression contains an unbounded repeat.
+	bounded bool
+}
+
+// collectGroups records the capture groups in regexp, so backreferences can find their lengths.
+func (e *lengthEstimator) collectGroups(regexp *syntax.Regexp) {
+	if regexp.Op == syntax.OpCapture && regexp.Cap > 0 {
+		e.groups[regexp.Cap-1] = regexp
+	}
+	for _, sub := range regexp.Sub {
+		e.collectGroups(sub)
+	}
+}
+
+// maxLength returns the maximum length, in bytes, of the strings regexp can generate.
+func (e *lengthEstimator) maxLength(regexp *syntax.Regexp) int {
+	if length, ok := e.lengths[regexp]; ok {
+		return length
+	}
+	if e.visiting[regexp] {
+		// A backreference inside its own group (e.g. in "(a\1)*") repeats the group's previous output, so it can
+		// keep growing.
+		e.bounded = false
+		return 0
+	}
+	e.visiting[regexp] = true
+	defer delete(e.visiting, regexp)
+
+	length := 0
+	switch regexp.Op {
+	case syntax.OpLiteral:
+		for _, variants := range literalVariants(regexp) {
+			length = addLengths(length, maxRuneLen(variants...))
+		}
+
+	case syntax.OpCharClass:
+		length = e.classRuneLen(maxRuneLen(regexp.Rune...))
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		if e.args.ByteMode {
+			length = 1
+		} else {
+			length = e.classRuneLen(utf8.UTFMax)
+		}
+
+	case syntax.OpCapture:
+		if index, ok := backreferenceGroup(regexp); ok && e.args.hasBackreferences {
+			if group, ok := e.groups[index]; ok {
+				length = e.maxLength(group)
+			}
+		} else {
+			length = e.maxLength(regexp.Sub[0])
+		}
+
+	case syntax.OpConcat:
+		for _, sub := range regexp.Sub {
+			length = addLengths(length, e.maxLength(sub))
+		}
+
+	case syntax.OpAlternate:
+		for _, sub := range regexp.Sub {
+			length = maxInt(length, e.maxLength(sub))
+		}
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		subLength := e.maxLength(regexp.Sub[0])
+		unbounded := regexp.Op == syntax.OpStar || regexp.Op == syntax.OpPlus ||
+			regexp.Op == syntax.OpRepeat && regexp.Max == noBound
+		if unbounded && subLength > 0 {
+			e.bounded = false
+		}
+		_, max := repeatBounds(regexp, e.args)
+		length = multiplyLengths(max, subLength)
+	}
+
+	e.lengths[regexp] = length
+	return length
+}
+
+// classRuneLen returns the maximum length of a rune generated by a character class whose longest rune has
+// length n, taking the options that restrict or replace the runes of classes into account.
+func (e *lengthEstimator) classRuneLen(n int) int {
+	if e.args.ASCIIOnly {
+		return 1
+	}
+	if len(e.args.AllowRunes) > 0 {
+		if allowed := maxRuneLen(e.args.AllowRunes...); allowed < n {
+			n = allowed
+		}
+	}
+	if e.args.DigitScript != ASCIIDigits {
+		n = maxInt(n, utf8.RuneLen(rune(e.args.DigitScript)+9))
+	}
+	return n
+}
+
+// maxRuneLen returns the length of the longest UTF-8 encoding of runes. Invalid runes are encoded as
+// utf8.RuneError.
+func maxRuneLen(runes ...rune) int {
+	length := 0
+	for _, r := range runes {
+		n := utf8.RuneLen(r)
+		if n < 0 {
+			n = utf8.RuneLen(utf8.RuneError)
+		}
+		length = maxInt(length, n)
+	}
+	return length
+}
+
+// addLengths returns a + b, or math.MaxInt if it would overflow.
+func addLengths(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// multiplyLengths returns a * b, or math.MaxInt if it would overflow.
+func multiplyLengths(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
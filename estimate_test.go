@@ -0,0 +1,122 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEstimateMaxLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("EstimateMaxLength", t, func() {
+		estimate := func(pattern string, args *GeneratorArgs) (int, bool) {
+			length, bounded, err := EstimateMaxLength(pattern, args)
+			So(err, ShouldBeNil)
+			return length, bounded
+		}
+
+		Convey("Limits unbounded repeats like Generate", func() {
+			length, bounded := estimate(`a{2,5}b+`, nil)
+			So(length, ShouldEqual, 5+DefaultMaxUnboundedRepeatCount)
+			So(bounded, ShouldBeFalse)
+
+			length, bounded = estimate(`a{2,5}b+`, &GeneratorArgs{MaxUnboundedRepeatCount: 10})
+			So(length, ShouldEqual, 15)
+			So(bounded, ShouldBeFalse)
+
+			length, bounded = estimate(`a{3,}`, &GeneratorArgs{MaxUnboundedRepeatCount: 10})
+			So(length, ShouldEqual, 12)
+			So(bounded, ShouldBeFalse)
+
+			length, _ = estimate(`(a*)*`, &GeneratorArgs{MaxUnboundedRepeatCount: 10})
+			So(length, ShouldEqual, 100)
+		})
+
+		Convey("Bounded expressions", func() {
+			length, bounded := estimate(`a{2,5}`, nil)
+			So(length, ShouldEqual, 5)
+			So(bounded, ShouldBeTrue)
+
+			length, bounded = estimate(`(ab|cde){3}x?`, nil)
+			So(length, ShouldEqual, 10)
+			So(bounded, ShouldBeTrue)
+
+			// Repeating something that can only be empty doesn't make the expression unbounded.
+			length, bounded = estimate(`a(?:^)*`, &GeneratorArgs{Flags: syntax.Perl})
+			So(length, ShouldEqual, 1)
+			So(bounded, ShouldBeTrue)
+		})
+
+		Convey("Counts bytes", func() {
+			length, _ := estimate(`é{2}[a-zé]`, nil)
+			So(length, ShouldEqual, 6)
+
+			length, _ = estimate(`.`, nil)
+			So(length, ShouldEqual, 4)
+			length, _ = estimate(`.`, &GeneratorArgs{ByteMode: true})
+			So(length, ShouldEqual, 1)
+			length, _ = estimate(`.[^a]`, &GeneratorArgs{ASCIIOnly: true})
+			So(length, ShouldEqual, 2)
+			length, _ = estimate(`\d`, &GeneratorArgs{Flags: syntax.Perl, DigitScript: DevanagariDigits})
+			So(length, ShouldEqual, 3)
+
+			// The Kelvin sign is a case variant of k.
+			length, _ = estimate(`(?i)k`, &GeneratorArgs{Flags: syntax.Perl})
+			So(length, ShouldEqual, 3)
+		})
+
+		Convey("Agrees with GenerateLongest", func() {
+			for _, pattern := range []string{`a{2,5}b{0,7}`, `(foo|ba+r){2,3}`, `x(y{3}|z{4}){2}`} {
+				args := &GeneratorArgs{Flags: syntax.Perl, MaxUnboundedRepeatCount: 6}
+				length, _ := estimate(pattern, args)
+				generator, err := NewGenerator(pattern, args)
+				So(err, ShouldBeNil)
+				longest, err := generator.(LengthGenerator).GenerateLongest()
+				So(err, ShouldBeNil)
+				So(length, ShouldEqual, len(longest))
+			}
+		})
+
+		Convey("Backreferences", func() {
+			length, bounded := estimate(`(ab|c)-\1`, &GeneratorArgs{Flags: syntax.Perl})
+			So(length, ShouldEqual, 5)
+			So(bounded, ShouldBeTrue)
+
+			_, bounded = estimate(`(a\1)*`, &GeneratorArgs{Flags: syntax.Perl})
+			So(bounded, ShouldBeFalse)
+		})
+
+		Convey("Saturates instead of overflowing", func() {
+			length, bounded := estimate(`((((((a*)*)*)*)*)*)*`, nil)
+			So(length, ShouldEqual, math.MaxInt)
+			So(bounded, ShouldBeFalse)
+		})
+
+		Convey("Returns an error if NewGenerator would", func() {
+			_, _, err := EstimateMaxLength(`(`, nil)
+			So(err, ShouldNotBeNil)
+
+			_, _, err = EstimateMaxLength(`[a-z]`, &GeneratorArgs{AllowRunes: []rune("0")})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
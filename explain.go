@@ -0,0 +1,64 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+)
+
+// Decision is a single random choice made while generating a string. See GenerateExplained.
+type Decision struct {
+	// The op of the expression that made the choice.
+	Op syntax.Op
+	// The expression that made the choice.
+	Expr string
+	// The value chosen:
+	//  - for alternations, the index of the alternative.
+	//  - for repeats, the number of repetitions.
+	//  - for character classes and ".", the index of the rune in the class, or the rune itself for "." with
+	//    RawAnyChar, or the index of the byte for "." in ByteMode.
+	//  - for case-insensitive literals, the index of the case variant of each rune (0 is the rune itself).
+	Value int
+}
+
+// GenerateExplained is like generator.Generate, but also returns each random choice made to generate the string,
+// in the order they were made, e.g. to debug why a generated string looks the way it does.
+// It returns ErrUnsupportedGenerator if generator wasn't created by this package.
+func GenerateExplained(generator Generator) (string, []Decision, error) {
+	gen, ok := generator.(extendedGenerator)
+	if !ok {
+		return "", nil, ErrUnsupportedGenerator
+	}
+	str, decisions := gen.generateExplained()
+	return str, decisions, nil
+}
+
+func (gen *internalGenerator) generateExplained() (string, []Decision) {
+	var buffer bytes.Buffer
+	state := gen.newState(&buffer, nil)
+	state.explain = true
+	gen.generate(state)
+	return buffer.String(), state.decisions
+}
+
+// decide records that regexp chose value, if decisions are being recorded.
+func (state *generatorState) decide(regexp *syntax.Regexp, value int) {
+	if state.explain {
+		state.decisions = append(state.decisions, Decision{regexp.Op, regexp.String(), value})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateExplained(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateExplained", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+		generateExplained := func(generator Generator) (string, []Decision) {
+			str, decisions, err := GenerateExplained(generator)
+			So(err, ShouldBeNil)
+			return str, decisions
+		}
+
+		Convey("Records each choice", func() {
+			generator := newGenerator(`(?:foo|bar)[a-c]{2}x?`, &GeneratorArgs{Flags: syntax.Perl, Deterministic: true})
+			str, decisions := generateExplained(generator)
+			So(str, ShouldEqual, "fooaa")
+			So(decisions, ShouldResemble, []Decision{
+				{syntax.OpAlternate, `foo|bar`, 0},
+				{syntax.OpCharClass, `[a-c]`, 0},
+				{syntax.OpCharClass, `[a-c]`, 0},
+				{syntax.OpQuest, `x?`, 0},
+			})
+		})
+
+		Convey("Is stable with the same seed", func() {
+			explain := func() (string, []Decision) {
+				args := &GeneratorArgs{Flags: syntax.Perl, RngSource: rand.NewSource(0)}
+				return generateExplained(newGenerator(`(?i:ab|cd)+\d`, args))
+			}
+			str, decisions := explain()
+			otherStr, otherDecisions := explain()
+			So(otherStr, ShouldEqual, str)
+			So(otherDecisions, ShouldResemble, decisions)
+
+			// The repeat count, then for each repetition an alternative and a case variant for each of its runes,
+			// and finally a digit.
+			So(decisions[0].Op, ShouldEqual, syntax.OpPlus)
+			So(decisions, ShouldHaveLength, 1+3*decisions[0].Value+1)
+			So(len(str), ShouldEqual, 2*decisions[0].Value+1)
+			So(decisions[len(decisions)-1].Op, ShouldEqual, syntax.OpCharClass)
+		})
+
+		Convey("Doesn't record anything for literals", func() {
+			str, decisions := generateExplained(newGenerator(`abc`, nil))
+			So(str, ShouldEqual, "abc")
+			So(decisions, ShouldBeEmpty)
+		})
+
+		Convey("Records the pattern chosen by multiple patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a`, `b[xy]`}, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize/10; i++ {
+				str, decisions := generateExplained(generator)
+				So(decisions[0].Op, ShouldEqual, syntax.OpAlternate)
+				if decisions[0].Value == 0 {
+					So(str, ShouldEqual, "a")
+					So(decisions, ShouldHaveLength, 1)
+				} else {
+					So(str[0], ShouldEqual, 'b')
+					So(decisions, ShouldHaveLength, 2)
+				}
+			}
+		})
+
+		Convey("Returns an error for other generators", func() {
+			_, _, err := GenerateExplained(&countingGenerator{Generator: newGenerator(`abc`, nil)})
+			So(err, ShouldEqual, ErrUnsupportedGenerator)
+		})
+	})
+}
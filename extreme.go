@@ -0,0 +1,247 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// extremeGenerator generates the shortest or longest string matched by an expression, for GenerateShortest and
+// GenerateLongest.
+type extremeGenerator struct {
+	args *GeneratorArgs
+	// If true, generate the longest string instead of the shortest.
+	longest bool
+	buffer  bytes.Buffer
+
+	// The minimum (or maximum, if longest is set) length, in runes, each expression can generate.
+	lengths map[*syntax.Regexp]int
+	// The capture groups, indexed like backreferences, and their output so far.
+	groups       []*syntax.Regexp
+	groupOutputs []string
+}
+
+func (gen *internalGenerator) GenerateShortest() (string, error) {
+	return gen.generateExtreme(false)
+}
+
+func (gen *internalGenerator) GenerateLongest() (string, error) {
+	return gen.generateExtreme(true)
+}
+
+func (gen *internalGenerator) generateExtreme(longest bool) (string, error) {
+	g := &extremeGenerator{
+		args:         gen.args,
+		longest:      longest,
+		lengths:      make(map[*syntax.Regexp]int),
+		groups:       make([]*syntax.Regexp, gen.args.numCaptureGroups),
+		groupOutputs: make([]string, gen.args.numCaptureGroups),
+	}
+	g.collectGroups(gen.regexp)
+
+	// Every rune is at least one byte, so fail fast instead of generating a string that's already known to be
+	// too long.
+	if gen.args.MaxTotalLength > 0 && g.length(gen.regexp) > gen.args.MaxTotalLength {
+		return "", ErrMaxTotalLengthExceeded
+	}
+	if err := g.generate(gen.regexp); err != nil {
+		return "", err
+	}
+	return g.buffer.String(), nil
+}
+
+// collectGroups records the capture groups in regexp, so backreferences can find their lengths.
+func (g *extremeGenerator) collectGroups(regexp *syntax.Regexp) {
+	if regexp.Op == syntax.OpCapture && regexp.Cap > 0 && regexp.Cap <= len(g.groups) {
+		g.groups[regexp.Cap-1] = regexp
+	}
+	for _, sub := range regexp.Sub {
+		g.collectGroups(sub)
+	}
+}
+
+// length returns the length of the shortest (or longest) string regexp can generate.
+func (g *extremeGenerator) length(regexp *syntax.Regexp) int {
+	if length, ok := g.lengths[regexp]; ok {
+		return length
+	}
+
+	length := 0
+	switch regexp.Op {
+	case syntax.OpLiteral:
+		length = len(regexp.Rune)
+
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		length = 1
+
+	case syntax.OpCapture:
+		if index, ok := backreferenceGroup(regexp); ok {
+			// Assume the group was generated before the backreference, which is usually the case.
+			if index < len(g.groups) && g.groups[index] != nil {
+				length = g.length(g.groups[index])
+			}
+		} else if len(regexp.Sub) == 1 {
+			length = g.length(regexp.Sub[0])
+		}
+
+	case syntax.OpConcat:
+		for _, sub := range regexp.Sub {
+			length += g.length(sub)
+		}
+
+	case syntax.OpAlternate:
+		length = g.length(regexp.Sub[g.alternative(regexp)])
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		if count := g.repeatCount(regexp); count > 0 && len(regexp.Sub) == 1 {
+			length = count * g.length(regexp.Sub[0])
+		}
+	}
+
+	g.lengths[regexp] = length
+	return length
+}
+
+// alternative returns the index of the alternative of regexp with the shortest (or longest) length, or the
+// first one if more than one has it.
+func (g *extremeGenerator) alternative(regexp *syntax.Regexp) int {
+	best := 0
+	for i := 1; i < len(regexp.Sub); i++ {
+		length, bestLength := g.length(regexp.Sub[i]), g.length(regexp.Sub[best])
+		if g.longest && length > bestLength || !g.longest && length < bestLength {
+			best = i
+		}
+	}
+	return best
+}
+
+// repeatCount returns the number of times the repeat expression regexp repeats its sub-expression: the minimum,
+// or the maximum (limited by MaxUnboundedRepeatCount) if longest is set.
+func (g *extremeGenerator) repeatCount(regexp *syntax.Regexp) int {
+	min, max := repeatBounds(regexp, g.args)
+	if g.longest {
+		return max
+	}
+	return min
+}
+
+// grow returns ErrMaxTotalLengthExceeded if writing n more bytes to g.buffer would exceed MaxTotalLength.
+func (g *extremeGenerator) grow(n int) error {
+	if g.args.MaxTotalLength > 0 && g.buffer.Len()+n > g.args.MaxTotalLength {
+		return ErrMaxTotalLengthExceeded
+	}
+	return nil
+}
+
+// writeRune writes r to g.buffer, unless that would exceed MaxTotalLength.
+func (g *extremeGenerator) writeRune(r rune) error {
+	if err := g.grow(utf8.RuneLen(r)); err != nil {
+		return err
+	}
+	g.buffer.WriteRune(r)
+	return nil
+}
+
+// generate writes the shortest (or longest) string generated by regexp to g.buffer.
+func (g *extremeGenerator) generate(regexp *syntax.Regexp) error {
+	switch regexp.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return nil
+
+	case syntax.OpLiteral:
+		for _, r := range regexp.Rune {
+			if err := g.writeRune(r); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		if g.args.ByteMode {
+			values, err := anyByteValues(regexp, regexp.Op == syntax.OpAnyCharNotNL, g.args)
+			if err != nil {
+				return err
+			}
+			if err := g.grow(1); err != nil {
+				return err
+			}
+			return g.buffer.WriteByte(values[0])
+		}
+		fallthrough
+
+	case syntax.OpCharClass:
+		charClass, err := finiteCharClass(regexp, g.args)
+		if err != nil {
+			return err
+		}
+		if charClass.TotalSize == 0 {
+			return generatorError(nil, "character class %s doesn't generate any runes", regexp)
+		}
+		return g.writeRune(charClass.GetRuneAt(0))
+
+	case syntax.OpCapture:
+		if index, ok := backreferenceGroup(regexp); ok {
+			if index >= len(g.groupOutputs) {
+				return generatorError(nil, "invalid backreference to group %d", index+1)
+			}
+			if err := g.grow(len(g.groupOutputs[index])); err != nil {
+				return err
+			}
+			g.buffer.WriteString(g.groupOutputs[index])
+			return nil
+		}
+		if err := enforceSingleSub(regexp); err != nil {
+			return err
+		}
+		start := g.buffer.Len()
+		if err := g.generate(regexp.Sub[0]); err != nil {
+			return err
+		}
+		if regexp.Cap > 0 && regexp.Cap <= len(g.groupOutputs) {
+			g.groupOutputs[regexp.Cap-1] = string(g.buffer.Bytes()[start:])
+		}
+		return nil
+
+	case syntax.OpConcat:
+		for _, sub := range regexp.Sub {
+			if err := g.generate(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case syntax.OpAlternate:
+		return g.generate(regexp.Sub[g.alternative(regexp)])
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		if err := enforceSingleSub(regexp); err != nil {
+			return err
+		}
+		count := g.repeatCount(regexp)
+		for i := 0; i < count; i++ {
+			if err := g.generate(regexp.Sub[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &UnsupportedOpError{regexp.Op, regexp}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateShortest(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateShortest", t, func() {
+		ConveyGeneratesShortest := func(pattern, expected string, args *GeneratorArgs) {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateShortest()
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, expected)
+		}
+
+		Convey("Generates the shortest string", func() {
+			ConveyGeneratesShortest(`(abc|x)+`, "x", nil)
+			ConveyGeneratesShortest(`a{3,7}`, "aaa", nil)
+			ConveyGeneratesShortest(`foo|b?ar|qux`, "ar", nil)
+			ConveyGeneratesShortest(`a*b+c?`, "b", nil)
+			ConveyGeneratesShortest(`^(ab|cd)$`, "ab", nil)
+			ConveyGeneratesShortest(`[x-z]{2}`, "xx", nil)
+			ConveyGeneratesShortest(`\d-\w`, "0-0", &GeneratorArgs{Flags: syntax.Perl})
+			ConveyGeneratesShortest(`(x|)yz`, "yz", nil)
+			ConveyGeneratesShortest(``, "", nil)
+		})
+
+		Convey("Counts runes", func() {
+			ConveyGeneratesShortest(`ééé|abcd`, "ééé", nil)
+		})
+
+		Convey("Generates backreferences", func() {
+			ConveyGeneratesShortest(`(a+|bc)-\1`, "a-a", &GeneratorArgs{Flags: syntax.Perl})
+		})
+
+		Convey("Respects options", func() {
+			ConveyGeneratesShortest(`x*`, "xx", &GeneratorArgs{MinUnboundedRepeatCount: 2})
+			ConveyGeneratesShortest(`[a-c]`, "b", &GeneratorArgs{ExcludeRunes: []rune("a")})
+			ConveyGeneratesShortest(`.`, "\x01", nil)
+			ConveyGeneratesShortest(`.`, " ", &GeneratorArgs{PrintableOnly: true})
+			ConveyGeneratesShortest(`.`, "\x00", &GeneratorArgs{ByteMode: true})
+		})
+
+		Convey("Works with multiple patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a{3}`, `b+`, `c`}, nil)
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateShortest()
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "b")
+		})
+	})
+}
+
+func TestGenerateLongest(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateLongest", t, func() {
+		ConveyGeneratesLongest := func(pattern, expected string, args *GeneratorArgs) {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, expected)
+		}
+
+		Convey("Generates the longest string", func() {
+			ConveyGeneratesLongest(`(a|bbbb)+`, "bbbbbbbbbbbb", &GeneratorArgs{MaxUnboundedRepeatCount: 3})
+			ConveyGeneratesLongest(`a{3,7}`, "aaaaaaa", nil)
+			ConveyGeneratesLongest(`fo|b?ar|qux`, "bar", nil)
+			ConveyGeneratesLongest(`a*b?c{2}`, "aabcc", &GeneratorArgs{MaxUnboundedRepeatCount: 2})
+			ConveyGeneratesLongest(`x{2,}`, "xxxx", &GeneratorArgs{MaxUnboundedRepeatCount: 3})
+			ConveyGeneratesLongest(`[x-z]{2}`, "xx", nil)
+			ConveyGeneratesLongest(``, "", nil)
+		})
+
+		Convey("Uses the default limit for unbounded repeats", func() {
+			generator, err := NewGenerator(`a*`, nil)
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, DefaultMaxUnboundedRepeatCount)
+		})
+
+		Convey("Generates backreferences", func() {
+			ConveyGeneratesLongest(`(a|bc)-\1`, "bc-bc", &GeneratorArgs{Flags: syntax.Perl})
+		})
+
+		Convey("Respects MaxTotalLength", func() {
+			generator, err := NewGenerator(`((a*)*){3}`, &GeneratorArgs{MaxTotalLength: 100})
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+			So(str, ShouldBeEmpty)
+
+			generator, err = NewGenerator(`(a|bc)-\1`, &GeneratorArgs{Flags: syntax.Perl, MaxTotalLength: 4})
+			So(err, ShouldBeNil)
+			_, err = generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+
+			ConveyGeneratesLongest(`a{3}é`, "aaaé", &GeneratorArgs{MaxTotalLength: 5})
+			generator, err = NewGenerator(`a{3}é`, &GeneratorArgs{MaxTotalLength: 4})
+			So(err, ShouldBeNil)
+			_, err = generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+		})
+
+		Convey("Works with multiple patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a{3}`, `b{2,4}`, `c`}, nil)
+			So(err, ShouldBeNil)
+			str, err := generator.(LengthGenerator).GenerateLongest()
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "bbbb")
+		})
+	})
+}
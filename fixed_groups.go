@@ -0,0 +1,118 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+)
+
+func (gen *internalGenerator) GenerateWithFixed(fixed map[int]string) (string, error) {
+	values, err := gen.fixedGroupValues(fixed, true)
+	if err != nil {
+		return "", err
+	}
+	return gen.generateWithFixedGroups(values)
+}
+
+// GenerateWithHoles is like CaptureGenerator.GenerateWithFixed, but the values don't have to match their groups, so
+// they can be placeholders for another system to fill in, e.g. "{{domain}}". The result usually doesn't match the
+// expression. It returns ErrUnsupportedGenerator if generator wasn't created by this package.
+func GenerateWithHoles(generator Generator, holes map[int]string) (string, error) {
+	gen, ok := generator.(extendedGenerator)
+	if !ok {
+		return "", ErrUnsupportedGenerator
+	}
+	return gen.generateWithHoles(holes)
+}
+
+func (gen *internalGenerator) generateWithHoles(holes map[int]string) (string, error) {
+	values, err := gen.fixedGroupValues(holes, false)
+	if err != nil {
+		return "", err
+	}
+	return gen.generateWithFixedGroups(values)
+}
+
+// generateWithFixedGroups generates a string with the capture groups in values, keyed by 0-based group index,
+// generating their values instead of their expressions.
+func (gen *internalGenerator) generateWithFixedGroups(values map[int]string) (string, error) {
+	var buffer bytes.Buffer
+	state := gen.newState(&buffer, nil)
+	state.fixedGroups = values
+	err := gen.generate(state)
+	return buffer.String(), err
+}
+
+func (gen *internalGenerator) GenerateWithNamed(fixed map[string]string) (string, error) {
+	numbered := make(map[int]string, len(fixed))
+	for name, value := range fixed {
+		found := false
+		for n, groupName := range gen.args.captureNames {
+			if name != "" && groupName == name {
+				numbered[n] = value
+				found = true
+			}
+		}
+		if !found {
+			return "", generatorError(nil, "no capture group named %q in /%s/", name, gen)
+		}
+	}
+	return gen.GenerateWithFixed(numbered)
+}
+
+// fixedGroupValues returns the values in fixed keyed by 0-based group index, like state.captureGroups, or an error
+// if there is no group for a key or, if validate is true, a value doesn't match its group.
+func (gen *internalGenerator) fixedGroupValues(fixed map[int]string, validate bool) (map[int]string, error) {
+	groups := make(map[int]*syntax.Regexp)
+	collectCaptureGroups(gen.regexp, groups)
+
+	values := make(map[int]string, len(fixed))
+	for n, value := range fixed {
+		group, ok := groups[n]
+		if !ok {
+			return nil, generatorError(nil, "no capture group %d in /%s/", n, gen)
+		}
+		if !validate {
+			values[n-1] = value
+			continue
+		}
+
+		// group.String() includes any flags needed to parse it the same way again.
+		validator, err := regexp.Compile(`\A(?:` + group.Sub[0].String() + `)\z`)
+		if err != nil {
+			return nil, generatorError(err, "failed to compile capture group %d /%s/ for validation", n, group)
+		}
+		if !validator.MatchString(value) {
+			return nil, generatorError(nil, "value %q for capture group %d doesn't match /%s/", value, n, group)
+		}
+		values[n-1] = value
+	}
+	return values, nil
+}
+
+// collectCaptureGroups adds the capture groups in regexp to groups, keyed by group number. Backreference
+// placeholders are numbered 0, so they're ignored.
+func collectCaptureGroups(regexp *syntax.Regexp, groups map[int]*syntax.Regexp) {
+	if regexp.Op == syntax.OpCapture && regexp.Cap > 0 && len(regexp.Sub) == 1 {
+		groups[regexp.Cap] = regexp
+	}
+	for _, sub := range regexp.Sub {
+		collectCaptureGroups(sub, groups)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateWithFixed(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithFixed", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates fixed groups verbatim", func() {
+			generator := newGenerator(`(\d{3})-(\d{4})`, &GeneratorArgs{Flags: syntax.Perl})
+			matcher := regexp.MustCompile(`^555-\d{4}$`)
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize/10; i++ {
+				str, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{1: "555"})
+				So(err, ShouldBeNil)
+				So(matcher.MatchString(str), ShouldBeTrue)
+				seen[str] = true
+			}
+			So(len(seen), ShouldBeGreaterThan, 1)
+
+			str, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{1: "555", 2: "0123"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "555-0123")
+		})
+
+		Convey("Fixes nested and repeated groups", func() {
+			generator := newGenerator(`((a|b)c){3}`, nil)
+			str, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{2: "b"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "bcbcbc")
+
+			str, err = generator.(CaptureGenerator).GenerateWithFixed(map[int]string{1: "ac"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "acacac")
+		})
+
+		Convey("Repeats fixed groups in backreferences", func() {
+			str, err := newGenerator(`([a-z]+)=\1`, nil).(CaptureGenerator).GenerateWithFixed(map[int]string{1: "key"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "key=key")
+		})
+
+		Convey("Fixes groups with a CaptureGroupHandler", func() {
+			generator := newGenerator(`(x)(y)`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return strings.ToUpper(generator.Generate())
+				},
+			})
+			str, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{2: "y"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "Xy")
+		})
+
+		Convey("Returns error for values that don't match", func() {
+			generator := newGenerator(`(\d{3})-(\d{4})`, &GeneratorArgs{Flags: syntax.Perl})
+			for _, value := range []string{"55", "5555", "abc", ""} {
+				_, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{1: value})
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Returns error for groups that don't exist", func() {
+			generator := newGenerator(`(a)b`, nil)
+			for _, n := range []int{0, 2, -1} {
+				_, err := generator.(CaptureGenerator).GenerateWithFixed(map[int]string{n: "b"})
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestGenerateWithNamed(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithNamed", t, func() {
+		generator, err := NewGenerator(`(?P<year>\d{4})-(?P<month>\d{2})-(\d{2})`, &GeneratorArgs{Flags: syntax.Perl})
+		So(err, ShouldBeNil)
+
+		Convey("Generates fixed groups verbatim", func() {
+			matcher := regexp.MustCompile(`^2024-\d{2}-\d{2}$`)
+			for i := 0; i < SampleSize/10; i++ {
+				str, err := generator.(CaptureGenerator).GenerateWithNamed(map[string]string{"year": "2024"})
+				So(err, ShouldBeNil)
+				So(matcher.MatchString(str), ShouldBeTrue)
+			}
+
+			str, err := generator.(CaptureGenerator).GenerateWithNamed(map[string]string{"year": "1999", "month": "12"})
+			So(err, ShouldBeNil)
+			So(str, ShouldStartWith, "1999-12-")
+		})
+
+		Convey("Fixes every group with the same name", func() {
+			generator, err := NewGenerator(`(?P<x>[a-z])(?P<x>[a-z])`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			str, err := generator.(CaptureGenerator).GenerateWithNamed(map[string]string{"x": "q"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "qq")
+		})
+
+		Convey("Returns error for values that don't match", func() {
+			_, err := generator.(CaptureGenerator).GenerateWithNamed(map[string]string{"year": "99"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Returns error for names that don't exist", func() {
+			for _, name := range []string{"day", ""} {
+				_, err := generator.(CaptureGenerator).GenerateWithNamed(map[string]string{name: "01"})
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestGenerateWithHoles(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithHoles", t, func() {
+		Convey("Generates placeholders for holes", func() {
+			generator, err := NewGenerator(`(\w+)@(\w+)`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^\w+@\{\{domain\}\}$`)
+
+			for i := 0; i < SampleSize; i++ {
+				str, err := GenerateWithHoles(generator, map[int]string{2: "{{domain}}"})
+				So(err, ShouldBeNil)
+				So(matcher.MatchString(str), ShouldBeTrue)
+			}
+		})
+
+		Convey("Repeats holes for backreferences", func() {
+			generator, err := NewGenerator(`(a+)-\1`, nil)
+			So(err, ShouldBeNil)
+			str, err := GenerateWithHoles(generator, map[int]string{1: "{{x}}"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "{{x}}-{{x}}")
+		})
+
+		Convey("Returns error for missing groups", func() {
+			generator, err := NewGenerator(`(a)`, nil)
+			So(err, ShouldBeNil)
+			_, err = GenerateWithHoles(generator, map[int]string{2: "{{x}}"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Uses the groups of the chosen pattern", func() {
+			multi, err := NewGeneratorFromPatterns([]string{`(a)b`}, nil)
+			So(err, ShouldBeNil)
+			str, err := GenerateWithHoles(multi, map[int]string{1: "_"})
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "_b")
+		})
+
+		Convey("Returns an error for other generators", func() {
+			generator, err := NewGenerator(`(a)`, nil)
+			So(err, ShouldBeNil)
+			_, err = GenerateWithHoles(&countingGenerator{Generator: generator}, map[int]string{1: "_"})
+			So(err, ShouldEqual, ErrUnsupportedGenerator)
+		})
+	})
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"math/big"
+	"regexp/syntax"
+)
+
+// DefaultMaxGenerateAllCount is the default value for MaxGenerateAllCount.
+const DefaultMaxGenerateAllCount = 10000
+
+// ErrInfiniteMatches is returned by GenerateAll and CountMatches if the expression matches an unlimited number of strings.
+var ErrInfiniteMatches = errors.New("expression matches an infinite number of strings")
+
+/*
+GenerateAll returns every string that matches the regular expression pattern, in the order they appear in
+the expression. If args is nil, default values are used.
+
+ErrInfiniteMatches is returned if the expression can match an unlimited number of strings (i.e. it contains "*", "+",
+or "{n,}"). An error is also returned if it would generate more than GeneratorArgs.MaxGenerateAllCount strings,
+or if it contains backreferences.
+
+Options that only affect how strings are chosen at random (e.g. AlternateWeight and CaptureGroupHandler) are ignored.
+"." generates every valid rune, so it can only be used with ASCIIOnly.
+*/
+func GenerateAll(pattern string, inputArgs *GeneratorArgs) ([]string, error) {
+	regexp, args, err := parseFinite(pattern, inputArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	strs, err := generateAll(regexp, args)
+	if err != nil {
+		return nil, err
+	}
+	return removeDuplicates(strs), nil
+}
+
+/*
+CountMatches returns the number of strings that GenerateAll would generate for pattern, without generating them
+or limiting the count. If args is nil, default values are used.
+
+ErrInfiniteMatches is returned if the expression can match an unlimited number of strings.
+
+The count assumes that different alternatives and repeat counts never generate the same string. That's true
+for most expressions (the parser merges simple overlapping alternatives like "a|a"), but not all: e.g. "(a|ab)(b|)"
+is counted as 4 strings, although it only matches 3. So the count is an upper bound.
+*/
+func CountMatches(pattern string, inputArgs *GeneratorArgs) (*big.Int, error) {
+	regexp, args, err := parseFinite(pattern, inputArgs)
+	if err != nil {
+		return nil, err
+	}
+	return countMatches(regexp, args)
+}
+
+// parseFinite parses and simplifies pattern for GenerateAll and CountMatches, and initializes a copy of inputArgs.
+func parseFinite(pattern string, inputArgs *GeneratorArgs) (*syntax.Regexp, *GeneratorArgs, error) {
+	regexp, args, err := parsePattern(pattern, inputArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if args.hasBackreferences {
+		return nil, nil, generatorError(nil, "backreferences are not supported: /%s/", pattern)
+	}
+	return regexp.Simplify(), args, nil
+}
+
+// finiteCharClass returns the runes generated by regexp, which must be a character class or any char.
+func finiteCharClass(regexp *syntax.Regexp, args *GeneratorArgs) (*tCharClass, error) {
+	var charClass *tCharClass
+	switch {
+	case regexp.Op == syntax.OpCharClass:
+		charClass = parseCharClass(regexp.Rune)
+	case args.ByteMode:
+		return nil, generatorError(nil, "ByteMode is not supported: /%s/", regexp)
+	case args.PrintableOnly:
+		charClass = getPrintableCharClass()
+	case regexp.Op == syntax.OpAnyCharNotNL:
+		charClass = anyCharNotNLClass
+	default:
+		charClass = anyCharClass
+	}
+
+	if args.ASCIIOnly {
+		charClass = charClass.intersect(asciiClass)
+	}
+	if len(args.AllowRunes) > 0 {
+		charClass = charClass.intersect(newCharClassFromRunes(args.AllowRunes))
+	}
+	if len(args.ExcludeRunes) > 0 {
+		charClass = charClass.subtract(newCharClassFromRunes(args.ExcludeRunes))
+	}
+	return charClass, nil
+}
+
+// generateAll returns every string matched by regexp, which must be simplified.
+// The result may contain duplicates.
+func generateAll(regexp *syntax.Regexp, args *GeneratorArgs) ([]string, error) {
+	switch regexp.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, nil
+
+	case syntax.OpLiteral:
+		strs := []string{""}
+		for _, runes := range literalVariants(regexp) {
+			var err error
+			if strs, err = generateAllProduct(regexp, strs, runesToStrings(runes), args); err != nil {
+				return nil, err
+			}
+		}
+		return strs, nil
+
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return generateAllCharClass(regexp, args)
+
+	case syntax.OpCapture:
+		if err := enforceSingleSub(regexp); err != nil {
+			return nil, err
+		}
+		return generateAll(regexp.Sub[0], args)
+
+	case syntax.OpQuest:
+		return generateAllRepeat(regexp, 0, 1, args)
+
+	case syntax.OpRepeat:
+		if regexp.Max < 0 {
+			return nil, ErrInfiniteMatches
+		}
+		return generateAllRepeat(regexp, regexp.Min, regexp.Max, args)
+
+	case syntax.OpStar, syntax.OpPlus:
+		return nil, ErrInfiniteMatches
+
+	case syntax.OpConcat:
+		strs := []string{""}
+		for _, sub := range regexp.Sub {
+			subStrs, err := generateAll(sub, args)
+			if err != nil {
+				return nil, err
+			}
+			if strs, err = generateAllProduct(regexp, strs, subStrs, args); err != nil {
+				return nil, err
+			}
+		}
+		return strs, nil
+
+	case syntax.OpAlternate:
+		var strs []string
+		for _, sub := range regexp.Sub {
+			subStrs, err := generateAll(sub, args)
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, subStrs...)
+			if err := checkGenerateAllCount(regexp, len(strs), args); err != nil {
+				return nil, err
+			}
+		}
+		return strs, nil
+	}
+
+	return nil, generatorError(nil, "GenerateAll can't generate every string for /%s/", regexp)
+}
+
+// generateAllCharClass returns every rune generated by regexp, which must be a character class or any char.
+func generateAllCharClass(regexp *syntax.Regexp, args *GeneratorArgs) ([]string, error) {
+	charClass, err := finiteCharClass(regexp, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGenerateAllCount(regexp, int(charClass.TotalSize), args); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, charClass.TotalSize)
+	for i := range strs {
+		strs[i] = string(charClass.GetRuneAt(int32(i)))
+	}
+	return strs, nil
+}
+
+// generateAllRepeat returns every string matched by min to max repetitions of regexp's sub-expression.
+func generateAllRepeat(regexp *syntax.Regexp, min, max int, args *GeneratorArgs) ([]string, error) {
+	if err := enforceSingleSub(regexp); err != nil {
+		return nil, err
+	}
+	subStrs, err := generateAll(regexp.Sub[0], args)
+	if err != nil {
+		return nil, err
+	}
+
+	var strs []string
+	repeated := []string{""}
+	for n := 0; n <= max; n++ {
+		if n >= min {
+			strs = append(strs, repeated...)
+			if err := checkGenerateAllCount(regexp, len(strs), args); err != nil {
+				return nil, err
+			}
+		}
+		if n < max {
+			if repeated, err = generateAllProduct(regexp, repeated, subStrs, args); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return strs, nil
+}
+
+// generateAllProduct returns every string in prefixes followed by every string in suffixes.
+func generateAllProduct(regexp *syntax.Regexp, prefixes, suffixes []string, args *GeneratorArgs) ([]string, error) {
+	if err := checkGenerateAllCount(regexp, len(prefixes)*len(suffixes), args); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, 0, len(prefixes)*len(suffixes))
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			strs = append(strs, prefix+suffix)
+		}
+	}
+	return strs, nil
+}
+
+// countMatches returns the number of strings matched by regexp, which must be simplified.
+func countMatches(regexp *syntax.Regexp, args *GeneratorArgs) (*big.Int, error) {
+	switch regexp.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return big.NewInt(1), nil
+
+	case syntax.OpLiteral:
+		count := big.NewInt(1)
+		for _, runes := range literalVariants(regexp) {
+			count.Mul(count, big.NewInt(int64(len(runes))))
+		}
+		return count, nil
+
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		charClass, err := finiteCharClass(regexp, args)
+		if err != nil {
+			return nil, err
+		}
+		return big.NewInt(int64(charClass.TotalSize)), nil
+
+	case syntax.OpCapture:
+		if err := enforceSingleSub(regexp); err != nil {
+			return nil, err
+		}
+		return countMatches(regexp.Sub[0], args)
+
+	case syntax.OpQuest:
+		return countRepeatMatches(regexp, 0, 1, args)
+
+	case syntax.OpRepeat:
+		if regexp.Max < 0 {
+			return nil, ErrInfiniteMatches
+		}
+		return countRepeatMatches(regexp, regexp.Min, regexp.Max, args)
+
+	case syntax.OpStar, syntax.OpPlus:
+		return nil, ErrInfiniteMatches
+
+	case syntax.OpConcat:
+		count := big.NewInt(1)
+		for _, sub := range regexp.Sub {
+			subCount, err := countMatches(sub, args)
+			if err != nil {
+				return nil, err
+			}
+			count.Mul(count, subCount)
+		}
+		return count, nil
+
+	case syntax.OpAlternate:
+		count := big.NewInt(0)
+		for _, sub := range regexp.Sub {
+			subCount, err := countMatches(sub, args)
+			if err != nil {
+				return nil, err
+			}
+			count.Add(count, subCount)
+		}
+		return count, nil
+	}
+
+	return nil, generatorError(nil, "can't count strings matched by /%s/", regexp)
+}
+
+// countRepeatMatches returns the number of strings matched by min to max repetitions of regexp's sub-expression.
+func countRepeatMatches(regexp *syntax.Regexp, min, max int, args *GeneratorArgs) (*big.Int, error) {
+	if err := enforceSingleSub(regexp); err != nil {
+		return nil, err
+	}
+	subCount, err := countMatches(regexp.Sub[0], args)
+	if err != nil {
+		return nil, err
+	}
+
+	count := big.NewInt(0)
+	repeated := big.NewInt(1)
+	for n := 0; n <= max; n++ {
+		if n >= min {
+			count.Add(count, repeated)
+		}
+		repeated.Mul(repeated, subCount)
+	}
+	return count, nil
+}
+
+// checkGenerateAllCount returns an error if count is more than args.MaxGenerateAllCount.
+func checkGenerateAllCount(regexp *syntax.Regexp, count int, args *GeneratorArgs) error {
+	if count > args.MaxGenerateAllCount {
+		return generatorError(nil, "/%s/ generates more than %d strings", regexp, args.MaxGenerateAllCount)
+	}
+	return nil
+}
+
+// runesToStrings returns each rune in runes as a string.
+func runesToStrings(runes []rune) []string {
+	strs := make([]string, len(runes))
+	for i, r := range runes {
+		strs[i] = string(r)
+	}
+	return strs
+}
+
+// removeDuplicates returns strs without duplicates, in the order they first appear.
+func removeDuplicates(strs []string) []string {
+	seen := make(map[string]bool, len(strs))
+	result := strs[:0]
+	for _, str := range strs {
+		if !seen[str] {
+			seen[str] = true
+			result = append(result, str)
+		}
+	}
+	return result
+}
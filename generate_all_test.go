@@ -0,0 +1,178 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/big"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateAll(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateAll", t, func() {
+		Convey("Generates every string", func() {
+			strs, err := GenerateAll(`(ab|cd)[01]`, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"ab0", "ab1", "cd0", "cd1"})
+
+			strs, err = GenerateAll(`^x?[ab]{2}$`, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"aa", "ab", "ba", "bb", "xaa", "xab", "xba", "xbb"})
+
+			strs, err = GenerateAll(`a{1,3}`, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"a", "aa", "aaa"})
+
+			strs, err = GenerateAll(`(?i)ab`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"AB", "Ab", "aB", "ab"})
+
+			strs, err = GenerateAll(`[a-d]`, &GeneratorArgs{ExcludeRunes: []rune{'b', 'c'}})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"a", "d"})
+
+			strs, err = GenerateAll(`[a-z]`, &GeneratorArgs{AllowRunes: []rune("zxa1")})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"a", "x", "z"})
+		})
+
+		Convey("Generates only matching strings", func() {
+			for _, pattern := range []string{`\d{2}-(foo|bar)?`, `[a-c](x|y|)[^\x00-\x{7e}\x{80}-\x{10FFFF}]`} {
+				strs, err := GenerateAll(pattern, &GeneratorArgs{Flags: syntax.Perl})
+				So(err, ShouldBeNil)
+
+				re := regexp.MustCompile("^(?:" + pattern + ")$")
+				for _, str := range strs {
+					So(re.MatchString(str), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Doesn't return duplicates", func() {
+			strs, err := GenerateAll(`(a|ab)(b|)`, nil)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"ab", "a", "abb"})
+		})
+
+		Convey("Generates any char with ASCIIOnly", func() {
+			strs, err := GenerateAll(`.`, &GeneratorArgs{ASCIIOnly: true})
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 126)
+		})
+
+		Convey("Returns error for unbounded patterns", func() {
+			for _, pattern := range []string{`a*`, `a+`, `a{2,}`, `(b|a*)c`} {
+				_, err := GenerateAll(pattern, nil)
+				So(err, ShouldEqual, ErrInfiniteMatches)
+			}
+		})
+
+		Convey("Returns error for too many strings", func() {
+			_, err := GenerateAll(`[a-z]{4}`, nil)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateAll(`[ab]{4}`, &GeneratorArgs{MaxGenerateAllCount: 15})
+			So(err, ShouldNotBeNil)
+
+			strs, err := GenerateAll(`[ab]{4}`, &GeneratorArgs{MaxGenerateAllCount: 16})
+			So(err, ShouldBeNil)
+			So(strs, ShouldHaveLength, 16)
+		})
+
+		Convey("Returns error for backreferences", func() {
+			_, err := GenerateAll(`(a)\1`, nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Generates literal patterns verbatim", func() {
+			strs, err := GenerateAll(`(a)\1*`, &GeneratorArgs{Flags: syntax.Literal})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{`(a)\1*`})
+		})
+
+		Convey("Parses patterns like NewGenerator", func() {
+			strs, err := GenerateAll(`x\Qa.b\Ey`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"xa.by"})
+
+			strs, err = GenerateAll(`a?b{0,2}`, &GeneratorArgs{MinRepeatOverride: 1})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"ab", "abb"})
+
+			strs, err = GenerateAll(`[c-a]`, &GeneratorArgs{AllowDescendingRanges: true})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"a", "b", "c"})
+
+			strs, err = GenerateAll(`foo(?=bar)[01]`, &GeneratorArgs{Flags: syntax.Perl, IgnoreUnsupportedAssertions: true})
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"foo0", "foo1"})
+		})
+	})
+}
+
+func TestCountMatches(t *testing.T) {
+	t.Parallel()
+
+	Convey("CountMatches", t, func() {
+		count := func(pattern string, args *GeneratorArgs) string {
+			n, err := CountMatches(pattern, args)
+			So(err, ShouldBeNil)
+			return n.String()
+		}
+
+		Convey("Counts matching strings", func() {
+			So(count(`[a-c]{3}`, nil), ShouldEqual, "27")
+			So(count(`(ab|cd)[01]`, nil), ShouldEqual, "4")
+			So(count(`x?[ab]{0,2}`, nil), ShouldEqual, "14")
+			So(count(`^$`, nil), ShouldEqual, "1")
+			So(count(`(?i)ab1`, &GeneratorArgs{Flags: syntax.Perl}), ShouldEqual, "4")
+			So(count(`.`, &GeneratorArgs{ASCIIOnly: true}), ShouldEqual, "126")
+		})
+
+		Convey("Counts the strings generated by GenerateAll", func() {
+			for _, pattern := range []string{`(ab|cd)[01]`, `x?[ab]{0,2}`, `(foo|bar|qux)-\d{2}`} {
+				strs, err := GenerateAll(pattern, &GeneratorArgs{Flags: syntax.Perl})
+				So(err, ShouldBeNil)
+				So(count(pattern, &GeneratorArgs{Flags: syntax.Perl}), ShouldEqual, strconv.Itoa(len(strs)))
+			}
+		})
+
+		Convey("Parses patterns like NewGenerator", func() {
+			So(count(`[z-a]`, &GeneratorArgs{AllowDescendingRanges: true}), ShouldEqual, "26")
+			So(count(`x\Q.*\E`, &GeneratorArgs{Flags: syntax.Perl}), ShouldEqual, "1")
+			So(count(`a?b?`, &GeneratorArgs{MinRepeatOverride: 1}), ShouldEqual, "1")
+		})
+
+		Convey("Counts huge numbers of strings", func() {
+			expected := new(big.Int).Exp(big.NewInt(26), big.NewInt(1000), nil)
+			So(count(`[a-z]{1000}`, nil), ShouldEqual, expected.String())
+		})
+
+		Convey("Returns error for unbounded patterns", func() {
+			for _, pattern := range []string{`a*`, `a+`, `a{2,}`, `(b|a*)c`} {
+				_, err := CountMatches(pattern, nil)
+				So(err, ShouldEqual, ErrInfiniteMatches)
+			}
+		})
+	})
+}
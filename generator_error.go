@@ -18,6 +18,7 @@ package regen
 
 import (
 	"fmt"
+	"regexp/syntax"
 )
 
 // Error returned by a generatorFactory if the AST is invalid.
@@ -36,3 +37,41 @@ func (err *tGeneratorError) Error() string {
 	}
 	return err.ErrorStr
 }
+
+// Unwrap returns the cause of the error, so errors.Is and errors.As can find the errors below.
+func (err *tGeneratorError) Unwrap() error {
+	return err.Cause
+}
+
+// maxDepthError returns the error for an expression nested deeper than args.MaxDepth.
+func maxDepthError(args *GeneratorArgs) error {
+	return generatorError(nil, "expression is nested more than %d levels deep", args.MaxDepth)
+}
+
+// UnsupportedOpError is returned by NewGenerator and CanGenerate when the expression contains an operation
+// that generators can't be created for.
+type UnsupportedOpError struct {
+	Op syntax.Op
+	// The simplified expression with the unsupported operation.
+	Regexp *syntax.Regexp
+}
+
+func (err *UnsupportedOpError) Error() string {
+	return fmt.Sprintf("invalid generator pattern: /%s/ (unsupported op %s)\n%s",
+		err.Regexp, opToString(err.Op), inspectRegexpToString(err.Regexp))
+}
+
+// SubExpressionCountError is returned when an expression has the wrong number of sub-expressions for its operation,
+// e.g. a repeat with more than one. The parser never creates such expressions, so this indicates an invalid AST.
+type SubExpressionCountError struct {
+	Op   syntax.Op
+	Got  int
+	Want int
+	// The expression with the wrong number of sub-expressions.
+	Regexp *syntax.Regexp
+}
+
+func (err *SubExpressionCountError) Error() string {
+	return fmt.Sprintf("%s expected %d sub-expression, but got %d: %s",
+		opToString(err.Op), err.Want, err.Got, err.Regexp)
+}
@@ -18,6 +18,7 @@ package regen
 
 import (
 	"errors"
+	"regexp/syntax"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -37,3 +38,48 @@ func TestGeneratorError(t *testing.T) {
 		})
 	})
 }
+
+func TestTypedErrors(t *testing.T) {
+	t.Parallel()
+
+	Convey("Typed errors", t, func() {
+		args := &GeneratorArgs{}
+		So(args.initialize(), ShouldBeNil)
+
+		Convey("UnsupportedOpError can be extracted from wrapped errors", func() {
+			regexp := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+				{Op: syntax.OpLiteral, Rune: []rune("a")},
+				{Op: syntax.OpNoMatch},
+			}}
+			_, err := newGenerator(regexp, args)
+			So(err, ShouldNotBeNil)
+			So(err, ShouldHaveSameTypeAs, &tGeneratorError{})
+
+			var opErr *UnsupportedOpError
+			So(errors.As(err, &opErr), ShouldBeTrue)
+			So(opErr.Op, ShouldEqual, syntax.OpNoMatch)
+			So(err.Error(), ShouldContainSubstring, "OpNoMatch")
+		})
+
+		Convey("SubExpressionCountError has the counts", func() {
+			regexp := &syntax.Regexp{Op: syntax.OpCapture, Sub: []*syntax.Regexp{
+				{Op: syntax.OpLiteral, Rune: []rune("a")},
+				{Op: syntax.OpLiteral, Rune: []rune("b")},
+			}}
+			_, err := opCapture(regexp, args)
+
+			var countErr *SubExpressionCountError
+			So(errors.As(err, &countErr), ShouldBeTrue)
+			So(countErr.Op, ShouldEqual, syntax.OpCapture)
+			So(countErr.Got, ShouldEqual, 2)
+			So(countErr.Want, ShouldEqual, 1)
+			So(err.Error(), ShouldContainSubstring, "expected 1 sub-expression, but got 2")
+		})
+
+		Convey("Unwraps causes", func() {
+			cause := errors.New("cause")
+			So(errors.Is(generatorError(cause, "msg"), cause), ShouldBeTrue)
+			So(errors.Unwrap(generatorError(nil, "msg")), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,19 @@
+module github.com/zach-klippenstein/goregen
+
+go 1.18
+
+require (
+	github.com/google/gxui v0.0.0-20151028112939-f85e0a97b3a4
+	github.com/smartystreets/goconvey v1.8.1
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.14.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gopherjs/gopherjs v1.17.2 // indirect
+	github.com/jtolds/gls v4.20.0+incompatible // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/smarty/assertions v1.15.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import "regexp/syntax"
+
+// GoldenVersion is the version of GoldenGenerate's output. Changes that would change the output are made opt-in
+// under a new version (see GoldenGenerate).
+const GoldenVersion = 1
+
+// goldenArgs returns the options used by GoldenGenerate. Every field is set explicitly, instead of relying on zero
+// values and defaults, so changing a default doesn't change golden output, and new fields must be added here with
+// the value that keeps it the same.
+func goldenArgs(seed int64) *GeneratorArgs {
+	return &GeneratorArgs{
+		RngSource:                   nil,
+		Rand:                        newXorShift64Rand(seed),
+		SeedString:                  "",
+		Flags:                       syntax.Perl,
+		MaxUnboundedRepeatCount:     4096,
+		MinUnboundedRepeatCount:     0,
+		MinRepeatOverride:           0,
+		MaxGenerateAllCount:         10000,
+		MaxDepth:                    5000,
+		MaxAlternateDepth:           0,
+		RepeatDistribution:          UniformRepeatDistribution,
+		CharClassStrategy:           RandomCharClassStrategy,
+		Deterministic:               false,
+		ByteMode:                    false,
+		PrintableOnly:               false,
+		RawAnyChar:                  false,
+		ASCIIOnly:                   false,
+		AllowRunes:                  nil,
+		ExcludeRunes:                nil,
+		RuneWeights:                 nil,
+		ForbiddenSubstrings:         nil,
+		NonEmpty:                    false,
+		MaxDistinctRunesPerClass:    0,
+		DigitScript:                 ASCIIDigits,
+		NormalizeForm:               NoNormalization,
+		WordBoundaries:              false,
+		DedupeAlternates:            false,
+		AvoidEmptyAlternates:        false,
+		IgnoreUnsupportedAssertions: false,
+		AllowDescendingRanges:       false,
+		Concurrent:                  false,
+		RngPool:                     false,
+		Validate:                    false,
+		MaxTotalLength:              0,
+		Timeout:                     0,
+		SoftMaxLength:               0,
+		AlternateWeight:             nil,
+		PatternWeight:               nil,
+		CaptureGroupHandler:         nil,
+		Overrides:                   nil,
+	}
+}
+
+/*
+GoldenGenerate returns n strings generated from pattern with an RNG seeded with seed, for golden files and other
+fixtures that are checked in and compared against generated data. Its options are fixed rather than taken from
+the defaults: pattern is parsed with syntax.Perl flags, unbounded repeats generate at most 4096 instances, and
+every other option that affects the strings generated is off.
+
+GoldenGenerate uses the same generators as Generate, but its output is pinned by tests: a change to the generators
+that would change it is made opt-in, under a new GoldenVersion, instead. This only covers patterns that parse the
+same way in both releases: e.g. a pattern that a release rejects (like one using a feature added later) isn't
+covered.
+*/
+func GoldenGenerate(pattern string, seed int64, n int) ([]string, error) {
+	if n < 0 {
+		return nil, generatorError(nil, "invalid count %d", n)
+	}
+
+	generator, err := NewGenerator(pattern, goldenArgs(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, n)
+	for i := range strs {
+		strs[i] = generator.Generate()
+	}
+	return strs, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGoldenGenerate(t *testing.T) {
+	t.Parallel()
+
+	Convey("GoldenGenerate", t, func() {
+		// These outputs must never change: see the compatibility promise in GoldenGenerate's documentation.
+		// If one of them fails, the change that broke it must be made opt-in under a new GoldenVersion instead.
+		Convey("Generates pinned output for version 1", func() {
+			So(GoldenVersion, ShouldEqual, 1)
+
+			golden := []struct {
+				pattern string
+				strs    []string
+			}{
+				{`[a-z]{5}\d{3}`, []string{"jjazb135", "wphbm652", "wpubs578"}},
+				{`(foo|bar|baz)-[0-9a-f]{4}`, []string{"baz-09b1", "baz-2b39", "foo-0f28"}},
+				{`\w{3,8}@example\.(com|org)`, []string{"dxfQl2Mj@example.com", "vYHNE5@example.org", "A7W6j@example.com"}},
+				{`(?i)hello [^\x00-\x7f]`, []string{"heLlo \U000ba727", "hEllo \U0004f768", "hELlO \U00085b68"}},
+			}
+			for _, g := range golden {
+				strs, err := GoldenGenerate(g.pattern, 42, len(g.strs))
+				So(err, ShouldBeNil)
+				So(strs, ShouldResemble, g.strs)
+			}
+
+			// Unbounded repeats are limited to 4096 instances.
+			strs, err := GoldenGenerate(`x*y{0,3}`, 42, 2)
+			So(err, ShouldBeNil)
+			So(strs[0], ShouldEqual, strings.Repeat("x", 3861)+"y")
+			So(strs[1], ShouldEqual, strings.Repeat("x", 1708)+"yyy")
+		})
+
+		Convey("Generates a prefix of the same strings for smaller counts", func() {
+			strs, err := GoldenGenerate(`[a-z]{5}\d{3}`, 42, 1)
+			So(err, ShouldBeNil)
+			So(strs, ShouldResemble, []string{"jjazb135"})
+
+			strs, err = GoldenGenerate(`[a-z]{5}\d{3}`, 42, 0)
+			So(err, ShouldBeNil)
+			So(strs, ShouldBeEmpty)
+		})
+
+		Convey("Different seeds generate different strings", func() {
+			strs, err := GoldenGenerate(`[a-z]{5}\d{3}`, 43, 3)
+			So(err, ShouldBeNil)
+			So(strs, ShouldNotResemble, []string{"jjazb135", "wphbm652", "wpubs578"})
+		})
+
+		Convey("Sets every option explicitly", func() {
+			file, err := parser.ParseFile(token.NewFileSet(), "golden.go", nil, 0)
+			So(err, ShouldBeNil)
+			set := make(map[string]bool)
+			ast.Inspect(file, func(node ast.Node) bool {
+				if kv, ok := node.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						set[key.Name] = true
+					}
+				}
+				return true
+			})
+
+			argsType := reflect.TypeOf(GeneratorArgs{})
+			for i := 0; i < argsType.NumField(); i++ {
+				if field := argsType.Field(i); field.IsExported() {
+					So(set, ShouldContainKey, field.Name)
+				}
+			}
+		})
+
+		Convey("Returns errors", func() {
+			_, err := GoldenGenerate(`(`, 42, 1)
+			So(err, ShouldNotBeNil)
+
+			_, err = GoldenGenerate(`a`, 42, -1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -17,10 +17,19 @@ limitations under the License.
 package regen
 
 import (
+	"bufio"
 	"bytes"
-	"fmt"
+	"context"
+	"io"
 	"math"
+	"math/rand"
 	"regexp/syntax"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // generatorFactory is a function that creates a random string generator from a regular expression AST.
@@ -31,6 +40,10 @@ var generatorFactories map[syntax.Op]generatorFactory
 
 const noBound = -1
 
+// contextCheckInterval is the number of calls to generatorState.checkContext between checks of the context.
+// Checking on every call would slow down generation too much.
+const contextCheckInterval = 64
+
 func init() {
 	generatorFactories = map[syntax.Op]generatorFactory{
 		syntax.OpEmptyMatch:     opEmptyMatch,
@@ -49,24 +62,512 @@ func init() {
 		syntax.OpEndLine:        noop,
 		syntax.OpBeginText:      noop,
 		syntax.OpEndText:        noop,
-		syntax.OpWordBoundary:   noop,
-		syntax.OpNoWordBoundary: noop,
+		syntax.OpWordBoundary:   opWordBoundary,
+		syntax.OpNoWordBoundary: opNoWordBoundary,
+	}
+}
+
+// maxPooledBufferSize is the capacity of the largest buffer kept in bufferPool, so generating one huge string
+// doesn't keep its memory alive.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool holds buffers for output that's copied out once it's generated, so generating many strings
+// doesn't allocate (and grow) a new buffer for each one. Each buffer is only used by one call at a time.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer takes an empty buffer from bufferPool. It must be returned with putBuffer when it's no longer used,
+// and its contents must not be used afterwards.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffer.Reset()
+	bufferPool.Put(buffer)
+}
+
+// writerPool holds the bufio.Writers GenerateTo wraps its writer in, since each one allocates its own buffer.
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewWriter(nil)
+	},
+}
+
+// runeWriter is the interface generators write their output to.
+// It is implemented by both *bytes.Buffer and *bufio.Writer.
+type runeWriter interface {
+	io.Writer
+	io.ByteWriter
+	WriteRune(r rune) (int, error)
+	WriteString(s string) (int, error)
+}
+
+// generatorState holds the state of a single call to a generator.
+// Generators write their output to the embedded runeWriter.
+type generatorState struct {
+	runeWriter
+
+	args *GeneratorArgs
+	// The RNG used by this call. Usually args.rng, but with args.RngPool, each call uses its own RNG from the pool.
+	rng RandSource
+	// The pool rng was taken from, if any.
+	rngPool *pooledRandSource
+
+	// May be nil.
+	ctx context.Context
+	// The time after which generation fails with ErrTimeout, or zero if args.Timeout isn't set.
+	deadline time.Time
+	// Number of calls to checkContext since ctx and deadline were last checked.
+	contextChecks int
+
+	// Maximum number of bytes that may be written, or 0 if there is no limit.
+	maxLength int
+	// Number of bytes after which repeats stop repeating, or 0 if there is no limit.
+	softMaxLength int
+	// Number of bytes written so far. Only tracked if there is a limit.
+	length int
+
+	// If true, capture groups record their output in captureGroups.
+	recordCaptureGroups bool
+	// The last output of each capture group, if they're being recorded.
+	captureGroups []string
+	// Values to generate instead of the output of capture groups, keyed by 0-based group index. May be nil.
+	fixedGroups map[int]string
+
+	// The last rune written, or 0 if nothing has been written yet. Write and WriteString only record the last byte
+	// written, without decoding it: multi-byte runes are never word runes or newlines, and neither are the bytes
+	// they end with, so that's enough for word boundaries and anchors.
+	lastRune rune
+	// True once anything has been written.
+	written bool
+	// The kind of rune the next rune written must be to satisfy a preceding word boundary.
+	nextRune runeRequirement
+
+	// If true, each random choice is recorded in decisions.
+	explain   bool
+	decisions []Decision
+
+	// The alternatives chosen so far by GenerateCovering, or nil if they aren't being recorded.
+	coverage alternateCoverage
+}
+
+// restart resets state to generate the output again from the beginning.
+func (state *generatorState) restart() {
+	state.length = 0
+	state.captureGroups = state.captureGroups[:0]
+	state.lastRune = 0
+	state.written = false
+	state.nextRune = anyRune
+	state.decisions = state.decisions[:0]
+}
+
+// release returns the resources used by state. state must not be used afterwards.
+func (state *generatorState) release() {
+	if state.rngPool != nil {
+		state.rngPool.put(state.rng)
+		state.rngPool = nil
+	}
+}
+
+// intn returns a random number in [0, n), or 0 if args.Deterministic is set.
+func (state *generatorState) intn(n int) int {
+	if state.args.Deterministic {
+		return 0
+	}
+	return state.rng.Intn(n)
+}
+
+// int31n returns a random number in [0, n), or 0 if args.Deterministic is set.
+func (state *generatorState) int31n(n int32) int32 {
+	if state.args.Deterministic {
+		return 0
+	}
+	return state.rng.Int31n(n)
+}
+
+// int31 returns a random non-negative int32, or 0 if args.Deterministic is set.
+func (state *generatorState) int31() int32 {
+	if state.args.Deterministic {
+		return 0
+	}
+	return state.rng.Int31()
+}
+
+// repeatCount returns a number of repetitions in [min, max] chosen from args.RepeatDistribution
+// (or GeometricRepeatDistribution, if the repeat is non-greedy), or min if args.Deterministic is set.
+func (state *generatorState) repeatCount(min, max int, nonGreedy bool) int {
+	if nonGreedy || state.args.RepeatDistribution == GeometricRepeatDistribution {
+		n := min
+		for n < max && state.intn(2) == 1 {
+			n++
+		}
+		return n
+	}
+	return min + state.intn(max-min+1)
+}
+
+// checkContext returns ctx's error if it is done, or ErrTimeout if deadline has passed.
+// To keep it cheap, they're only actually checked every contextCheckInterval calls.
+func (state *generatorState) checkContext() error {
+	if state.ctx == nil && state.deadline.IsZero() {
+		return nil
+	}
+
+	state.contextChecks++
+	if state.contextChecks < contextCheckInterval {
+		return nil
+	}
+	state.contextChecks = 0
+	if !state.deadline.IsZero() && time.Now().After(state.deadline) {
+		return ErrTimeout
+	}
+	if state.ctx != nil {
+		return state.ctx.Err()
+	}
+	return nil
+}
+
+// grow records that n more bytes are about to be written, and returns ErrMaxTotalLengthExceeded if
+// that would make the output longer than maxLength.
+func (state *generatorState) grow(n int) error {
+	if state.maxLength <= 0 && state.softMaxLength <= 0 {
+		return nil
+	}
+	if state.maxLength > 0 && state.length+n > state.maxLength {
+		return ErrMaxTotalLengthExceeded
+	}
+	state.length += n
+	return nil
+}
+
+// overSoftMaxLength returns true if at least softMaxLength bytes have been written.
+func (state *generatorState) overSoftMaxLength() bool {
+	return state.softMaxLength > 0 && state.length >= state.softMaxLength
+}
+
+// wrote records that r was the last rune written, for word boundaries and anchors.
+func (state *generatorState) wrote(r rune) {
+	state.lastRune = r
+	state.written = true
+	if state.args.WordBoundaries {
+		state.nextRune = anyRune
+	}
+}
+
+// The Write methods override the embedded runeWriter's to enforce maxLength and track the last rune written.
+
+func (state *generatorState) Write(p []byte) (int, error) {
+	if err := state.grow(len(p)); err != nil {
+		return 0, err
+	}
+	if len(p) > 0 {
+		state.wrote(rune(p[len(p)-1]))
+	}
+	return state.runeWriter.Write(p)
+}
+
+func (state *generatorState) WriteByte(b byte) error {
+	if err := state.grow(1); err != nil {
+		return err
+	}
+	state.wrote(rune(b))
+	return state.runeWriter.WriteByte(b)
+}
+
+func (state *generatorState) WriteRune(r rune) (int, error) {
+	n := utf8.RuneLen(r)
+	if n < 0 {
+		// Invalid runes are written as utf8.RuneError.
+		n = utf8.RuneLen(utf8.RuneError)
+	}
+	if err := state.grow(n); err != nil {
+		return 0, err
+	}
+	state.wrote(r)
+	return state.runeWriter.WriteRune(r)
+}
+
+func (state *generatorState) WriteString(s string) (int, error) {
+	if err := state.grow(len(s)); err != nil {
+		return 0, err
+	}
+	if len(s) > 0 {
+		state.wrote(rune(s[len(s)-1]))
+	}
+	return state.runeWriter.WriteString(s)
+}
+
+// captureGroup returns the last recorded output of the capture group at index, or "" if there isn't any.
+func (state *generatorState) captureGroup(index int) string {
+	if index < len(state.captureGroups) {
+		return state.captureGroups[index]
+	}
+	return ""
+}
+
+// setCaptureGroup records value as the output of the capture group at index.
+func (state *generatorState) setCaptureGroup(index int, value string) {
+	for len(state.captureGroups) <= index {
+		state.captureGroups = append(state.captureGroups, "")
 	}
+	state.captureGroups[index] = value
+}
+
+// writeCaptureGroup writes value as the output of the capture group at index, recording it if groups are
+// being recorded.
+func (state *generatorState) writeCaptureGroup(index int, value string) error {
+	if state.recordCaptureGroups {
+		state.setCaptureGroup(index, value)
+	}
+	_, err := state.WriteString(value)
+	return err
+}
+
+// generateCaptureGroup runs generate, and records its output as the output of the capture group at index.
+func (state *generatorState) generateCaptureGroup(index int, generate func(state *generatorState) error) error {
+	w := state.runeWriter
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	state.runeWriter = buffer
+	err := generate(state)
+	state.runeWriter = w
+	if err != nil {
+		return err
+	}
+
+	state.setCaptureGroup(index, buffer.String())
+	// The output was already counted against maxLength when it was written to buffer.
+	_, err = state.runeWriter.WriteString(buffer.String())
+	return err
 }
 
 type internalGenerator struct {
-	Name         string
-	GenerateFunc func() string
+	Name string
+	// The expression the generator was created from.
+	regexp *syntax.Regexp
+	// The args used to create the generator.
+	args *GeneratorArgs
+	// The generators for the sub-expressions of regexp that this generator runs, if any.
+	children []*internalGenerator
+	// Writes the generated string to state, and returns the first error encountered.
+	GenerateFunc func(state *generatorState) error
 }
 
 func (gen *internalGenerator) Generate() string {
-	return gen.GenerateFunc()
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	// Writing to a bytes.Buffer never fails, and there's no context to be cancelled.
+	// If MaxTotalLength is exceeded, the output generated so far is returned.
+	gen.generate(gen.newState(buffer, nil))
+	return buffer.String()
+}
+
+func (gen *internalGenerator) generateBytes() []byte {
+	var buffer bytes.Buffer
+	// Writing to a bytes.Buffer never fails, and there's no context to be cancelled.
+	// If MaxTotalLength is exceeded, the output generated so far is returned.
+	gen.generate(gen.newState(&buffer, nil))
+	return buffer.Bytes()
+}
+
+func (gen *internalGenerator) generateE() (string, error) {
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	if err := gen.generate(gen.newState(buffer, nil)); err != nil {
+		return buffer.String(), err
+	}
+	str := buffer.String()
+	return str, gen.check(str)
+}
+
+func (gen *internalGenerator) generateTo(w io.Writer) (int, error) {
+	counter := &countingWriter{w: w}
+	buffered := writerPool.Get().(*bufio.Writer)
+	buffered.Reset(counter)
+	defer func() {
+		buffered.Reset(nil)
+		writerPool.Put(buffered)
+	}()
+
+	if err := gen.generate(gen.newState(buffered, nil)); err != nil {
+		return counter.n, err
+	}
+	err := buffered.Flush()
+	return counter.n, err
+}
+
+func (gen *internalGenerator) generateAppend(sb *strings.Builder) {
+	// Writing to a strings.Builder never fails, and there's no context to be cancelled.
+	// If MaxTotalLength is exceeded, the output generated so far is appended.
+	gen.generate(gen.newState(sb, nil))
+}
+
+func (gen *internalGenerator) generateContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	if err := gen.generate(gen.newState(buffer, ctx)); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (gen *internalGenerator) GenerateCaptures() (string, []string) {
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	state := gen.newState(buffer, nil)
+	state.recordCaptureGroups = true
+	gen.generate(state)
+
+	// Use the same numbering as regexp.FindStringSubmatch: the whole string is at index 0.
+	full := buffer.String()
+	groups := make([]string, gen.args.numCaptureGroups+1)
+	groups[0] = full
+	copy(groups[1:], state.captureGroups)
+	return full, groups
+}
+
+func (gen *internalGenerator) GenerateNamed() (string, map[string]string) {
+	full, groups := gen.GenerateCaptures()
+
+	named := make(map[string]string)
+	// Later groups overwrite earlier groups with the same name.
+	for i, name := range gen.args.captureNames {
+		if name != "" {
+			named[name] = groups[i]
+		}
+	}
+	return full, named
+}
+
+// newState returns the state for a single call to the generator.
+// It must be released when the call is finished.
+func (gen *internalGenerator) newState(w runeWriter, ctx context.Context) *generatorState {
+	state := &generatorState{
+		runeWriter:    w,
+		args:          gen.args,
+		rng:           gen.args.rng,
+		ctx:           ctx,
+		maxLength:     gen.args.MaxTotalLength,
+		softMaxLength: gen.args.SoftMaxLength,
+		// Backreferences need the output of every group.
+		recordCaptureGroups: gen.args.hasBackreferences,
+	}
+	if gen.args.Timeout > 0 {
+		state.deadline = time.Now().Add(gen.args.Timeout)
+	}
+	if pool, ok := gen.args.rng.(*pooledRandSource); ok {
+		// Taking a single RNG for the whole call is much faster than taking one for every random number.
+		state.rng = pool.get()
+		state.rngPool = pool
+	}
+	return state
+}
+
+// generate runs the generator with state, and then releases state.
+func (gen *internalGenerator) generate(state *generatorState) error {
+	defer state.release()
+	return gen.generateUnreleased(state)
+}
+
+// generateUnreleased runs the generator with state, normalizing the output with args.NormalizeForm, and
+// regenerating it if it contains one of args.ForbiddenSubstrings, or is empty and args.NonEmpty is set.
+func (gen *internalGenerator) generateUnreleased(state *generatorState) error {
+	if len(gen.args.ForbiddenSubstrings) == 0 && gen.args.NormalizeForm == NoNormalization && !gen.args.NonEmpty {
+		return gen.GenerateFunc(state)
+	}
+
+	// Generate into a buffer so the output can be discarded.
+	w := state.runeWriter
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	state.runeWriter = buffer
+
+	attempts := 1
+	if len(gen.args.ForbiddenSubstrings) > 0 {
+		attempts = MaxForbiddenSubstringAttempts
+	}
+	if gen.args.NonEmpty {
+		attempts = maxInt(attempts, MaxNonEmptyAttempts)
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		buffer.Reset()
+		state.restart()
+		err = gen.GenerateFunc(state)
+		gen.args.NormalizeForm.normalize(buffer)
+		if err != nil {
+			break
+		}
+		if containsAny(buffer.Bytes(), gen.args.ForbiddenSubstrings) {
+			err = ErrForbiddenSubstring
+		} else if gen.args.NonEmpty && buffer.Len() == 0 {
+			err = ErrEmptyString
+		} else {
+			break
+		}
+	}
+
+	state.runeWriter = w
+	// The output was already counted against maxLength when it was written to buffer.
+	if _, writeErr := w.Write(buffer.Bytes()); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+// containsAny returns true if b contains any of substrings.
+func containsAny(b []byte, substrings []string) bool {
+	for _, substring := range substrings {
+		if bytes.Contains(b, []byte(substring)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (gen *internalGenerator) Reseed(seed int64) {
+	// Seed the same way as initialize would with RngSource set to rand.NewSource(seed).
+	gen.args.setRng(newXorShift64Rand(rand.NewSource(seed).Int63()))
+}
+
+func (gen *internalGenerator) Clone(rng RandSource) Generator {
+	return gen.withArgs(gen.args.withRng(rng))
+}
+
+// withArgs returns a copy of gen that generates with args instead of gen.args. The generator tree is shared.
+func (gen *internalGenerator) withArgs(args *GeneratorArgs) *internalGenerator {
+	clone := *gen
+	clone.args = args
+	return &clone
 }
 
 func (gen *internalGenerator) String() string {
 	return gen.Name
 }
 
+func (gen *internalGenerator) AST() *syntax.Regexp {
+	return gen.regexp
+}
+
+func (gen *internalGenerator) Walk(fn func(op syntax.Op, regexp *syntax.Regexp)) {
+	fn(gen.regexp.Op, gen.regexp)
+	for _, child := range gen.children {
+		child.Walk(fn)
+	}
+}
+
 // Create a new generator for each expression in regexps.
 func newGenerators(regexps []*syntax.Regexp, args *GeneratorArgs) ([]*internalGenerator, error) {
 	generators := make([]*internalGenerator, len(regexps), len(regexps))
@@ -85,98 +586,267 @@ func newGenerators(regexps []*syntax.Regexp, args *GeneratorArgs) ([]*internalGe
 
 // Create a new generator for r.
 func newGenerator(regexp *syntax.Regexp, args *GeneratorArgs) (generator *internalGenerator, err error) {
+	// Generators for sub-expressions are created recursively, so track how deep the recursion is.
+	args.depth++
+	defer func() { args.depth-- }()
+	if args.depth > args.MaxDepth {
+		return nil, maxDepthError(args)
+	}
+
 	simplified := regexp.Simplify()
 
+	if override, ok := args.Overrides[simplified.Op]; ok && !isBackreferencePlaceholderGroup(simplified, args) {
+		return createOverrideGenerator(simplified, override, args)
+	}
+
 	factory, ok := generatorFactories[simplified.Op]
 	if ok {
 		return factory(simplified, args)
 	}
 
-	return nil, fmt.Errorf("invalid generator pattern: /%s/ as /%s/\n%s",
-		regexp, simplified, inspectRegexpToString(simplified))
+	return nil, &UnsupportedOpError{simplified.Op, simplified}
+}
+
+// Returns a generator that will generate the output of the generator created by override.
+func createOverrideGenerator(regexp *syntax.Regexp, override GeneratorFactory, args *GeneratorArgs) (*internalGenerator, error) {
+	generator, err := override(regexp, args)
+	if err != nil {
+		return nil, generatorError(err, "error creating override generator for /%s/", regexp)
+	}
+	if generator == nil {
+		return nil, generatorError(nil, "override for /%s/ returned a nil generator", regexp)
+	}
+
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		str := generator.Generate()
+		if regexp.Op == syntax.OpCapture && regexp.Cap > 0 && state.recordCaptureGroups {
+			state.setCaptureGroup(regexp.Cap-1, str)
+		}
+		_, err := state.WriteString(str)
+		return err
+	}}, nil
 }
 
 // Generator that does nothing.
 func noop(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	return &internalGenerator{regexp.String(), func() string {
-		return ""
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		return nil
 	}}, nil
 }
 
 func opEmptyMatch(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpEmptyMatch)
-	return &internalGenerator{regexp.String(), func() string {
-		return ""
+	if err := enforceOp(regexp, syntax.OpEmptyMatch); err != nil {
+		return nil, err
+	}
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		return nil
 	}}, nil
 }
 
 func opLiteral(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpLiteral)
-	return &internalGenerator{regexp.String(), func() string {
-		return runesToString(regexp.Rune...)
+	if err := enforceOp(regexp, syntax.OpLiteral); err != nil {
+		return nil, err
+	}
+	if regexp.Flags&syntax.FoldCase != 0 {
+		return createFoldedLiteralGenerator(regexp, args)
+	}
+
+	literal := runesToString(regexp.Rune...)
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		_, err := state.WriteString(literal)
+		return err
 	}}, nil
 }
 
-func opAnyChar(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpAnyChar)
-	return &internalGenerator{regexp.String(), func() string {
-		return runesToString(rune(args.rng.Int31()))
+// createFoldedLiteralGenerator creates a generator for a case-insensitive literal, which generates a random case
+// variant of each rune.
+func createFoldedLiteralGenerator(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+	variants := literalVariants(regexp)
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		for _, runes := range variants {
+			i := state.intn(len(runes))
+			state.decide(regexp, i)
+			if _, err := state.WriteRune(runes[i]); err != nil {
+				return err
+			}
+		}
+		return nil
 	}}, nil
 }
 
+// literalVariants returns, for each rune of a literal, the runes it can generate: the rune itself, followed by its
+// other case variants if the literal is case-insensitive.
+func literalVariants(regexp *syntax.Regexp) [][]rune {
+	variants := make([][]rune, len(regexp.Rune))
+	for i, r := range regexp.Rune {
+		variants[i] = []rune{r}
+		if regexp.Flags&syntax.FoldCase == 0 {
+			continue
+		}
+		// SimpleFold iterates over the runes equivalent to r, returning to r after the last one.
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			variants[i] = append(variants[i], f)
+		}
+	}
+	return variants
+}
+
+func opAnyChar(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+	if err := enforceOp(regexp, syntax.OpAnyChar); err != nil {
+		return nil, err
+	}
+	if args.ByteMode {
+		return createAnyByteGenerator(regexp, false, args)
+	}
+	if isRawAnyChar(regexp, args) {
+		excluded := make(map[rune]bool, len(args.ExcludeRunes))
+		for _, r := range args.ExcludeRunes {
+			excluded[r] = true
+		}
+		// Almost all runes are non-word runes, so only word runes need their own class.
+		wordClass := asciiWordClass.subtract(newCharClassFromRunes(args.ExcludeRunes))
+		return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+			req := state.nextRune
+			if req == wordRune {
+				if wordClass.TotalSize > 0 {
+					i := state.int31n(wordClass.TotalSize)
+					state.decide(regexp, int(i))
+					_, err := state.WriteRune(wordClass.GetRuneAt(i))
+					return err
+				}
+				// Every word rune is excluded, so the requirement can't be met.
+				req = anyRune
+			}
+
+			r := rune(state.int31())
+			for excluded[r] || !req.meets(r) {
+				if args.Deterministic {
+					// int31 always returns 0, so take the first rune that is allowed.
+					r++
+				} else {
+					r = rune(state.int31())
+				}
+			}
+			state.decide(regexp, int(r))
+			_, err := state.WriteRune(r)
+			return err
+		}}, nil
+	}
+	return createCharClassGenerator(regexp, anyCharGenerationClass(regexp, args), args)
+}
+
 func opAnyCharNotNl(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpAnyCharNotNL)
-	charClass := newCharClass(1, rune(math.MaxInt32))
-	return createCharClassGenerator(regexp.String(), charClass, args)
+	if err := enforceOp(regexp, syntax.OpAnyCharNotNL); err != nil {
+		return nil, err
+	}
+	if args.ByteMode {
+		return createAnyByteGenerator(regexp, true, args)
+	}
+	return createCharClassGenerator(regexp, anyCharGenerationClass(regexp, args), args)
+}
+
+// isRawAnyChar returns true if regexp, which must be "." with syntax.DotNL, generates arbitrary int31 runes
+// instead of runes from a character class.
+func isRawAnyChar(regexp *syntax.Regexp, args *GeneratorArgs) bool {
+	return regexp.Op == syntax.OpAnyChar && args.RawAnyChar && !args.PrintableOnly && !args.ASCIIOnly &&
+		len(args.AllowRunes) == 0
+}
+
+// anyCharGenerationClass returns the class "." generates runes from when not in ByteMode, before
+// createCharClassGenerator applies ASCIIOnly, AllowRunes, and ExcludeRunes.
+func anyCharGenerationClass(regexp *syntax.Regexp, args *GeneratorArgs) *tCharClass {
+	switch {
+	case args.PrintableOnly:
+		// Newlines aren't printable.
+		return getPrintableCharClass()
+	case regexp.Op == syntax.OpAnyCharNotNL && args.RawAnyChar:
+		return parseCharClass([]rune{1, '\n' - 1, '\n' + 1, rune(math.MaxInt32)})
+	case regexp.Op == syntax.OpAnyCharNotNL:
+		return anyCharNotNLClass
+	}
+	return anyCharClass
 }
 
 func opQuest(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpQuest)
+	if err := enforceOp(regexp, syntax.OpQuest); err != nil {
+		return nil, err
+	}
 	return createRepeatingGenerator(regexp, args, 0, 1)
 }
 
 func opStar(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpStar)
+	if err := enforceOp(regexp, syntax.OpStar); err != nil {
+		return nil, err
+	}
 	return createRepeatingGenerator(regexp, args, noBound, noBound)
 }
 
 func opPlus(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpPlus)
+	if err := enforceOp(regexp, syntax.OpPlus); err != nil {
+		return nil, err
+	}
 	return createRepeatingGenerator(regexp, args, 1, noBound)
 }
 
 func opRepeat(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpRepeat)
+	if err := enforceOp(regexp, syntax.OpRepeat); err != nil {
+		return nil, err
+	}
 	return createRepeatingGenerator(regexp, args, regexp.Min, regexp.Max)
 }
 
 // Handles syntax.ClassNL because the parser uses that flag to generate character
 // classes that respect it.
 func opCharClass(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpCharClass)
+	if err := enforceOp(regexp, syntax.OpCharClass); err != nil {
+		return nil, err
+	}
+	if err := checkCharClassRunes(regexp.Rune); err != nil {
+		return nil, generatorError(err, "invalid character class")
+	}
 	charClass := parseCharClass(regexp.Rune)
-	return createCharClassGenerator(regexp.String(), charClass, args)
+	return createCharClassGenerator(regexp, charClass, args)
 }
 
 func opConcat(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpConcat)
+	if err := enforceOp(regexp, syntax.OpConcat); err != nil {
+		return nil, err
+	}
 
 	generators, err := newGenerators(regexp.Sub, genArgs)
 	if err != nil {
 		return nil, generatorError(err, "error creating generators for concat pattern /%s/", regexp)
 	}
 
-	return &internalGenerator{regexp.String(), func() string {
-		var result bytes.Buffer
+	return &internalGenerator{regexp.String(), regexp, genArgs, generators, func(state *generatorState) error {
 		for _, generator := range generators {
-			result.WriteString(generator.Generate())
+			if err := state.checkContext(); err != nil {
+				return err
+			}
+			if err := generator.GenerateFunc(state); err != nil {
+				return err
+			}
 		}
-		return result.String()
+		return nil
 	}}, nil
 }
 
 func opAlternate(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpAlternate)
+	if err := enforceOp(regexp, syntax.OpAlternate); err != nil {
+		return nil, err
+	}
+	if genArgs.DedupeAlternates {
+		regexp = dedupeAlternates(regexp)
+	}
+	if genArgs.AvoidEmptyAlternates {
+		regexp = removeEmptyAlternates(regexp)
+	}
+
+	genArgs.alternateDepth++
+	defer func() { genArgs.alternateDepth-- }()
+	if genArgs.MaxAlternateDepth > 0 && genArgs.alternateDepth > genArgs.MaxAlternateDepth {
+		return newGenerator(shortestAlternative(regexp, genArgs), genArgs)
+	}
 
 	generators, err := newGenerators(regexp.Sub, genArgs)
 	if err != nil {
@@ -185,20 +855,131 @@ func opAlternate(regexp *syntax.Regexp, genArgs *GeneratorArgs) (*internalGenera
 
 	numGens := len(generators)
 
-	return &internalGenerator{regexp.String(), func() string {
-		i := genArgs.rng.Intn(numGens)
+	if genArgs.AlternateWeight != nil {
+		return createWeightedAlternateGenerator(regexp, generators, genArgs)
+	}
+	anchors := leadingAnchors(regexp)
+
+	return &internalGenerator{regexp.String(), regexp, genArgs, generators, func(state *generatorState) error {
+		i, ok := state.chooseAnchoredAlternative(anchors, nil)
+		if !ok {
+			i, ok = state.chooseUncovered(regexp, numGens)
+		}
+		if !ok {
+			i = state.intn(numGens)
+		}
+		state.decide(regexp, i)
 		generator := generators[i]
-		return generator.Generate()
+		return generator.GenerateFunc(state)
+	}}, nil
+}
+
+// Returns a generator that will run one of generators, chosen using genArgs.AlternateWeight.
+func createWeightedAlternateGenerator(regexp *syntax.Regexp, generators []*internalGenerator, genArgs *GeneratorArgs) (*internalGenerator, error) {
+	cumulativeWeights, err := alternateWeights(regexp, genArgs.AlternateWeight)
+	if err != nil {
+		return nil, err
+	}
+	totalWeight := cumulativeWeights[len(cumulativeWeights)-1]
+	anchors := leadingAnchors(regexp)
+
+	return &internalGenerator{regexp.String(), regexp, genArgs, generators, func(state *generatorState) error {
+		i, ok := state.chooseAnchoredAlternative(anchors, cumulativeWeights)
+		if !ok {
+			i, ok = state.chooseUncovered(regexp, len(generators))
+		}
+		if !ok {
+			n := state.intn(totalWeight)
+			for cumulativeWeights[i] <= n {
+				i++
+			}
+		}
+		state.decide(regexp, i)
+		return generators[i].GenerateFunc(state)
 	}}, nil
 }
 
+// shortestAlternative returns the alternative of regexp, an alternation, that generates the shortest strings, or
+// the first one if more than one does, for MaxAlternateDepth.
+func shortestAlternative(regexp *syntax.Regexp, args *GeneratorArgs) *syntax.Regexp {
+	g := &extremeGenerator{args: args, lengths: make(map[*syntax.Regexp]int)}
+	return regexp.Sub[g.alternative(regexp)]
+}
+
+// dedupeAlternates returns regexp, an alternation, without alternatives that are equal to earlier ones.
+func dedupeAlternates(regexp *syntax.Regexp) *syntax.Regexp {
+	var unique []*syntax.Regexp
+	for _, sub := range regexp.Sub {
+		duplicate := false
+		for _, other := range unique {
+			if sub.Simplify().Equal(other.Simplify()) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			unique = append(unique, sub)
+		}
+	}
+	if len(unique) == len(regexp.Sub) {
+		return regexp
+	}
+
+	deduped := *regexp
+	deduped.Sub = unique
+	return &deduped
+}
+
+// removeEmptyAlternates returns regexp, an alternation, without alternatives that only match the empty string,
+// unless they all do.
+func removeEmptyAlternates(regexp *syntax.Regexp) *syntax.Regexp {
+	var nonEmpty []*syntax.Regexp
+	for _, sub := range regexp.Sub {
+		if sub.Simplify().Op != syntax.OpEmptyMatch {
+			nonEmpty = append(nonEmpty, sub)
+		}
+	}
+	if len(nonEmpty) == 0 || len(nonEmpty) == len(regexp.Sub) {
+		return regexp
+	}
+
+	removed := *regexp
+	removed.Sub = nonEmpty
+	return &removed
+}
+
+// alternateWeights returns the cumulative weights of regexp's alternatives from genArgs.AlternateWeight:
+// element i is the sum of the weights of alternatives 0 to i.
+func alternateWeights(regexp *syntax.Regexp, weight AlternateWeight) ([]int, error) {
+	cumulativeWeights := make([]int, len(regexp.Sub))
+	totalWeight := 0
+	for i := range regexp.Sub {
+		w := weight(i, len(regexp.Sub))
+		if w < 0 {
+			return nil, generatorError(nil, "invalid weight %d for alternative %d of /%s/", w, i, regexp)
+		}
+		totalWeight += w
+		cumulativeWeights[i] = totalWeight
+	}
+	if totalWeight == 0 {
+		return nil, generatorError(nil, "all alternatives of /%s/ have weight 0", regexp)
+	}
+	return cumulativeWeights, nil
+}
+
 func opCapture(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
-	enforceOp(regexp, syntax.OpCapture)
+	if err := enforceOp(regexp, syntax.OpCapture); err != nil {
+		return nil, err
+	}
 
 	if err := enforceSingleSub(regexp); err != nil {
 		return nil, err
 	}
 
+	if index, ok := backreferenceGroup(regexp); ok && args.hasBackreferences {
+		return createBackreferenceGenerator(regexp, index, args)
+	}
+
 	groupRegexp := regexp.Sub[0]
 	generator, err := newGenerator(groupRegexp, args)
 	if err != nil {
@@ -208,39 +989,181 @@ func opCapture(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator,
 	// Group indices are 0-based, but index 0 is the whole expression.
 	index := regexp.Cap - 1
 
-	return &internalGenerator{regexp.String(), func() string {
-		return args.CaptureGroupHandler(index, regexp.Name, groupRegexp, generator, args)
-	}}, nil
-}
+	if args.CaptureGroupHandler == nil {
+		return &internalGenerator{regexp.String(), regexp, args, []*internalGenerator{generator}, func(state *generatorState) error {
+			if value, ok := state.fixedGroups[index]; ok {
+				return state.writeCaptureGroup(index, value)
+			}
+			if !state.recordCaptureGroups {
+				return generator.GenerateFunc(state)
+			}
+			return state.generateCaptureGroup(index, generator.GenerateFunc)
+		}}, nil
+	}
 
-func defaultCaptureGroupHandler(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
-	return generator.Generate()
+	return &internalGenerator{regexp.String(), regexp, args, []*internalGenerator{generator}, func(state *generatorState) error {
+		if value, ok := state.fixedGroups[index]; ok {
+			return state.writeCaptureGroup(index, value)
+		}
+		return state.writeCaptureGroup(index, args.CaptureGroupHandler(index, regexp.Name, groupRegexp, generator, args))
+	}}, nil
 }
 
-// Panic if r.Op != op.
-func enforceOp(r *syntax.Regexp, op syntax.Op) {
+// Return an error if r.Op != op.
+func enforceOp(r *syntax.Regexp, op syntax.Op) error {
 	if r.Op != op {
-		panic(fmt.Sprintf("invalid Op: expected %s, was %s", opToString(op), opToString(r.Op)))
+		return generatorError(nil, "invalid Op: expected %s, was %s", opToString(op), opToString(r.Op))
 	}
+	return nil
 }
 
 // Return an error if r has 0 or more than 1 sub-expression.
 func enforceSingleSub(regexp *syntax.Regexp) error {
 	if len(regexp.Sub) != 1 {
-		return generatorError(nil,
-			"%s expected 1 sub-expression, but got %d: %s", opToString(regexp.Op), len(regexp.Sub), regexp)
+		return &SubExpressionCountError{regexp.Op, len(regexp.Sub), 1, regexp}
 	}
 	return nil
 }
 
-func createCharClassGenerator(name string, charClass *tCharClass, args *GeneratorArgs) (*internalGenerator, error) {
-	return &internalGenerator{name, func() string {
-		i := args.rng.Int31n(charClass.TotalSize)
+func createCharClassGenerator(regexp *syntax.Regexp, charClass *tCharClass, args *GeneratorArgs) (*internalGenerator, error) {
+	charClass, err := restrictCharClass(regexp, applyDigitScript(charClass, args), args)
+	if err != nil {
+		return nil, err
+	}
+	if args.MaxDistinctRunesPerClass > 0 {
+		charClass = sampleCharClass(regexp, charClass, args)
+	}
+	classes := boundaryCharClasses(charClass, args)
+
+	var weighted [numRuneRequirements]*weightedCharClass
+	if len(args.RuneWeights) > 0 {
+		for i, class := range classes {
+			if weighted[i], err = newWeightedCharClass(regexp, class, args.RuneWeights); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sequential := args.CharClassStrategy == SequentialCharClassStrategy
+	// The number of runes generated so far, for SequentialCharClassStrategy.
+	var position uint64
+
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		charClass := classes[state.nextRune]
+		var i int32
+		// Runes required by word boundaries are chosen from a smaller class, so they aren't covered.
+		uncovered, ok := 0, false
+		if state.nextRune == anyRune {
+			uncovered, ok = state.chooseUncovered(regexp, int(charClass.TotalSize))
+		}
+		if ok {
+			i = int32(uncovered)
+		} else if sequential && !state.args.Deterministic {
+			i = int32((atomic.AddUint64(&position, 1) - 1) % uint64(charClass.TotalSize))
+		} else if weighted := weighted[state.nextRune]; weighted != nil && !state.args.Deterministic {
+			i = weighted.choose(state)
+		} else {
+			i = state.int31n(charClass.TotalSize)
+		}
+		state.decide(regexp, int(i))
 		r := charClass.GetRuneAt(i)
-		return runesToString(r)
+		_, err := state.WriteRune(r)
+		return err
+	}}, nil
+}
+
+// applyDigitScript returns the digits of args.DigitScript if charClass contains exactly the ASCII digits, and
+// charClass otherwise.
+func applyDigitScript(charClass *tCharClass, args *GeneratorArgs) *tCharClass {
+	if args.DigitScript == ASCIIDigits || len(charClass.Ranges) != 1 || charClass.Ranges[0].Start != '0' ||
+		charClass.Ranges[0].Size != 10 {
+		return charClass
+	}
+	return newCharClass(rune(args.DigitScript), rune(args.DigitScript)+9)
+}
+
+// restrictCharClass returns charClass restricted by args.ASCIIOnly, args.AllowRunes, and args.ExcludeRunes,
+// or an error if that leaves it empty.
+func restrictCharClass(regexp *syntax.Regexp, charClass *tCharClass, args *GeneratorArgs) (*tCharClass, error) {
+	if args.ASCIIOnly {
+		charClass = charClass.intersect(asciiClass)
+		if charClass.TotalSize == 0 {
+			return nil, generatorError(nil, "character class %s has no ASCII characters", regexp)
+		}
+	}
+	if len(args.AllowRunes) > 0 {
+		charClass = charClass.intersect(newCharClassFromRunes(args.AllowRunes))
+		if charClass.TotalSize == 0 {
+			return nil, generatorError(nil, "character class %s doesn't contain any allowed runes %q", regexp,
+				string(args.AllowRunes))
+		}
+	}
+	if len(args.ExcludeRunes) > 0 {
+		charClass = charClass.subtract(newCharClassFromRunes(args.ExcludeRunes))
+		if charClass.TotalSize == 0 {
+			return nil, generatorError(nil, "character class %s only contains excluded runes %q", regexp,
+				string(args.ExcludeRunes))
+		}
+	}
+	return charClass, nil
+}
+
+// Returns a generator that will generate a single arbitrary byte from anyByteValues.
+func createAnyByteGenerator(regexp *syntax.Regexp, excludeNewline bool, args *GeneratorArgs) (*internalGenerator, error) {
+	values, err := anyByteValues(regexp, excludeNewline, args)
+	if err != nil {
+		return nil, err
+	}
+	boundaryValues := boundaryByteValues(values, args)
+
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		values := boundaryValues[state.nextRune]
+		i := state.intn(len(values))
+		state.decide(regexp, i)
+		return state.WriteByte(values[i])
 	}}, nil
 }
 
+// anyByteValues returns the bytes "." generates in ByteMode: every byte (or ASCII byte, if args.ASCIIOnly is set),
+// excluding '\n' if excludeNewline is true. If args.AllowRunes is set, only bytes in it are included.
+// Bytes in args.ExcludeRunes are also excluded.
+func anyByteValues(regexp *syntax.Regexp, excludeNewline bool, args *GeneratorArgs) ([]byte, error) {
+	n := 256
+	if args.ASCIIOnly {
+		n = unicode.MaxASCII + 1
+	}
+
+	excluded := make([]bool, n)
+	if len(args.AllowRunes) > 0 {
+		for b := range excluded {
+			excluded[b] = true
+		}
+		for _, r := range args.AllowRunes {
+			if r >= 0 && int(r) < n {
+				excluded[r] = false
+			}
+		}
+	}
+	if excludeNewline {
+		excluded['\n'] = true
+	}
+	for _, r := range args.ExcludeRunes {
+		if r >= 0 && int(r) < n {
+			excluded[r] = true
+		}
+	}
+
+	var values []byte
+	for b := 0; b < n; b++ {
+		if !excluded[b] {
+			values = append(values, byte(b))
+		}
+	}
+	if len(values) == 0 {
+		return nil, generatorError(nil, "%s doesn't match any allowed bytes", regexp)
+	}
+	return values, nil
+}
+
 // Returns a generator that will run the generator for r's sub-expression [min, max] times.
 func createRepeatingGenerator(regexp *syntax.Regexp, genArgs *GeneratorArgs, min, max int) (*internalGenerator, error) {
 	if err := enforceSingleSub(regexp); err != nil {
@@ -258,14 +1181,35 @@ func createRepeatingGenerator(regexp *syntax.Regexp, genArgs *GeneratorArgs, min
 	if max == noBound {
 		max = int(genArgs.MaxUnboundedRepeatCount)
 	}
+	nonGreedy := regexp.Flags&syntax.NonGreedy != 0
 
-	return &internalGenerator{regexp.String(), func() string {
-		n := min + genArgs.rng.Intn(max-min+1)
+	return &internalGenerator{regexp.String(), regexp, genArgs, []*internalGenerator{generator}, func(state *generatorState) error {
+		n := state.repeatCount(min, max, nonGreedy)
+		state.decide(regexp, n)
 
-		var result bytes.Buffer
 		for i := 0; i < n; i++ {
-			result.WriteString(generator.Generate())
+			if err := state.checkContext(); err != nil {
+				return err
+			}
+			if i >= min && state.overSoftMaxLength() {
+				break
+			}
+			if err := generator.GenerateFunc(state); err != nil {
+				return err
+			}
 		}
-		return result.String()
+		return nil
 	}}, nil
 }
+
+// countingWriter counts the number of bytes successfully written to w.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+// MaxIntersectionAttempts is the number of strings GenerateIntersection generates before giving up.
+const MaxIntersectionAttempts = 1000
+
+/*
+GenerateIntersection returns a random string that matches all of patterns, e.g. a string that satisfies both a
+format and a separate validation rule. If args is nil, default values are used.
+
+This is best effort: strings are generated from the first pattern, and the first one that the other patterns also
+match is returned. If none of MaxIntersectionAttempts strings match, an error is returned, so it's only suitable
+when a reasonable fraction of the strings generated from the first pattern match the others. Put the most restrictive
+pattern first. E.g. for `\d{3}` and `[0-8]+`, about 73% of strings from `\d{3}` don't contain a 9, but only strings
+of exactly 3 digits from `[0-8]+` match `\d{3}`.
+
+Each pattern is parsed on its own with args. Only the first pattern can contain backreferences, since the others are
+matched with the regexp package, which doesn't support them.
+*/
+func GenerateIntersection(patterns []string, args *GeneratorArgs) (string, error) {
+	if len(patterns) == 0 {
+		return "", generatorError(nil, "no patterns")
+	}
+
+	generator, err := NewGenerator(patterns[0], args)
+	if err != nil {
+		return "", generatorError(err, "error creating generator for pattern 0")
+	}
+	matchers := make([]func(string) bool, len(patterns)-1)
+	for i, pattern := range patterns[1:] {
+		regexp, parsedArgs, err := parsePattern(pattern, args)
+		if err != nil {
+			return "", generatorError(err, "error parsing pattern %d", i+1)
+		}
+		if matchers[i], err = compileValidator(regexp, parsedArgs.hasBackreferences); err != nil {
+			return "", generatorError(err, "error compiling pattern %d", i+1)
+		}
+	}
+
+	for attempt := 0; attempt < MaxIntersectionAttempts; attempt++ {
+		str, err := GenerateE(generator)
+		if err != nil {
+			return "", err
+		}
+		if matchesAll(str, matchers) {
+			return str, nil
+		}
+	}
+	return "", generatorError(nil, "couldn't generate a string that matches all %d patterns in %d attempts",
+		len(patterns), MaxIntersectionAttempts)
+}
+
+// matchesAll returns true if every one of matchers matches str.
+func matchesAll(str string, matchers []func(string) bool) bool {
+	for _, matches := range matchers {
+		if !matches(str) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateIntersection(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateIntersection", t, func() {
+		args := &GeneratorArgs{Flags: syntax.Perl}
+
+		Convey("Generates strings that match every pattern", func() {
+			for i := 0; i < 100; i++ {
+				str, err := GenerateIntersection([]string{`\d{3}`, `[0-8]+`}, args)
+				So(err, ShouldBeNil)
+				So(str, ShouldHaveLength, 3)
+				So(str, ShouldNotContainSubstring, "9")
+			}
+
+			pattern := regexp.MustCompile(`^[a-c]{2,6}$`)
+			for i := 0; i < 100; i++ {
+				str, err := GenerateIntersection([]string{`[a-d]{2,6}`, `[a-c]+`, `\w{2}|\w{4}|\w{6}`}, args)
+				So(err, ShouldBeNil)
+				So(pattern.MatchString(str), ShouldBeTrue)
+				So(len(str)%2, ShouldEqual, 0)
+			}
+		})
+
+		Convey("Is reproducible with a seeded RNG", func() {
+			generate := func() string {
+				str, err := GenerateIntersection([]string{`\d{3}`, `[0-8]+`}, &GeneratorArgs{
+					RngSource: rand.NewSource(1),
+					Flags:     syntax.Perl,
+				})
+				So(err, ShouldBeNil)
+				return str
+			}
+			So(generate(), ShouldEqual, generate())
+		})
+
+		Convey("Returns the first pattern's strings for a single pattern", func() {
+			str, err := GenerateIntersection([]string{`abc`}, nil)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "abc")
+		})
+
+		Convey("Returns an error if the patterns can't be satisfied", func() {
+			_, err := GenerateIntersection([]string{`a+`, `b+`}, args)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Returns an error for invalid patterns", func() {
+			_, err := GenerateIntersection(nil, args)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateIntersection([]string{`(`, `a`}, args)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateIntersection([]string{`a`, `(`}, args)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateIntersection([]string{`a`, `(a)\1`}, args)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,327 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp/syntax"
+)
+
+// MaxGenerateWithLength is the longest length GenerateWithLength generates. The time and memory it takes grow with
+// the square of the length, or the cube for repeats of expressions that generate many lengths, so longer lengths
+// return an error instead.
+const MaxGenerateWithLength = 1024
+
+/*
+GenerateWithLength can't just generate strings until one has the right length, so it makes two passes over
+the expression instead. The first pass works out which lengths (up to the requested length) each sub-expression
+can generate. The second pass generates the string, only making choices (alternatives, repeat counts, and how to
+split the length between sub-expressions) that can still lead to a string of the requested length.
+*/
+
+// lengthSet records the lengths, in runes, that an expression can generate: lengthSet[l] is true if the expression
+// can generate a string of length l.
+type lengthSet []bool
+
+// lengthGenerator generates strings of a fixed length.
+type lengthGenerator struct {
+	args  *GeneratorArgs
+	state *generatorState
+	// The length of the string being generated. Longer lengths aren't tracked.
+	maxLength int
+
+	lengths map[*syntax.Regexp]lengthSet
+	// The lengths each number of repetitions of a repeat expression can generate, indexed by count.
+	repeatLengths map[*syntax.Regexp][]lengthSet
+	// For each concat expression, the lengths the concatenation of Sub[i:] can generate, indexed by i.
+	suffixLengths map[*syntax.Regexp][]lengthSet
+}
+
+func (gen *internalGenerator) GenerateWithLength(n int) (string, error) {
+	if n < 0 {
+		return "", generatorError(nil, "invalid length %d", n)
+	}
+	if n > MaxGenerateWithLength {
+		return "", generatorError(nil, "length %d is longer than MaxGenerateWithLength (%d)", n, MaxGenerateWithLength)
+	}
+	if gen.args.hasBackreferences {
+		return "", generatorError(nil, "GenerateWithLength doesn't support backreferences: /%s/", gen)
+	}
+
+	lengthGen := &lengthGenerator{
+		args:          gen.args,
+		maxLength:     n,
+		lengths:       make(map[*syntax.Regexp]lengthSet),
+		repeatLengths: make(map[*syntax.Regexp][]lengthSet),
+		suffixLengths: make(map[*syntax.Regexp][]lengthSet),
+	}
+
+	lengths, err := lengthGen.possibleLengths(gen.regexp)
+	if err != nil {
+		return "", err
+	}
+	if !lengths[n] {
+		return "", generatorError(nil, "/%s/ can't generate a string of length %d", gen, n)
+	}
+
+	var buffer bytes.Buffer
+	lengthGen.state = gen.newState(&buffer, nil)
+	defer lengthGen.state.release()
+	if err := lengthGen.generate(gen.regexp, n); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (g *lengthGenerator) newLengthSet() lengthSet {
+	return make(lengthSet, g.maxLength+1)
+}
+
+// concatLengths returns the lengths generated by an expression that generates a length from a followed by
+// a length from b.
+func (g *lengthGenerator) concatLengths(a, b lengthSet) lengthSet {
+	result := g.newLengthSet()
+	for i, okA := range a {
+		if !okA {
+			continue
+		}
+		for j := 0; i+j <= g.maxLength; j++ {
+			if b[j] {
+				result[i+j] = true
+			}
+		}
+	}
+	return result
+}
+
+// possibleLengths returns the lengths regexp can generate.
+func (g *lengthGenerator) possibleLengths(regexp *syntax.Regexp) (lengthSet, error) {
+	if lengths, ok := g.lengths[regexp]; ok {
+		return lengths, nil
+	}
+
+	lengths := g.newLengthSet()
+
+	switch regexp.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		lengths[0] = true
+
+	case syntax.OpLiteral:
+		if len(regexp.Rune) <= g.maxLength {
+			lengths[len(regexp.Rune)] = true
+		}
+
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		charClass, err := finiteCharClass(regexp, g.args)
+		if err != nil {
+			return nil, err
+		}
+		if charClass.TotalSize > 0 && g.maxLength > 0 {
+			lengths[1] = true
+		}
+
+	case syntax.OpCapture:
+		if err := enforceSingleSub(regexp); err != nil {
+			return nil, err
+		}
+		subLengths, err := g.possibleLengths(regexp.Sub[0])
+		if err != nil {
+			return nil, err
+		}
+		copy(lengths, subLengths)
+
+	case syntax.OpConcat:
+		suffixes := make([]lengthSet, len(regexp.Sub)+1)
+		suffixes[len(regexp.Sub)] = g.newLengthSet()
+		suffixes[len(regexp.Sub)][0] = true
+		for i := len(regexp.Sub) - 1; i >= 0; i-- {
+			subLengths, err := g.possibleLengths(regexp.Sub[i])
+			if err != nil {
+				return nil, err
+			}
+			suffixes[i] = g.concatLengths(subLengths, suffixes[i+1])
+		}
+		g.suffixLengths[regexp] = suffixes
+		lengths = suffixes[0]
+
+	case syntax.OpAlternate:
+		for _, sub := range regexp.Sub {
+			subLengths, err := g.possibleLengths(sub)
+			if err != nil {
+				return nil, err
+			}
+			for l, ok := range subLengths {
+				lengths[l] = lengths[l] || ok
+			}
+		}
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		repeats, err := g.possibleRepeatLengths(regexp)
+		if err != nil {
+			return nil, err
+		}
+		min, _ := repeatBounds(regexp, g.args)
+		for count := min; count < len(repeats); count++ {
+			for l, ok := range repeats[count] {
+				lengths[l] = lengths[l] || ok
+			}
+		}
+
+	default:
+		return nil, generatorError(nil, "GenerateWithLength doesn't support /%s/", regexp)
+	}
+
+	g.lengths[regexp] = lengths
+	return lengths, nil
+}
+
+// repeatBounds returns the minimum and maximum number of repetitions of regexp, which must be a repeat expression.
+// The bounds of unbounded repeats are the same as for Generate.
+func repeatBounds(regexp *syntax.Regexp, args *GeneratorArgs) (min, max int) {
+	switch regexp.Op {
+	case syntax.OpQuest:
+		return 0, 1
+	case syntax.OpStar:
+		return int(args.MinUnboundedRepeatCount), int(args.MaxUnboundedRepeatCount)
+	case syntax.OpPlus:
+		return 1, int(args.MaxUnboundedRepeatCount)
+	}
+
+	min, max = regexp.Min, regexp.Max
+	if max == noBound {
+		max = int(args.MaxUnboundedRepeatCount)
+	}
+	return min, max
+}
+
+// possibleRepeatLengths returns the lengths generated by each number of repetitions of regexp's sub-expression,
+// up to the largest number that can generate new lengths.
+func (g *lengthGenerator) possibleRepeatLengths(regexp *syntax.Regexp) ([]lengthSet, error) {
+	if repeats, ok := g.repeatLengths[regexp]; ok {
+		return repeats, nil
+	}
+
+	if err := enforceSingleSub(regexp); err != nil {
+		return nil, err
+	}
+	subLengths, err := g.possibleLengths(regexp.Sub[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// A string of length maxLength can't contain more than maxLength non-empty repetitions, so more repetitions than
+	// that (or the minimum, if it's larger) are only possible by repeating the empty string, which doesn't generate any
+	// new lengths.
+	min, max := repeatBounds(regexp, g.args)
+	if limit := maxInt(min, g.maxLength); max > limit {
+		max = limit
+	}
+
+	repeats := make([]lengthSet, max+1)
+	repeats[0] = g.newLengthSet()
+	repeats[0][0] = true
+	for count := 1; count <= max; count++ {
+		repeats[count] = g.concatLengths(subLengths, repeats[count-1])
+	}
+
+	g.repeatLengths[regexp] = repeats
+	return repeats, nil
+}
+
+// choose returns a random index i in [0, n) for which ok(i) is true. At least one must be true.
+func (g *lengthGenerator) choose(n int, ok func(i int) bool) int {
+	var choices []int
+	for i := 0; i < n; i++ {
+		if ok(i) {
+			choices = append(choices, i)
+		}
+	}
+	return choices[g.state.intn(len(choices))]
+}
+
+// generate writes a string of the given length generated by regexp to g.state, and returns the first error encountered.
+// possibleLengths must have returned true for length.
+func (g *lengthGenerator) generate(regexp *syntax.Regexp, length int) error {
+	switch regexp.Op {
+	case syntax.OpLiteral:
+		if regexp.Flags&syntax.FoldCase == 0 {
+			_, err := g.state.WriteString(runesToString(regexp.Rune...))
+			return err
+		}
+		for _, runes := range literalVariants(regexp) {
+			if _, err := g.state.WriteRune(runes[g.state.intn(len(runes))]); err != nil {
+				return err
+			}
+		}
+
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		// Already checked by possibleLengths.
+		charClass, _ := finiteCharClass(regexp, g.args)
+		_, err := g.state.WriteRune(charClass.GetRuneAt(g.state.int31n(charClass.TotalSize)))
+		return err
+
+	case syntax.OpCapture:
+		return g.generate(regexp.Sub[0], length)
+
+	case syntax.OpConcat:
+		suffixes := g.suffixLengths[regexp]
+		for i, sub := range regexp.Sub {
+			subLengths := g.lengths[sub]
+			subLength := g.choose(length+1, func(l int) bool {
+				return subLengths[l] && suffixes[i+1][length-l]
+			})
+			if err := g.generate(sub, subLength); err != nil {
+				return err
+			}
+			length -= subLength
+		}
+
+	case syntax.OpAlternate:
+		i := g.choose(len(regexp.Sub), func(i int) bool {
+			return g.lengths[regexp.Sub[i]][length]
+		})
+		return g.generate(regexp.Sub[i], length)
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		repeats := g.repeatLengths[regexp]
+		sub := regexp.Sub[0]
+		subLengths := g.lengths[sub]
+
+		min, _ := repeatBounds(regexp, g.args)
+		count := min + g.choose(len(repeats)-min, func(i int) bool {
+			return repeats[min+i][length]
+		})
+		for ; count > 0; count-- {
+			subLength := g.choose(length+1, func(l int) bool {
+				return subLengths[l] && repeats[count-1][length-l]
+			})
+			if err := g.generate(sub, subLength); err != nil {
+				return err
+			}
+			length -= subLength
+		}
+	}
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateWithLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithLength", t, func() {
+		ConveyGeneratesLength := func(pattern string, n int, args *GeneratorArgs) {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			matcher := regexp.MustCompile("^(?:" + pattern + ")$")
+			for i := 0; i < SampleSize/10; i++ {
+				str, err := generator.(LengthGenerator).GenerateWithLength(n)
+				So(err, ShouldBeNil)
+				So(utf8.RuneCountInString(str), ShouldEqual, n)
+				So(matcher.MatchString(str), ShouldBeTrue)
+			}
+		}
+
+		Convey("Generates strings of the requested length", func() {
+			ConveyGeneratesLength(`a+b+`, 5, nil)
+			ConveyGeneratesLength(`a+b+`, 2, nil)
+			ConveyGeneratesLength(`(ab)*c?`, 7, nil)
+			ConveyGeneratesLength(`[a-z]{2,8}-(x|yyy)`, 6, nil)
+			ConveyGeneratesLength(`\d{3}(-\d{4})?`, 8, &GeneratorArgs{Flags: syntax.Perl})
+			ConveyGeneratesLength(`(a*)*b`, 10, nil)
+			ConveyGeneratesLength(`.*`, 100, nil)
+			ConveyGeneratesLength(``, 0, nil)
+			ConveyGeneratesLength(`(?i)(hello)+`, 10, &GeneratorArgs{Flags: syntax.Perl})
+		})
+
+		Convey("Generates every split of the length", func() {
+			generator, err := NewGenerator(`a+b+`, nil)
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				str, err := generator.(LengthGenerator).GenerateWithLength(5)
+				So(err, ShouldBeNil)
+				seen[str] = true
+			}
+			So(seen, ShouldResemble, map[string]bool{"abbbb": true, "aabbb": true, "aaabb": true, "aaaab": true})
+		})
+
+		Convey("Returns error for impossible lengths", func() {
+			for pattern, n := range map[string]int{
+				`abc`:                4,
+				`a+b+`:               1,
+				`(ab)+`:              3,
+				`a{2,3}`:             4,
+				`a|bcd`:              2,
+				`a+`:                 -1,
+				`[^\x00-\x{10FFFF}]`: 1,
+			} {
+				generator, err := NewGenerator(pattern, nil)
+				So(err, ShouldBeNil)
+				_, err = generator.(LengthGenerator).GenerateWithLength(n)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Respects MaxUnboundedRepeatCount", func() {
+			generator, err := NewGenerator(`a*`, &GeneratorArgs{MaxUnboundedRepeatCount: 3})
+			So(err, ShouldBeNil)
+
+			_, err = generator.(LengthGenerator).GenerateWithLength(3)
+			So(err, ShouldBeNil)
+			_, err = generator.(LengthGenerator).GenerateWithLength(4)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Returns error for lengths over MaxGenerateWithLength without trying them", func() {
+			generator, err := NewGenerator(`(a{0,1000})*`, nil)
+			So(err, ShouldBeNil)
+
+			start := time.Now()
+			_, err = generator.(LengthGenerator).GenerateWithLength(math.MaxInt32)
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+
+			_, err = generator.(LengthGenerator).GenerateWithLength(MaxGenerateWithLength + 1)
+			So(err, ShouldNotBeNil)
+			str, err := MustNewGenerator(`a*`, nil).(LengthGenerator).GenerateWithLength(MaxGenerateWithLength)
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, MaxGenerateWithLength)
+		})
+
+		Convey("Returns error for backreferences", func() {
+			generator, err := NewGenerator(`(a)\1`, nil)
+			So(err, ShouldBeNil)
+			_, err = generator.(LengthGenerator).GenerateWithLength(2)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
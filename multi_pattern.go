@@ -0,0 +1,289 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// multiPatternGenerator generates strings from one of several independently-parsed patterns, chosen at random
+// for each string like the alternatives of an alternation.
+type multiPatternGenerator struct {
+	// An alternation of the patterns' expressions, used for AST, String, and alternateWeights.
+	regexp *syntax.Regexp
+	args   *GeneratorArgs
+	// Each generator has its own args, since capture groups, backreferences, and validation are per-pattern,
+	// but they all share the RNG in args.
+	generators []*internalGenerator
+	// Cumulative weights of generators, or nil to choose uniformly.
+	cumulativeWeights []int
+}
+
+/*
+NewGeneratorFromPatterns creates a generator that returns random strings that match one of patterns. Each string
+is generated from a single pattern, chosen at random, so the generator behaves like one created from the
+alternation of all the patterns, but without having to escape or renumber anything.
+
+Each pattern is parsed on its own with args, so capture groups and backreferences are numbered within their own
+pattern. Patterns are chosen uniformly, or using args.PatternWeight if it's set. All the patterns share one RNG, so
+a seeded generator only draws one seed from args.RngSource, however many patterns there are.
+*/
+func NewGeneratorFromPatterns(patterns []string, inputArgs *GeneratorArgs) (Generator, error) {
+	if len(patterns) == 0 {
+		return nil, generatorError(nil, "no patterns")
+	}
+
+	gen := &multiPatternGenerator{
+		regexp:     &syntax.Regexp{Op: syntax.OpAlternate},
+		args:       &GeneratorArgs{},
+		generators: make([]*internalGenerator, len(patterns)),
+	}
+	if inputArgs != nil {
+		*gen.args = *inputArgs
+	}
+	if err := gen.args.initialize(); err != nil {
+		return nil, err
+	}
+
+	for i, pattern := range patterns {
+		regexp, args, err := parsePatternWithRng(pattern, inputArgs, gen.args.rng)
+		if err != nil {
+			return nil, generatorError(err, "error parsing pattern %d", i)
+		}
+
+		if gen.generators[i], err = newGenerator(regexp, args); err != nil {
+			return nil, generatorError(err, "error creating generator for pattern %d", i)
+		}
+		gen.regexp.Sub = append(gen.regexp.Sub, gen.generators[i].AST())
+	}
+
+	if gen.args.PatternWeight != nil {
+		var err error
+		if gen.cumulativeWeights, err = alternateWeights(gen.regexp, gen.args.PatternWeight); err != nil {
+			return nil, err
+		}
+	}
+	return gen, nil
+}
+
+// choose returns a generator for the next string.
+func (gen *multiPatternGenerator) choose() *internalGenerator {
+	return gen.generators[gen.chooseIndex()]
+}
+
+// chooseIndex returns the index of the generator for the next string.
+func (gen *multiPatternGenerator) chooseIndex() int {
+	if gen.args.Deterministic {
+		return 0
+	}
+	if gen.cumulativeWeights == nil {
+		return gen.args.rng.Intn(len(gen.generators))
+	}
+
+	n := gen.args.rng.Intn(gen.cumulativeWeights[len(gen.cumulativeWeights)-1])
+	i := 0
+	for gen.cumulativeWeights[i] <= n {
+		i++
+	}
+	return i
+}
+
+func (gen *multiPatternGenerator) Generate() string {
+	return gen.choose().Generate()
+}
+
+func (gen *multiPatternGenerator) generateE() (string, error) {
+	return gen.choose().generateE()
+}
+
+func (gen *multiPatternGenerator) generateBytes() []byte {
+	return gen.choose().generateBytes()
+}
+
+func (gen *multiPatternGenerator) generateTo(w io.Writer) (int, error) {
+	return gen.choose().generateTo(w)
+}
+
+func (gen *multiPatternGenerator) generateAppend(sb *strings.Builder) {
+	gen.choose().generateAppend(sb)
+}
+
+func (gen *multiPatternGenerator) generateContext(ctx context.Context) (string, error) {
+	return gen.choose().generateContext(ctx)
+}
+
+// GenerateCaptures returns the capture groups of the pattern that was chosen.
+func (gen *multiPatternGenerator) GenerateCaptures() (string, []string) {
+	return gen.choose().GenerateCaptures()
+}
+
+func (gen *multiPatternGenerator) GenerateNamed() (string, map[string]string) {
+	return gen.choose().GenerateNamed()
+}
+
+// GenerateWithFixed uses the capture group numbers of the pattern that was chosen.
+func (gen *multiPatternGenerator) GenerateWithFixed(fixed map[int]string) (string, error) {
+	return gen.choose().GenerateWithFixed(fixed)
+}
+
+// generateWithHoles uses the capture group numbers of the pattern that was chosen.
+func (gen *multiPatternGenerator) generateWithHoles(holes map[int]string) (string, error) {
+	return gen.choose().generateWithHoles(holes)
+}
+
+// GenerateWithNamed uses the capture group names of the pattern that was chosen.
+func (gen *multiPatternGenerator) GenerateWithNamed(fixed map[string]string) (string, error) {
+	return gen.choose().GenerateWithNamed(fixed)
+}
+
+// GenerateNonMatching returns a string that doesn't match any of the patterns.
+func (gen *multiPatternGenerator) GenerateNonMatching() (string, error) {
+	matchers := make([]func(string) bool, len(gen.generators))
+	for i, generator := range gen.generators {
+		var err error
+		if matchers[i], err = generator.matcher(); err != nil {
+			return "", err
+		}
+	}
+	return generateNonMatching(gen, gen.Generate, matchers, gen.args.rng)
+}
+
+// GenerateCovering chooses each pattern at least once if n is at least the number of patterns, and shares the
+// alternatives chosen so far between them.
+func (gen *multiPatternGenerator) GenerateCovering(n int) ([]string, error) {
+	return generateCovering(n, func(coverage alternateCoverage) (string, error) {
+		i, ok := coverage.chooseUncovered(gen.regexp, len(gen.generators), gen.args.rng.Intn)
+		if !ok {
+			i = gen.chooseIndex()
+		}
+		return gen.generators[i].generateCovering(coverage)
+	})
+}
+
+// GenerateWithLength tries the patterns in a random order, and returns a string from the first one that can
+// generate a string of length n.
+func (gen *multiPatternGenerator) GenerateWithLength(n int) (string, error) {
+	order := make([]int, len(gen.generators))
+	for i := range order {
+		order[i] = i
+	}
+	if !gen.args.Deterministic {
+		for i := len(order) - 1; i > 0; i-- {
+			j := gen.args.rng.Intn(i + 1)
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	var err error
+	for _, i := range order {
+		var str string
+		if str, err = gen.generators[i].GenerateWithLength(n); err == nil {
+			return str, nil
+		}
+	}
+	return "", err
+}
+
+// GenerateShortest returns the shortest string generated by any of the patterns, or the first one if more than
+// one pattern generates a string of that length.
+func (gen *multiPatternGenerator) GenerateShortest() (string, error) {
+	return gen.generateExtreme(false)
+}
+
+// GenerateLongest is like GenerateShortest, but for the longest string.
+func (gen *multiPatternGenerator) GenerateLongest() (string, error) {
+	return gen.generateExtreme(true)
+}
+
+func (gen *multiPatternGenerator) generateExtreme(longest bool) (string, error) {
+	var best string
+	bestLength := -1
+	for _, generator := range gen.generators {
+		str, err := generator.generateExtreme(longest)
+		if err != nil {
+			return "", err
+		}
+		length := utf8.RuneCountInString(str)
+		if bestLength < 0 || longest && length > bestLength || !longest && length < bestLength {
+			best, bestLength = str, length
+		}
+	}
+	return best, nil
+}
+
+// generateWithStats includes the random number drawn to choose the pattern in the stats.
+func (gen *multiPatternGenerator) generateWithStats() (string, GenStats, error) {
+	generator := gen.choose()
+	str, stats, err := generator.generateWithStats()
+	if !gen.args.Deterministic {
+		stats.IntnCalls++
+	}
+	return str, stats, err
+}
+
+// generateExplained records the choice of pattern as a decision of the alternation returned by AST.
+func (gen *multiPatternGenerator) generateExplained() (string, []Decision) {
+	i := gen.chooseIndex()
+	str, decisions := gen.generators[i].generateExplained()
+	return str, append([]Decision{{syntax.OpAlternate, gen.regexp.String(), i}}, decisions...)
+}
+
+func (gen *multiPatternGenerator) generateChecked() (string, error) {
+	return gen.choose().generateChecked()
+}
+
+func (gen *multiPatternGenerator) Reseed(seed int64) {
+	gen.args.setRng(newXorShift64Rand(rand.NewSource(seed).Int63()))
+	for _, generator := range gen.generators {
+		generator.args.rng = gen.args.rng
+	}
+}
+
+// Clone shares rng between the clones of all the patterns' generators.
+func (gen *multiPatternGenerator) Clone(rng RandSource) Generator {
+	clone := *gen
+	clone.args = gen.args.withRng(rng)
+	clone.generators = make([]*internalGenerator, len(gen.generators))
+	for i, generator := range gen.generators {
+		args := *generator.args
+		args.rng = clone.args.rng
+		clone.generators[i] = generator.withArgs(&args)
+	}
+	return &clone
+}
+
+func (gen *multiPatternGenerator) String() string {
+	return gen.regexp.String()
+}
+
+// AST returns an alternation of the simplified expressions of all the patterns.
+func (gen *multiPatternGenerator) AST() *syntax.Regexp {
+	return gen.regexp
+}
+
+// Walk visits the alternation returned by AST, and then the generators for each pattern.
+func (gen *multiPatternGenerator) Walk(fn func(op syntax.Op, regexp *syntax.Regexp)) {
+	fn(gen.regexp.Op, gen.regexp)
+	for _, generator := range gen.generators {
+		generator.Walk(fn)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewGeneratorFromPatterns(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewGeneratorFromPatterns", t, func() {
+		patterns := []string{`[a-z]{3,8}@[a-z]{3,8}\.com`, `\(\d{3}\) \d{3}-\d{4}`, `(x)\1`}
+		// regexp doesn't support backreferences, so the last pattern is checked with an equivalent expression.
+		matchers := []*regexp.Regexp{
+			regexp.MustCompile(`^(?:` + patterns[0] + `)$`),
+			regexp.MustCompile(`^(?:` + patterns[1] + `)$`),
+			regexp.MustCompile(`^xx$`),
+		}
+
+		matchingPattern := func(str string) int {
+			for i, matcher := range matchers {
+				if matcher.MatchString(str) {
+					return i
+				}
+			}
+			return -1
+		}
+
+		Convey("Generates strings matching one of the patterns", func() {
+			generator, err := NewGeneratorFromPatterns(patterns, &GeneratorArgs{
+				Flags:     syntax.Perl,
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+
+			counts := make([]int, len(patterns))
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				index := matchingPattern(str)
+				So(index, ShouldBeGreaterThanOrEqualTo, 0)
+				counts[index]++
+			}
+			for _, count := range counts {
+				So(count, ShouldBeGreaterThan, 0)
+			}
+		})
+
+		Convey("Uses PatternWeight to choose patterns", func() {
+			generator, err := NewGeneratorFromPatterns(patterns, &GeneratorArgs{
+				Flags: syntax.Perl,
+				PatternWeight: func(index, total int) int {
+					if index == 1 {
+						return 1
+					}
+					return 0
+				},
+			})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize; i++ {
+				So(matchingPattern(generator.Generate()), ShouldEqual, 1)
+			}
+		})
+
+		Convey("Only uses AlternateWeight inside patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`x(a|bb)`, `y(c|dd)`}, &GeneratorArgs{
+				AlternateWeight: func(index, total int) int {
+					if index == total-1 {
+						return 1
+					}
+					return 0
+				},
+			})
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				seen[generator.Generate()] = true
+			}
+			So(seen, ShouldResemble, map[string]bool{"xbb": true, "ydd": true})
+		})
+
+		Convey("Numbers capture groups within each pattern", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`(a)(b)`, `(c)`}, nil)
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize/10; i++ {
+				full, groups := generator.(CaptureGenerator).GenerateCaptures()
+				if full == "ab" {
+					So(groups, ShouldResemble, []string{"ab", "a", "b"})
+				} else {
+					So(groups, ShouldResemble, []string{"c", "c"})
+				}
+			}
+		})
+
+		Convey("Generates strings with a given length from a pattern that can", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a{2}`, `b{5}`}, nil)
+			So(err, ShouldBeNil)
+
+			str, err := generator.(LengthGenerator).GenerateWithLength(5)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "bbbbb")
+
+			_, err = generator.(LengthGenerator).GenerateWithLength(3)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Is repeatable with a seed", func() {
+			newGenerator := func() Generator {
+				generator, err := NewGeneratorFromPatterns(patterns, &GeneratorArgs{
+					Flags:     syntax.Perl,
+					RngSource: rand.NewSource(42),
+				})
+				So(err, ShouldBeNil)
+				return generator
+			}
+			So(GenerateN(newGenerator(), 20), ShouldResemble, GenerateN(newGenerator(), 20))
+
+			generator := newGenerator()
+			generator.(SeedableGenerator).Reseed(1)
+			reseeded := GenerateN(generator, 20)
+			generator.(SeedableGenerator).Reseed(1)
+			So(GenerateN(generator, 20), ShouldResemble, reseeded)
+		})
+
+		Convey("Draws a single seed from RngSource", func() {
+			source := rand.NewSource(42)
+			_, err := NewGeneratorFromPatterns(patterns, &GeneratorArgs{
+				Flags:     syntax.Perl,
+				RngSource: source,
+			})
+			So(err, ShouldBeNil)
+
+			expected := rand.NewSource(42)
+			expected.Int63()
+			So(source.Int63(), ShouldEqual, expected.Int63())
+		})
+
+		Convey("Exposes the patterns as an alternation", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`foo`, `ba+r`}, nil)
+			So(err, ShouldBeNil)
+			ast := generator.(InspectableGenerator).AST()
+			So(ast.Op, ShouldEqual, syntax.OpAlternate)
+			So(ast.Sub, ShouldHaveLength, 2)
+			So(generator.String(), ShouldEqual, "foo|ba+r")
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGeneratorFromPatterns(nil, nil)
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGeneratorFromPatterns([]string{`a`, `b(`}, nil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "pattern 1")
+
+			_, err = NewGeneratorFromPatterns([]string{`a`, `b`}, &GeneratorArgs{
+				PatternWeight: func(index, total int) int { return 0 },
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+// MaxNonMatchingAttempts is the number of strings GenerateNonMatching perturbs before giving up.
+const MaxNonMatchingAttempts = 100
+
+// nonMatchingRunes are the runes GenerateNonMatching inserts or substitutes: printable ASCII, and some whitespace,
+// control, and non-ASCII runes that classes often exclude.
+var nonMatchingRunes = []rune(" !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`" +
+	"abcdefghijklmnopqrstuvwxyz{|}~\t\n\r\x01\x7féß日\U0001F600")
+
+func (gen *internalGenerator) GenerateNonMatching() (string, error) {
+	matches, err := gen.matcher()
+	if err != nil {
+		return "", err
+	}
+	return generateNonMatching(gen, gen.Generate, []func(string) bool{matches}, gen.args.rng)
+}
+
+// matcher returns a function that returns true if a string matches the expression, for GenerateNonMatching.
+func (gen *internalGenerator) matcher() (func(str string) bool, error) {
+	if gen.args.hasBackreferences {
+		return nil, generatorError(nil, "GenerateNonMatching doesn't support backreferences: /%s/", gen)
+	}
+	return compileValidator(gen.regexp, false)
+}
+
+// generateNonMatching returns a perturbation of a string from generate that isn't matched by any of matchers,
+// for gen's GenerateNonMatching.
+func generateNonMatching(gen Generator, generate func() string, matchers []func(string) bool, rng RandSource) (string, error) {
+	for attempt := 0; attempt < MaxNonMatchingAttempts; attempt++ {
+		str := perturb([]rune(generate()), rng)
+		matched := false
+		for _, matches := range matchers {
+			if matches(str) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return str, nil
+		}
+	}
+	return "", generatorError(nil, "couldn't generate a string that doesn't match /%s/ in %d attempts", gen,
+		MaxNonMatchingAttempts)
+}
+
+// perturb makes a random edit to runes: substituting, deleting, or inserting a rune.
+func perturb(runes []rune, rng RandSource) string {
+	edit := 2
+	if len(runes) > 0 {
+		edit = rng.Intn(3)
+	}
+
+	switch edit {
+	case 0:
+		runes[rng.Intn(len(runes))] = nonMatchingRunes[rng.Intn(len(nonMatchingRunes))]
+	case 1:
+		i := rng.Intn(len(runes))
+		runes = append(runes[:i], runes[i+1:]...)
+	default:
+		i := rng.Intn(len(runes) + 1)
+		runes = append(runes[:i], append([]rune{nonMatchingRunes[rng.Intn(len(nonMatchingRunes))]}, runes[i:]...)...)
+	}
+	return string(runes)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateNonMatching(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateNonMatching", t, func() {
+		Convey("Doesn't match digits", func() {
+			generator, err := NewGenerator(`\d{3}`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^\d{3}$`)
+
+			for i := 0; i < SampleSize; i++ {
+				str, err := generator.(TestDataGenerator).GenerateNonMatching()
+				So(err, ShouldBeNil)
+				So(matcher.MatchString(str), ShouldBeFalse)
+			}
+		})
+
+		Convey("Doesn't match literals", func() {
+			generator, err := NewGenerator(`abc`, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize; i++ {
+				str, err := generator.(TestDataGenerator).GenerateNonMatching()
+				So(err, ShouldBeNil)
+				So(str, ShouldNotEqual, "abc")
+			}
+		})
+
+		Convey("Doesn't match any pattern", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a`, `b`}, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize; i++ {
+				str, err := generator.(TestDataGenerator).GenerateNonMatching()
+				So(err, ShouldBeNil)
+				So(str, ShouldNotBeIn, "a", "b")
+			}
+		})
+
+		Convey("Returns error if everything matches", func() {
+			generator, err := NewGenerator(`(?s).*`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+			_, err = generator.(TestDataGenerator).GenerateNonMatching()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Returns error for backreferences", func() {
+			generator, err := NewGenerator(`(a)\1`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+			_, err = generator.(TestDataGenerator).GenerateNonMatching()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalize converts the contents of buffer to form.
+func (form NormalizeForm) normalize(buffer *bytes.Buffer) {
+	var f norm.Form
+	switch form {
+	case NFC:
+		f = norm.NFC
+	case NFD:
+		f = norm.NFD
+	default:
+		return
+	}
+	if f.IsNormal(buffer.Bytes()) {
+		return
+	}
+	normalized := f.Bytes(buffer.Bytes())
+	buffer.Reset()
+	buffer.Write(normalized)
+}
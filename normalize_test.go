@@ -0,0 +1,112 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNormalizeForm(t *testing.T) {
+	t.Parallel()
+
+	Convey("NormalizeForm", t, func() {
+		// Combining acute accent and diaeresis, which compose with the preceding vowel.
+		const pattern = `[aeo\x{301}\x{308}]{30}`
+		newGenerator := func(form NormalizeForm) Generator {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource:     rand.NewSource(0),
+				Flags:         syntax.Perl,
+				NormalizeForm: form,
+			})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Doesn't normalize by default", func() {
+			generator := newGenerator(NoNormalization)
+			normal := true
+			for i := 0; i < 10; i++ {
+				normal = normal && norm.NFC.IsNormalString(generator.Generate())
+			}
+			So(normal, ShouldBeFalse)
+		})
+
+		Convey("Generates NFC", func() {
+			generator := newGenerator(NFC)
+			unnormalized := newGenerator(NoNormalization)
+			for i := 0; i < 10; i++ {
+				str := generator.Generate()
+				So(norm.NFC.IsNormalString(str), ShouldBeTrue)
+				So(str, ShouldEqual, norm.NFC.String(unnormalized.Generate()))
+			}
+		})
+
+		Convey("Generates NFD", func() {
+			generator, err := NewGenerator(`[\x{e9}\x{f6}x]{30}`, &GeneratorArgs{Flags: syntax.Perl, NormalizeForm: NFD})
+			So(err, ShouldBeNil)
+			for i := 0; i < 10; i++ {
+				str := generator.Generate()
+				So(norm.NFD.IsNormalString(str), ShouldBeTrue)
+				So(str, ShouldNotContainSubstring, "\u00e9")
+			}
+		})
+
+		Convey("Changes the length of strings", func() {
+			generator, err := NewGenerator("e\u0301", &GeneratorArgs{NormalizeForm: NFC})
+			So(err, ShouldBeNil)
+			So(generator.Generate(), ShouldEqual, "\u00e9")
+			So(len(generator.Generate()), ShouldEqual, 2)
+		})
+
+		Convey("Normalizes every method's output", func() {
+			generator, err := NewGenerator("(e)\u0301", &GeneratorArgs{NormalizeForm: NFC})
+			So(err, ShouldBeNil)
+
+			var buffer bytes.Buffer
+			n, err := GenerateTo(generator, &buffer)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(buffer.String(), ShouldEqual, "\u00e9")
+
+			str, err := GenerateE(generator)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "\u00e9")
+			So(string(GenerateBytes(generator)), ShouldEqual, "\u00e9")
+
+			str, groups := generator.(CaptureGenerator).GenerateCaptures()
+			So(str, ShouldEqual, "\u00e9")
+			So(groups[1], ShouldEqual, "e")
+		})
+
+		Convey("Checks forbidden substrings after normalizing", func() {
+			generator, err := NewGenerator("[ao]\u0308", &GeneratorArgs{
+				NormalizeForm:       NFC,
+				ForbiddenSubstrings: []string{"\u00e4"},
+			})
+			So(err, ShouldBeNil)
+			for i := 0; i < 10; i++ {
+				So(generator.Generate(), ShouldEqual, "\u00f6")
+			}
+		})
+	})
+}
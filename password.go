@@ -0,0 +1,152 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+)
+
+// DefaultPasswordLength is the default value for PasswordArgs.Length.
+const DefaultPasswordLength = 16
+
+// PasswordRequirement requires a password to contain at least Min runes from the character class Class,
+// e.g. "[0-9]".
+type PasswordRequirement struct {
+	Class string
+	Min   int
+}
+
+// PasswordArgs are the arguments for GeneratePassword.
+type PasswordArgs struct {
+	// Length of the password, in runes. Default is DefaultPasswordLength.
+	Length int
+
+	// Runes required in the password. Their minimums must add up to at most Length.
+	Requirements []PasswordRequirement
+
+	// Character class the rest of the password is generated from, e.g. "[!-~]". Default is the union of the
+	// requirements' classes.
+	Alphabet string
+
+	// Options for parsing the classes (Flags), restricting them (e.g. ASCIIOnly and ExcludeRunes), and the RNG.
+	// If nil, default values are used, except that the RNG reads from crypto/rand unless Rand, RngSource, or
+	// SeedString is set. Passwords generated from those are only as unpredictable as the RNG they choose.
+	GeneratorArgs *GeneratorArgs
+}
+
+/*
+GeneratePassword returns a random password that contains at least the minimum number of runes from each of
+args.Requirements, which a regular expression can't easily express, e.g.:
+
+	password, err := regen.GeneratePassword(regen.PasswordArgs{
+		Length: 12,
+		Requirements: []regen.PasswordRequirement{
+			{Class: "[a-z]", Min: 1},
+			{Class: "[A-Z]", Min: 1},
+			{Class: "[0-9]", Min: 2},
+		},
+	})
+
+The required runes are generated first, the rest of the password is generated from args.Alphabet, and then all the
+runes are shuffled. Each class must be an expression that matches a single rune, like "[a-z]" or "x".
+
+By default, the runes are chosen with crypto/rand, so passwords can't be predicted from earlier ones.
+*/
+func GeneratePassword(args PasswordArgs) (string, error) {
+	genArgs := &GeneratorArgs{}
+	if args.GeneratorArgs != nil {
+		*genArgs = *args.GeneratorArgs
+	}
+	if genArgs.Rand == nil && genArgs.RngSource == nil && genArgs.SeedString == "" {
+		genArgs.Rand = newCryptoRand()
+	}
+	if err := genArgs.initialize(); err != nil {
+		return "", err
+	}
+	rng := genArgs.rng
+
+	length := args.Length
+	if length == 0 {
+		length = DefaultPasswordLength
+	}
+	if length < 0 {
+		return "", generatorError(nil, "negative password length %d", length)
+	}
+
+	password := make([]rune, 0, length)
+	var alphabet *tCharClass
+	for _, requirement := range args.Requirements {
+		class, err := parsePasswordClass(requirement.Class, genArgs)
+		if err != nil {
+			return "", err
+		}
+		if requirement.Min < 0 || len(password)+requirement.Min > length {
+			return "", generatorError(nil, "password requirements need more than %d runes", length)
+		}
+		for i := 0; i < requirement.Min; i++ {
+			password = append(password, class.GetRuneAt(rng.Int31n(class.TotalSize)))
+		}
+
+		if alphabet == nil {
+			alphabet = class
+		} else {
+			alphabet = alphabet.union(class)
+		}
+	}
+
+	if args.Alphabet != "" {
+		var err error
+		if alphabet, err = parsePasswordClass(args.Alphabet, genArgs); err != nil {
+			return "", err
+		}
+	} else if alphabet == nil {
+		return "", generatorError(nil, "password has no requirements or alphabet")
+	}
+	for len(password) < length {
+		password = append(password, alphabet.GetRuneAt(rng.Int31n(alphabet.TotalSize)))
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j := rng.Intn(i + 1)
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// parsePasswordClass returns the runes matched by class, an expression that matches a single rune, restricted by
+// args.
+func parsePasswordClass(class string, args *GeneratorArgs) (*tCharClass, error) {
+	regexp, err := syntax.Parse(class, args.Flags)
+	if err != nil {
+		return nil, err
+	}
+	regexp = regexp.Simplify()
+
+	var charClass *tCharClass
+	switch {
+	case regexp.Op == syntax.OpCharClass:
+		charClass = parseCharClass(regexp.Rune)
+	case regexp.Op == syntax.OpLiteral && len(regexp.Rune) == 1 && regexp.Flags&syntax.FoldCase == 0:
+		charClass = newCharClass(regexp.Rune[0], regexp.Rune[0])
+	default:
+		return nil, generatorError(nil, "password class /%s/ doesn't match a single rune", regexp)
+	}
+	if charClass.TotalSize == 0 {
+		return nil, generatorError(nil, "password class /%s/ is empty", regexp)
+	}
+	return restrictCharClass(regexp, charClass, args)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	t.Parallel()
+
+	Convey("GeneratePassword", t, func() {
+		requirements := []PasswordRequirement{
+			{Class: "[a-z]", Min: 1},
+			{Class: "[A-Z]", Min: 1},
+			{Class: "[0-9]", Min: 2},
+			{Class: "[!@#$%]", Min: 1},
+		}
+
+		Convey("Contains every required class", func() {
+			args := PasswordArgs{
+				Length:        8,
+				Requirements:  requirements,
+				GeneratorArgs: &GeneratorArgs{RngSource: rand.NewSource(0)},
+			}
+			for i := 0; i < SampleSize; i++ {
+				password, err := GeneratePassword(args)
+				So(err, ShouldBeNil)
+				So(password, ShouldHaveLength, 8)
+				So(regexp.MustCompile(`^[a-zA-Z0-9!@#$%]{8}$`).MatchString(password), ShouldBeTrue)
+				So(regexp.MustCompile(`[a-z]`).MatchString(password), ShouldBeTrue)
+				So(regexp.MustCompile(`[A-Z]`).MatchString(password), ShouldBeTrue)
+				So(regexp.MustCompile(`[0-9].*[0-9]`).MatchString(password), ShouldBeTrue)
+				So(regexp.MustCompile(`[!@#$%]`).MatchString(password), ShouldBeTrue)
+			}
+		})
+
+		Convey("Shuffles the required runes", func() {
+			firsts := make(map[rune]bool)
+			args := PasswordArgs{
+				Length:        2,
+				Requirements:  []PasswordRequirement{{Class: "a", Min: 1}, {Class: "b", Min: 1}},
+				GeneratorArgs: &GeneratorArgs{RngSource: rand.NewSource(0)},
+			}
+			for i := 0; i < SampleSize; i++ {
+				password, err := GeneratePassword(args)
+				So(err, ShouldBeNil)
+				So(password, ShouldBeIn, "ab", "ba")
+				firsts[rune(password[0])] = true
+			}
+			So(firsts, ShouldHaveLength, 2)
+		})
+
+		Convey("Uses the alphabet and options", func() {
+			password, err := GeneratePassword(PasswordArgs{
+				Requirements:  []PasswordRequirement{{Class: `\d`, Min: 3}},
+				Alphabet:      `\pL`,
+				GeneratorArgs: &GeneratorArgs{Flags: syntax.Perl, ASCIIOnly: true},
+			})
+			So(err, ShouldBeNil)
+			So(utf8.RuneCountInString(password), ShouldEqual, DefaultPasswordLength)
+			So(regexp.MustCompile(`^[a-zA-Z0-9]*$`).MatchString(password), ShouldBeTrue)
+			So(len(regexp.MustCompile(`[0-9]`).FindAllString(password, -1)), ShouldBeGreaterThanOrEqualTo, 3)
+		})
+
+		Convey("Uses the RNG options if they're set", func() {
+			args := PasswordArgs{Length: 20, Alphabet: "[a-z]", GeneratorArgs: &GeneratorArgs{SeedString: "seed"}}
+			first, err := GeneratePassword(args)
+			So(err, ShouldBeNil)
+			second, err := GeneratePassword(args)
+			So(err, ShouldBeNil)
+			So(second, ShouldEqual, first)
+
+			args.GeneratorArgs = &GeneratorArgs{Rand: maxRandSource{}}
+			password, err := GeneratePassword(args)
+			So(err, ShouldBeNil)
+			So(password, ShouldEqual, "zzzzzzzzzzzzzzzzzzzz")
+		})
+
+		Convey("Returns errors", func() {
+			for _, args := range []PasswordArgs{
+				{Length: 4, Requirements: []PasswordRequirement{{Class: "[a-z]", Min: 3}, {Class: "[0-9]", Min: 2}}},
+				{Requirements: []PasswordRequirement{{Class: "ab", Min: 1}}},
+				{Requirements: []PasswordRequirement{{Class: "[", Min: 1}}},
+				{Length: -1, Alphabet: "[a-z]"},
+				{},
+			} {
+				_, err := GeneratePassword(args)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing/quick"
+)
+
+// quickDefaultSize is the size testing/quick uses for values it generates itself.
+const quickDefaultSize = 50
+
+/*
+QuickGenerator generates strings matching a pattern for property tests using the testing/quick package.
+
+testing/quick calls the Generate method of the zero value of a type, so a type can't carry its own pattern.
+Instead, implement quick.Generator on your own string type using GenerateString:
+
+	var emailGenerator, _ = regen.NewQuickGenerator(`[a-z]{1,10}@example\.com`, nil)
+
+	type Email string
+
+	func (Email) Generate(rand *rand.Rand, size int) reflect.Value {
+		return reflect.ValueOf(Email(emailGenerator.GenerateString(rand, size)))
+	}
+
+Or, to use the pattern for every string argument of a function, use the quick.Config returned by Config.
+*/
+type QuickGenerator struct {
+	pattern string
+	args    GeneratorArgs
+
+	lock sync.Mutex
+	// The generator for each size passed to GenerateString, which is cloned with the RNG passed with the size.
+	generators map[int]Generator
+}
+
+// NewQuickGenerator creates a QuickGenerator for pattern.
+// If args is nil, default values are used. RngSource, Rand, and MaxUnboundedRepeatCount are ignored.
+func NewQuickGenerator(pattern string, args *GeneratorArgs) (*QuickGenerator, error) {
+	g := &QuickGenerator{pattern: pattern, generators: make(map[int]Generator)}
+	if args != nil {
+		g.args = *args
+	}
+
+	// Make sure the pattern is valid, so GenerateString doesn't have to return errors.
+	if _, err := g.generator(nil, quickDefaultSize); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// generator returns a generator for size that uses rand, or a random seed if rand is nil.
+func (g *QuickGenerator) generator(rand *rand.Rand, size int) (Generator, error) {
+	// A MaxUnboundedRepeatCount of 0 means the default.
+	if size < 1 {
+		size = 1
+	}
+
+	// Clones would still use the cached generator's RNG for these, so they need a new generator to use rand.
+	if g.args.CaptureGroupHandler != nil || len(g.args.Overrides) > 0 {
+		return g.newGenerator(rand, size)
+	}
+
+	g.lock.Lock()
+	generator, ok := g.generators[size]
+	if !ok {
+		var err error
+		if generator, err = g.newGenerator(nil, size); err != nil {
+			g.lock.Unlock()
+			return nil, err
+		}
+		g.generators[size] = generator
+	}
+	g.lock.Unlock()
+
+	if rand == nil {
+		return generator.(SeedableGenerator).Clone(nil), nil
+	}
+	return generator.(SeedableGenerator).Clone(rand), nil
+}
+
+func (g *QuickGenerator) newGenerator(rand *rand.Rand, size int) (Generator, error) {
+	args := g.args
+	if rand != nil {
+		args.Rand = rand
+	}
+	args.RngSource = nil
+	args.MaxUnboundedRepeatCount = uint(size)
+	if args.MinUnboundedRepeatCount > args.MaxUnboundedRepeatCount {
+		args.MinUnboundedRepeatCount = args.MaxUnboundedRepeatCount
+	}
+
+	return NewGenerator(g.pattern, &args)
+}
+
+// GenerateString generates a string matching the pattern using rand. Unbounded repeats (e.g. "x*") generate
+// at most size instances, so smaller sizes generate shorter strings.
+// The arguments are the same as those of quick.Generator's Generate method.
+func (g *QuickGenerator) GenerateString(rand *rand.Rand, size int) string {
+	generator, err := g.generator(rand, size)
+	if err != nil {
+		// The pattern was already checked by NewQuickGenerator.
+		panic(err)
+	}
+	return generator.Generate()
+}
+
+// Config returns a quick.Config for calling quick.Check or quick.CheckEqual with f.
+// It sets every string argument of f (including arguments with a named string type) to a string matching
+// the pattern, and generates other arguments with quick.Value. Panics if f is not a function.
+func (g *QuickGenerator) Config(f interface{}) *quick.Config {
+	fType := reflect.TypeOf(f)
+	if fType == nil || fType.Kind() != reflect.Func {
+		panic("f must be a function")
+	}
+
+	return &quick.Config{
+		Values: func(values []reflect.Value, rand *rand.Rand) {
+			for i := range values {
+				t := fType.In(i)
+				if t.Kind() == reflect.String {
+					values[i] = reflect.ValueOf(g.GenerateString(rand, quickDefaultSize)).Convert(t)
+					continue
+				}
+
+				value, ok := quick.Value(t, rand)
+				if !ok {
+					panic("cannot create arbitrary value of type " + t.String())
+				}
+				values[i] = value
+			}
+		},
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+	"testing/quick"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const quickTestPattern = `[a-z]+@example\.com`
+
+var (
+	// Initialized by TestQuickGenerator, since generators can't be created until the package's init has run.
+	quickTestGenerator *QuickGenerator
+	quickTestRegexp    = regexp.MustCompile("^" + quickTestPattern + "$")
+)
+
+type quickTestEmail string
+
+func (quickTestEmail) Generate(rand *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(quickTestEmail(quickTestGenerator.GenerateString(rand, size)))
+}
+
+func TestQuickGenerator(t *testing.T) {
+	var err error
+	if quickTestGenerator, err = NewQuickGenerator(quickTestPattern, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Parallel()
+
+	Convey("QuickGenerator", t, func() {
+		Convey("Implements quick.Generator", func() {
+			err := quick.Check(func(email quickTestEmail) bool {
+				return quickTestRegexp.MatchString(string(email))
+			}, nil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Generates string arguments with Config", func() {
+			f := func(email string, n int, name quickTestEmail) bool {
+				return quickTestRegexp.MatchString(email) && quickTestRegexp.MatchString(string(name))
+			}
+			So(quick.Check(f, quickTestGenerator.Config(f)), ShouldBeNil)
+		})
+
+		Convey("Scales repeats with size", func() {
+			rng := rand.New(rand.NewSource(0))
+			longest := func(size int) int {
+				longest := 0
+				for i := 0; i < SampleSize; i++ {
+					if n := len(quickTestGenerator.GenerateString(rng, size)); n > longest {
+						longest = n
+					}
+				}
+				return longest
+			}
+
+			So(longest(0), ShouldEqual, len("a@example.com"))
+			So(longest(5), ShouldEqual, len("aaaaa@example.com"))
+			So(longest(100), ShouldBeGreaterThan, len("aaaaa@example.com"))
+		})
+
+		Convey("Is deterministic for a seeded rand", func() {
+			a := quickTestGenerator.GenerateString(rand.New(rand.NewSource(1)), 10)
+			b := quickTestGenerator.GenerateString(rand.New(rand.NewSource(1)), 10)
+			So(a, ShouldEqual, b)
+		})
+
+		Convey("Creates one generator for each size", func() {
+			generator, err := NewQuickGenerator(`x{2}y*`, nil)
+			So(err, ShouldBeNil)
+			rng := rand.New(rand.NewSource(0))
+			for i := 0; i < SampleSize; i++ {
+				So(generator.GenerateString(rng, i%3+1), ShouldStartWith, "xx")
+			}
+			// Including quickDefaultSize, which NewQuickGenerator checks the pattern with.
+			So(generator.generators, ShouldHaveLength, 4)
+		})
+
+		Convey("Uses rand with CaptureGroupHandler", func() {
+			args := &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return generator.Generate()
+				},
+			}
+			generator, err := NewQuickGenerator(`([a-z]{10})`, args)
+			So(err, ShouldBeNil)
+			a := generator.GenerateString(rand.New(rand.NewSource(1)), 10)
+			b := generator.GenerateString(rand.New(rand.NewSource(1)), 10)
+			So(a, ShouldEqual, b)
+		})
+
+		Convey("Returns error for invalid patterns", func() {
+			_, err := NewQuickGenerator(`[`, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
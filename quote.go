@@ -0,0 +1,91 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// expandQuotes returns pattern with each \Q...\E span replaced by its text with metacharacters escaped, so
+// quoted text is literal even if flags doesn't contain syntax.PerlX, which the standard parser needs to support
+// \Q...\E itself. A \Q without \E quotes the rest of the pattern. Like replaceBackreferences, it leaves
+// character classes alone.
+func expandQuotes(pattern string, flags syntax.Flags) string {
+	if flags&(syntax.Literal|syntax.PerlX) != 0 || !strings.Contains(pattern, `\Q`) {
+		return pattern
+	}
+
+	var result bytes.Buffer
+	inClass := false
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '[' && !inClass:
+			inClass = true
+			result.WriteRune(r)
+			// A ']' at the start of a class (after an optional '^') is a literal.
+			if i+1 < len(runes) && runes[i+1] == '^' {
+				i++
+				result.WriteRune(runes[i])
+			}
+			if i+1 < len(runes) && runes[i+1] == ']' {
+				i++
+				result.WriteRune(runes[i])
+			}
+
+		case r == '[' && inClass && i+1 < len(runes) && runes[i+1] == ':':
+			// Copy named ASCII classes (e.g. [:alpha:]) so their ']' doesn't end the class.
+			end := indexRunePair(runes, i+2, ':', ']')
+			if end < 0 {
+				result.WriteRune(r)
+				break
+			}
+			result.WriteString(string(runes[i : end+2]))
+			i = end + 1
+
+		case r == ']' && inClass:
+			inClass = false
+			result.WriteRune(r)
+
+		case r == '\\' && i+1 < len(runes):
+			if inClass || runes[i+1] != 'Q' {
+				result.WriteString(string(runes[i : i+2]))
+				i++
+				break
+			}
+
+			end := indexRunePair(runes, i+2, '\\', 'E')
+			if end < 0 {
+				result.WriteString(regexp.QuoteMeta(string(runes[i+2:])))
+				i = len(runes)
+				break
+			}
+			result.WriteString(regexp.QuoteMeta(string(runes[i+2 : end])))
+			i = end + 1
+
+		default:
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
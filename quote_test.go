@@ -0,0 +1,51 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQuotedLiterals(t *testing.T) {
+	t.Parallel()
+
+	Convey("Quoted literals", t, func() {
+		Convey("Are generated verbatim", func() {
+			for _, flags := range []syntax.Flags{0, syntax.Perl} {
+				generator, err := NewGenerator(`x\Qa.b\Ey`, &GeneratorArgs{Flags: flags})
+				So(err, ShouldBeNil)
+				for i := 0; i < SampleSize; i++ {
+					So(generator.Generate(), ShouldEqual, "xa.by")
+				}
+			}
+		})
+
+		Convey("Quote the rest of the pattern without \\E", func() {
+			str, err := Generate(`a\Q(b)*\1`)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, `a(b)*\1`)
+		})
+
+		Convey("Aren't expanded in character classes or escapes", func() {
+			So(expandQuotes(`[\Q]\\Qa\E`, 0), ShouldEqual, `[\Q]\\Qa\E`)
+			So(expandQuotes(`\Q[a]\E[b]`, 0), ShouldEqual, `\[a\][b]`)
+		})
+	})
+}
@@ -28,12 +28,27 @@ Expressions are parsed using the Go standard library's parser: http://golang.org
 
 Constraints
 
-"." will generate any character, not necessarily a printable one.
+"." will generate any valid character (i.e. not a surrogate), not necessarily a printable one.
+Set GeneratorArgs.PrintableOnly to only generate printable characters.
 
-"x{0,}", "x*", and "x+" will generate a random number of x's up to an arbitrary limit.
-If you care about the maximum number, specify it explicitly in the expression,
+"x{0,}", "x*", and "x+" will generate a random number of x's up to a limit, which defaults
+to DefaultMaxUnboundedRepeatCount and can be changed per-generator by setting
+GeneratorArgs.MaxUnboundedRepeatCount (and GeneratorArgs.MinUnboundedRepeatCount for the lower bound).
+E.g. setting MaxUnboundedRepeatCount to 8 keeps "x*" to at most 8 x's.
+"x{n,}" is treated as n-1 x's followed by "x+", so it generates between n and n-1+MaxUnboundedRepeatCount x's.
+If you care about the maximum number for a specific repetition, specify it explicitly in the expression,
 e.g. "x{0,256}".
 
+Anchors (e.g. "^", "$", "\A", and "\z") don't generate anything. An alternative that starts with a begin anchor,
+like "^a" in "x?(^a|b)", is only chosen where the anchor can match: at the start of the string, or for "^" without
+the syntax.OneLine flag, also after a newline. End anchors aren't checked, since they depend on what's generated
+after them, so e.g. "(a$|b)c" can generate "ac", which doesn't match.
+
+Non-greedy repeats (e.g. "x*?", "x+?", and "x{2,5}?", which require the syntax.PerlX flag) always use
+GeometricRepeatDistribution, so they generate close to the minimum number of x's, while greedy repeats use
+GeneratorArgs.RepeatDistribution. This is only a heuristic to reflect how they match: there is no input to match
+against when generating, so greediness doesn't otherwise change which strings can be generated.
+
 Flags
 
 Flags can be passed to the parser by setting them in the GeneratorArgs struct.
@@ -46,7 +61,31 @@ the flag syntax.MatchNL.
 
 The Perl character class flag is supported, and required if the pattern contains them.
 
-Unicode groups are not supported at this time. Support may be added in the future.
+Case-insensitive literals (with the syntax.FoldCase flag, or "(?i)") generate a random case variant of each rune,
+e.g. "hello" can generate "hello", "HeLLo", or "HELLO".
+
+Unicode groups (e.g. \p{Greek}, \pL, or \P{Lu}) are supported when the syntax.UnicodeGroups flag is set
+(it is included in syntax.Perl). Any script or category name known to the unicode package
+(see unicode.Scripts and unicode.Categories) may be used.
+
+Runes outside the Basic Multilingual Plane (above U+FFFF) are generated as 4-byte UTF-8, like any other rune.
+"." and broad classes like \pS rarely generate emoji, since there are so many other runes. To generate emoji,
+use EmojiCharClass in the pattern, e.g.:
+
+	regen.NewGenerator("hi "+regen.EmojiCharClass+"{1,3}", nil)
+
+Classes that contain combining marks can generate strings that aren't in any Unicode normalization form, e.g.
+"e\u0301" instead of "\u00e9". Set GeneratorArgs.NormalizeForm to normalize generated strings. This uses
+golang.org/x/text/unicode/norm, which is the only dependency outside the standard library.
+
+Backreferences
+
+Backreferences (\1 to \9) are supported, even though the Go parser doesn't support them. A backreference
+generates the same string that its group generated most recently, or nothing if the group hasn't generated
+anything (e.g. it's in an alternative that wasn't chosen).
+E.g.
+	regen.Generate(`(foo|bar)-\1`)
+will return either "foo-foo" or "bar-bar".
 
 Concurrent Use
 
@@ -63,6 +102,12 @@ the same source may get the same output. While obviously not cryptographically s
 benefit outweighs the risk of collisions. If you really care about preventing this, the solution is simple: don't
 call a single Generator from multiple goroutines.
 
+Sharing the source is also a data race, and will be reported by the race detector. If you need to share a generator
+between goroutines anyway, set GeneratorArgs.Concurrent to guard the source with a mutex. Every random number then
+requires locking, which makes generation slower, especially when many goroutines are contending for the lock.
+For high-throughput concurrent generation, set GeneratorArgs.RngPool instead, which gives each goroutine its own
+source from a sync.Pool, so they never contend for a lock.
+
 Benchmarks
 
 Benchmarks are included for creating and running generators for limited-length,
@@ -93,14 +138,116 @@ the results of running the benchmarks with minimal load are:
 package regen
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"math/rand"
 	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
+// EmojiCharClass is a character class, for use in patterns, matching the Unicode blocks that contain most emoji:
+// Miscellaneous Symbols and Pictographs, Emoticons, Transport and Map Symbols, and Supplemental Symbols and
+// Pictographs (U+1F300-U+1F6FF and U+1F900-U+1F9FF). Not every rune in these blocks is an assigned emoji.
+const EmojiCharClass = `[\x{1F300}-\x{1F6FF}\x{1F900}-\x{1F9FF}]`
+
 // DefaultMaxUnboundedRepeatCount is default value for MaxUnboundedRepeatCount.
 const DefaultMaxUnboundedRepeatCount = 4096
 
+// DefaultMaxDepth is the default value for MaxDepth. The parser rejects expressions nested more than 1000 levels
+// deep, and Simplify can expand bounded repeats into nested expressions (e.g. "x{0,1000}" is 2000 levels deep),
+// so it's high enough for any expression the parser accepts.
+const DefaultMaxDepth = 5000
+
+// RepeatDistribution is the distribution that the number of instances generated by repeat expressions
+// (e.g. "x*" and "x{1,5}") is chosen from.
+type RepeatDistribution int
+
+const (
+	// UniformRepeatDistribution chooses every count between the minimum and maximum with equal probability.
+	// E.g. "x*" generates about DefaultMaxUnboundedRepeatCount/2 x's on average.
+	UniformRepeatDistribution RepeatDistribution = iota
+
+	// GeometricRepeatDistribution starts at the minimum count and keeps adding one instance with probability 1/2,
+	// up to the maximum count. E.g. "x*" generates 1 x on average, and rarely more than a few.
+	GeometricRepeatDistribution
+)
+
+// CharClassStrategy is how character classes (e.g. "[a-z]" and ".") choose the runes they generate.
+type CharClassStrategy int
+
+const (
+	// RandomCharClassStrategy chooses each rune at random.
+	RandomCharClassStrategy CharClassStrategy = iota
+
+	// SequentialCharClassStrategy generates the runes of each class in order, one per string, and wraps around
+	// after the last one. E.g. 26 strings generated from "[a-z]" contain every letter once.
+	SequentialCharClassStrategy
+)
+
+// NormalizeForm is the Unicode normalization form that generated strings are converted to (see
+// GeneratorArgs.NormalizeForm).
+type NormalizeForm int
+
+const (
+	// NoNormalization leaves generated strings as they are.
+	NoNormalization NormalizeForm = iota
+
+	// NFC converts generated strings to Normalization Form C (canonical composition), e.g. "e\u0301" to "\u00e9".
+	NFC
+
+	// NFD converts generated strings to Normalization Form D (canonical decomposition), e.g. "\u00e9" to "e\u0301".
+	NFD
+)
+
+// DigitScript is the script decimal digits are generated in (see GeneratorArgs.DigitScript), identified by the
+// script's zero digit. Any rune that starts a run of ten Unicode decimal digits can be used, not just these constants.
+type DigitScript rune
+
+const (
+	// ASCIIDigits generates 0-9.
+	ASCIIDigits DigitScript = 0
+	// ArabicIndicDigits generates U+0660-U+0669.
+	ArabicIndicDigits DigitScript = '\u0660'
+	// ExtendedArabicIndicDigits (Persian and Urdu) generates U+06F0-U+06F9.
+	ExtendedArabicIndicDigits DigitScript = '\u06f0'
+	// DevanagariDigits generates U+0966-U+096F.
+	DevanagariDigits DigitScript = '\u0966'
+	// BengaliDigits generates U+09E6-U+09EF.
+	BengaliDigits DigitScript = '\u09e6'
+	// ThaiDigits generates U+0E50-U+0E59.
+	ThaiDigits DigitScript = '\u0e50'
+	// FullwidthDigits generates U+FF10-U+FF19.
+	FullwidthDigits DigitScript = '\uff10'
+)
+
+// MaxForbiddenSubstringAttempts is the number of times a string is generated before giving up if every string
+// contains one of GeneratorArgs.ForbiddenSubstrings.
+const MaxForbiddenSubstringAttempts = 100
+
+// ErrForbiddenSubstring is returned by generators when every string generated contained one of
+// GeneratorArgs.ForbiddenSubstrings.
+var ErrForbiddenSubstring = errors.New("every generated string contained a forbidden substring")
+
+// MaxNonEmptyAttempts is the number of times a string is generated before giving up if every string is empty
+// and GeneratorArgs.NonEmpty is set.
+const MaxNonEmptyAttempts = 100
+
+// ErrEmptyString is returned by generators when every string generated was empty and GeneratorArgs.NonEmpty is set.
+var ErrEmptyString = errors.New("every generated string was empty")
+
+// ErrMaxTotalLengthExceeded is returned by generators when the generated string would be longer than
+// GeneratorArgs.MaxTotalLength.
+var ErrMaxTotalLengthExceeded = errors.New("generated string is longer than MaxTotalLength")
+
+// ErrTimeout is returned by generators when generating a string takes longer than GeneratorArgs.Timeout.
+var ErrTimeout = errors.New("generating string took longer than Timeout")
+
 // CaptureGroupHandler is a function that is called for each capture group in a regular expression.
 // index and name are the index and name of the group. If unnamed, name is empty. The first capture group has index 0
 // (not 1, as when matching).
@@ -109,6 +256,36 @@ const DefaultMaxUnboundedRepeatCount = 4096
 // args is the args used to create the generator calling this function.
 type CaptureGroupHandler func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string
 
+// GeneratorFactory creates a Generator for a simplified expression, to override how an op is generated (see
+// GeneratorArgs.Overrides). It must return either a non-nil Generator or an error, which is returned by NewGenerator.
+// args is the args used to create the generator calling this function, e.g. to use args.Rng().
+// The Generate method of the returned generator is called every time the expression is generated.
+type GeneratorFactory func(regexp *syntax.Regexp, args *GeneratorArgs) (Generator, error)
+
+// RandSource is the source of random numbers used by generators.
+// *rand.Rand implements it, and is used by default.
+// A RandSource doesn't have to be safe for concurrent use unless the generator is used concurrently.
+type RandSource interface {
+	// Intn returns a random number in [0, n). Panics if n <= 0.
+	Intn(n int) int
+	// Int31 returns a random non-negative int32.
+	Int31() int32
+	// Int31n returns a random number in [0, n). Panics if n <= 0.
+	Int31n(n int32) int32
+	// Int63 returns a random non-negative int64.
+	Int63() int64
+}
+
+// AlternateWeight is a function that returns the relative weight of the alternative at index in an
+// alternation (e.g. `foo|bar|baz`) with total alternatives. Alternatives are chosen with probability
+// proportional to their weight, and alternatives with weight 0 are never chosen.
+//
+// It is called once for each alternative of every alternation when the generator is created, including
+// nested alternations, so it can't tell alternations with the same number of alternatives apart.
+// Note that the parser may rewrite alternations: e.g. `a|b` becomes the character class `[ab]`, and
+// `abc|abd` becomes `ab[cd]`, neither of which are alternations.
+type AlternateWeight func(index, total int) int
+
 // GeneratorArgs are arguments passed to NewGenerator that control how generators
 // are created.
 type GeneratorArgs struct {
@@ -117,58 +294,360 @@ type GeneratorArgs struct {
 	// See http://vigna.di.unimi.it/ftp/papers/xorshift.pdf.
 	RngSource rand.Source
 
-	// Default is 0 (syntax.POSIX).
+	// May be nil.
+	// If set, used directly by generators instead of an RNG seeded from RngSource (which is then ignored).
+	// Use it to generate strings from e.g. a cryptographically secure source, or fixed values in tests.
+	Rand RandSource
+
+	// May be empty.
+	// If set, and Rand and RngSource are not, the RNG is seeded with a hash of this string, so e.g. using the
+	// name of a test always generates the same strings for that test.
+	// The RNG is chosen from Rand, then RngSource, then SeedString. If none are set, a random seed is used.
+	SeedString string
+
+	// Default is 0 (syntax.POSIX). Text quoted with \Q...\E is literal even without syntax.PerlX.
 	Flags syntax.Flags
 
 	// Maximum number of instances to generate for unbounded repeat expressions (e.g. ".*" and "{1,}")
+	// For "x{n,}" with n > 0, this limits the number of instances after the first n-1.
 	// Default is DefaultMaxUnboundedRepeatCount.
 	MaxUnboundedRepeatCount uint
 	// Minimum number of instances to generate for unbounded repeat expressions (e.g. ".*")
 	// Default is 0.
 	MinUnboundedRepeatCount uint
+	// If greater than 0, raises the minimum number of instances of every repeat expression to this, e.g. so "x*"
+	// and "x?" always generate at least one x for fixtures that must be non-empty. Bounded repeats are clamped to
+	// their maximum, so with 3, "x?" generates "x" and "x{1,2}" generates "xx". "x*" and "x+" are treated as
+	// "x{n,}". The expression returned by AST reflects the raised minimums.
+	// Default is 0.
+	MinRepeatOverride int
+
+	// Maximum number of strings returned by GenerateAll.
+	// Default is DefaultMaxGenerateAllCount.
+	MaxGenerateAllCount int
+
+	// Maximum depth of the tree of generators built for the simplified expression. NewGenerator and CanGenerate
+	// return an error for deeper expressions, e.g. to limit the work done for untrusted patterns.
+	// Default is DefaultMaxDepth.
+	MaxDepth int
+
+	// If greater than 0, alternations nested more deeply than this in other alternations always generate their
+	// shortest alternative (the first one, if more than one is shortest), e.g. to keep strings generated from
+	// machine-produced grammars simple. With 1, "(a|(bb|c)d)" generates "a" or "cd". AvoidEmptyAlternates and
+	// DedupeAlternates are applied first. The parser turns alternations of single runes (e.g. "a|b") into character
+	// classes, and merges non-capturing alternations into the alternation they're an alternative of (e.g.
+	// "x|(?:y|zz)" into "x|y|zz"), so they aren't limited. Ignored by GenerateWithLength, GenerateShortest,
+	// GenerateLongest, and GenerateAll.
+	// Default is 0 (no limit).
+	MaxAlternateDepth int
+
+	// Distribution of the number of instances generated for greedy repeat expressions.
+	// Non-greedy repeats (e.g. "x*?") always use GeometricRepeatDistribution.
+	// Default is UniformRepeatDistribution.
+	RepeatDistribution RepeatDistribution
+
+	// How character classes choose runes. With SequentialCharClassStrategy, each class in the expression keeps its
+	// own position, which advances every time it generates a rune, so the output depends on the order strings
+	// are generated in, and on every other string generated by the generator (and its clones), even from other
+	// goroutines. Deterministic takes precedence, and GenerateCovering covers runes first.
+	// Default is RandomCharClassStrategy.
+	CharClassStrategy CharClassStrategy
+
+	// If true, generators don't use the RNG at all and always make the first possible choice: the first
+	// alternative, the minimum number of repetitions, and the first rune of a character class.
+	// This produces a single canonical string for an expression, which is useful for golden-file tests.
+	Deterministic bool
+
+	// If true, "." generates a single arbitrary byte (0x00-0xFF) instead of a rune, so the length of the
+	// generated string is the number of bytes matched and it may not be valid UTF-8.
+	// "\n" is still only generated if the syntax.DotNL flag is set.
+	// (The Go parser doesn't support the "\C" any-byte escape, so "." is the only way to get this.)
+	ByteMode bool
+
+	// If true, "." only generates printable runes, as defined by unicode.IsPrint, in every form: with or without
+	// the syntax.DotNL flag, and even if RawAnyChar is set. Only "." is affected: character classes, including
+	// negated ones like "[^a]", generate the runes they contain. Ignored if ByteMode is set.
+	PrintableOnly bool
+
+	// By default, "." generates any valid rune (excluding surrogates, and newlines unless the syntax.DotNL flag is set).
+	// If true, "." generates the arbitrary, possibly invalid, runes that it did in earlier versions instead.
+	// Ignored if ByteMode, PrintableOnly, or ASCIIOnly is set.
+	RawAnyChar bool
+
+	// If true, character classes (including ".", "\w", and negated classes) only generate ASCII characters
+	// (0x01-0x7F). NewGenerator returns an error if a class doesn't contain any ASCII characters.
+	// Literals are not affected.
+	ASCIIOnly bool
+
+	// If not empty, character classes (including "." and negated classes) only generate these runes, e.g. to
+	// restrict generated tokens to a fixed alphabet. In ByteMode, "." only generates the bytes of runes less than 256.
+	// NewGenerator returns an error if a class doesn't contain any allowed runes. Literals are not affected.
+	AllowRunes []rune
+
+	// Runes that are never generated by character classes (including "." and negated classes), e.g. to keep
+	// quotes out of generated strings. In ByteMode, runes less than 256 also exclude the corresponding byte from ".".
+	// NewGenerator returns an error if a class only contains excluded runes. Literals are not affected.
+	ExcludeRunes []rune
+
+	// Weights of runes generated by character classes (including "." and negated classes), e.g. to generate
+	// letters with their frequency in English text. Each rune in a class is chosen with probability proportional
+	// to its weight, and runes that aren't in the map have a weight of 1, so e.g. with {'e': 10}, "[a-z]"
+	// generates "e" 10 times as often as each other letter. A weight of 0 excludes a rune, and NewGenerator
+	// returns an error if every rune in a class has a weight of 0. Weights must not be negative.
+	// Ignored if Deterministic is set, and by GenerateWithLength.
+	RuneWeights map[rune]int
+
+	// Substrings that generated strings must not contain, e.g. SQL keywords, even if the expression can generate
+	// them from allowed runes. This is best effort: a string that contains one is discarded and generated again, up
+	// to MaxForbiddenSubstringAttempts times, so it's only suitable for substrings that are rarely generated.
+	// If every attempt contains one, methods that return errors return ErrForbiddenSubstring, and methods that
+	// don't (e.g. Generate) return the last string generated. Strings are generated in memory before being
+	// written, even by GenerateTo. Ignored by GenerateWithLength, GenerateShortest, GenerateLongest, and
+	// GenerateAll. NewGenerator returns an error if one of them is empty.
+	ForbiddenSubstrings []string
+
+	// If true, empty strings are discarded and generated again, up to MaxNonEmptyAttempts times, e.g. so "a?" and
+	// "(foo)?" always generate their non-empty strings. Unlike MinRepeatOverride, this works for any expression
+	// that can generate something, but it's best effort in the same way as ForbiddenSubstrings: if every attempt is
+	// empty, methods that return errors return ErrEmptyString, and methods that don't (e.g. Generate) return "".
+	// NewGenerator returns an error if the expression can only generate the empty string (e.g. "" or "^$"), unless
+	// CaptureGroupHandler or Overrides is set, since they could generate something. Strings are generated in memory
+	// before being written, even by GenerateTo. Ignored by GenerateWithLength, GenerateShortest, GenerateLongest,
+	// and GenerateAll.
+	NonEmpty bool
+
+	// If greater than 0, each character class (including ".") only generates this many distinct runes, chosen at
+	// random from the class when the generator is created. This makes strings from huge classes like \pL look less
+	// like random noise. A class repeated by a repeat expression (e.g. `\pL{5}`) is only sampled once.
+	// Doesn't affect "." in ByteMode or with RawAnyChar, GenerateWithLength, or GenerateAll.
+	// Default is 0 (no limit).
+	MaxDistinctRunesPerClass int
+
+	// The script that character classes containing exactly the ASCII digits (e.g. "\d" and "[0-9]") generate
+	// digits in instead, e.g. DevanagariDigits for i18n test data. Other classes aren't affected, so e.g. "\w"
+	// still generates ASCII digits. The generated strings don't match the expression, so this can't be used with
+	// Validate. Ignored by GenerateWithLength, GenerateShortest, GenerateLongest, and GenerateAll.
+	// Default is ASCIIDigits.
+	DigitScript DigitScript
+
+	// The Unicode normalization form generated strings are converted to, e.g. NFC for systems that compare strings
+	// in NFC. Broad classes like "." and \pM can generate combining marks, so strings aren't normalized otherwise.
+	// The whole string is normalized once it's generated, so normalization may change its length (e.g. "e\u0301"
+	// is 3 bytes, and its NFC form is 2), and MaxTotalLength and capture groups apply to the string before it's
+	// normalized. The normalized string may not match the expression, so this shouldn't be used with Validate.
+	// Strings are generated in memory before being written, even by GenerateTo. Ignored by GenerateWithLength,
+	// GenerateShortest, GenerateLongest, and GenerateAll.
+	// Default is NoNormalization.
+	NormalizeForm NormalizeForm
+
+	// If true, character classes (including ".") generate runes that satisfy preceding word boundaries (\b and \B)
+	// where they can: e.g. "foo\b." generates a non-word rune after "foo". By default, word boundaries are ignored,
+	// so they may be generated between two word runes. Literals can't be changed, so e.g. "a\bb" still doesn't
+	// generate matching strings. Ignored by GenerateWithLength and GenerateAll.
+	WordBoundaries bool
+
+	// If true, identical alternatives of an alternation are only chosen as often as one alternative, e.g.
+	// "foo|bar|foo" generates "foo" and "bar" equally often instead of "foo" twice as often. Alternatives are
+	// identical if they're structurally equal after simplification (see syntax.Regexp.Equal), so capture groups
+	// with different numbers are always distinct. AlternateWeight is called with the indices of the remaining
+	// alternatives.
+	DedupeAlternates bool
+
+	// If true, alternatives that only match the empty string are never chosen, unless every alternative of the
+	// alternation does, e.g. "(a|b|)" always generates "a" or "b". Like DedupeAlternates, AlternateWeight is
+	// called with the indices of the remaining alternatives.
+	AvoidEmptyAlternates bool
+
+	// If true, PCRE lookaround assertions ("(?=...)", "(?!...)", "(?<=...)", and "(?<!...)"), which the standard
+	// parser rejects, are removed from the pattern before it's parsed, and atomic groups ("(?>...)") are treated
+	// as non-capturing groups. The removed assertions are ignored, like anchors, so generated strings may not
+	// satisfy them: e.g. "foo(?=bar)" generates "foo".
+	IgnoreUnsupportedAssertions bool
+
+	// If true, descending ranges in character classes, which the standard parser rejects, are reversed before
+	// the pattern is parsed, so e.g. "[z-a]" is treated as "[a-z]". This is a lenient mode for patterns emitted by
+	// other tools that accept them, so it's off by default: usually a descending range is a mistake.
+	AllowDescendingRanges bool
+
+	// If true, access to the RNG is guarded by a mutex, so the generator can be used by multiple goroutines
+	// without data races. This makes generation slower. See the package documentation for details.
+	Concurrent bool
+
+	// If true, generators use RNGs from a sync.Pool, so the generator can be used by multiple goroutines without
+	// data races or lock contention. Each RNG in the pool is seeded from the RNG that would be used otherwise
+	// (i.e. from Rand, or seeded from RngSource). Concurrent is ignored if this is set.
+	// The pool creates and discards RNGs at any time, and strings are generated by whichever RNG is free, so
+	// generators aren't reproducible even if Rand, RngSource, or SeedString is set. This includes the generators
+	// returned by NewGeneratorCached, which always set RngPool.
+	RngPool bool
+
+	// If true, NewGenerator also compiles the expression with the regexp package, and GenerateChecked returns
+	// an error if a generated string doesn't match it. This is a debugging aid for finding expressions that
+	// aren't generated correctly, and makes generation slower. Not supported with backreferences.
+	Validate bool
+
+	// Maximum number of bytes to generate. If generating a string would exceed it, generation stops
+	// and methods that return errors return ErrMaxTotalLengthExceeded. Methods that don't
+	// return errors (e.g. Generate) return the output generated up to that point instead.
+	// Default is 0 (no limit).
+	MaxTotalLength int
+
+	// Maximum time to spend generating a single string, e.g. to guard against pathological patterns from
+	// untrusted input without managing a context. If it's exceeded, generation stops like for MaxTotalLength,
+	// and methods that return errors return ErrTimeout. The time is only checked periodically, like the context
+	// passed to GenerateContext, so generation may continue for a short time afterwards.
+	// Default is 0 (no limit).
+	Timeout time.Duration
+
+	// If greater than 0, repeats stop repeating once this many bytes have been generated, so strings stay roughly
+	// within it instead of failing like MaxTotalLength. Repeats still generate their minimum number of instances,
+	// and literals and other required parts are always generated, so strings may still be longer.
+	// Ignored by GenerateWithLength, GenerateShortest, GenerateLongest, and GenerateAll.
+	// Default is 0 (no limit).
+	SoftMaxLength int
+
+	// Set this to choose some alternatives more often than others. The zero value chooses each alternative
+	// with equal probability.
+	AlternateWeight AlternateWeight
+
+	// Set this to choose some of the patterns passed to NewGeneratorFromPatterns more often than others. It's called
+	// with the index of each pattern and the number of patterns, like AlternateWeight, which only applies to the
+	// alternations inside the patterns. The zero value chooses each pattern with equal probability.
+	PatternWeight AlternateWeight
 
 	// Set this to perform special processing of capture groups (e.g. `(\w+)`). The zero value will generate strings
 	// from the expressions in the group.
 	CaptureGroupHandler CaptureGroupHandler
 
+	// Set this to override how expressions with specific ops are generated: e.g. an override for syntax.OpCapture
+	// could generate a valid-looking email for a group named "email". Overrides are consulted before the built-in
+	// generators, so they can also add support for ops that aren't supported otherwise. Sub-expressions of an
+	// overridden expression are only generated if the override does it. Only used by methods that generate random
+	// strings: GenerateWithLength, GenerateShortest, GenerateLongest, and GenerateAll ignore them.
+	Overrides map[syntax.Op]GeneratorFactory
+
 	// Used by generators.
-	rng *rand.Rand
+	rng RandSource
+
+	// Returns true if a string matches the expression. Only set if Validate is set.
+	validator func(str string) bool
+
+	// Number of capture groups in the expression, not counting backreferences.
+	numCaptureGroups int
+	// Names of the capture groups, indexed like regexp submatches. Unnamed groups have empty names.
+	captureNames []string
+	// True if the expression contains backreferences, so the output of capture groups must be recorded.
+	hasBackreferences bool
+	// The runes sampled from each character class for MaxDistinctRunesPerClass, keyed by expression.
+	sampledClasses map[*syntax.Regexp]*tCharClass
+	// The depth of the generator being created, for MaxDepth.
+	depth int
+	// The number of alternations containing the generator being created, including itself, for
+	// MaxAlternateDepth.
+	alternateDepth int
 }
 
 func (a *GeneratorArgs) initialize() error {
-	var seed int64
-	if nil == a.RngSource {
-		seed = rand.Int63()
+	if a.Rand != nil {
+		a.setRng(a.Rand)
 	} else {
-		seed = a.RngSource.Int63()
-	}
-	rngSource := xorShift64Source(seed)
-	a.rng = rand.New(&rngSource)
-
-	// unicode groups only allowed with Perl
-	if (a.Flags&syntax.UnicodeGroups) == syntax.UnicodeGroups && (a.Flags&syntax.Perl) != syntax.Perl {
-		return generatorError(nil, "UnicodeGroups not supported")
+		var seed int64
+		if a.RngSource != nil {
+			seed = a.RngSource.Int63()
+		} else if a.SeedString != "" {
+			seed = seedFromString(a.SeedString)
+		} else {
+			seed = rand.Int63()
+		}
+		a.setRng(newXorShift64Rand(seed))
 	}
 
 	if a.MaxUnboundedRepeatCount < 1 {
 		a.MaxUnboundedRepeatCount = DefaultMaxUnboundedRepeatCount
 	}
 
+	if a.MaxGenerateAllCount < 1 {
+		a.MaxGenerateAllCount = DefaultMaxGenerateAllCount
+	}
+
+	if a.MaxDepth < 1 {
+		a.MaxDepth = DefaultMaxDepth
+	}
+
+	for r, weight := range a.RuneWeights {
+		if weight < 0 {
+			return generatorError(nil, "negative weight %d for rune %q in RuneWeights", weight, r)
+		}
+	}
+
+	for _, substring := range a.ForbiddenSubstrings {
+		if substring == "" {
+			return generatorError(nil, "ForbiddenSubstrings contains an empty string")
+		}
+	}
+
+	if a.DigitScript != ASCIIDigits {
+		for r := rune(a.DigitScript); r < rune(a.DigitScript)+10; r++ {
+			if !unicode.IsDigit(r) {
+				return generatorError(nil, "invalid DigitScript %U: not followed by ten decimal digits", a.DigitScript)
+			}
+		}
+		if a.Validate {
+			return generatorError(nil, "DigitScript can't be used with Validate")
+		}
+	}
+
 	if a.MinUnboundedRepeatCount > a.MaxUnboundedRepeatCount {
 		panic(fmt.Sprintf("MinUnboundedRepeatCount(%d) > MaxUnboundedRepeatCount(%d)",
 			a.MinUnboundedRepeatCount, a.MaxUnboundedRepeatCount))
 	}
 
-	if a.CaptureGroupHandler == nil {
-		a.CaptureGroupHandler = defaultCaptureGroupHandler
+	return nil
+}
+
+// seedFromString returns a seed derived from the FNV-1a hash of s.
+func seedFromString(s string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(s))
+	return int64(hash.Sum64())
+}
+
+// setRng sets the RNG used by generators, guarding it with a mutex if a.Concurrent is set, or using it to
+// seed a pool of RNGs if a.RngPool is set.
+func (a *GeneratorArgs) setRng(rng RandSource) {
+	if a.RngPool {
+		rng = newPooledRandSource(rng)
+	} else if a.Concurrent {
+		rng = &lockedRandSource{source: rng}
 	}
+	a.rng = rng
+}
 
-	return nil
+// withRng returns a copy of a that uses rng, or a new randomly-seeded RNG if rng is nil, for SeedableGenerator.Clone.
+func (a *GeneratorArgs) withRng(rng RandSource) *GeneratorArgs {
+	args := *a
+	if rng == nil {
+		rng = newXorShift64Rand(rand.Int63())
+	}
+	args.setRng(rng)
+	return &args
 }
 
 // Rng returns the random number generator used by generators.
+// If they don't use a *rand.Rand (e.g. because Rand, Concurrent, or RngPool is set), the returned Rand draws
+// from their RandSource, and can't be seeded.
 // Panics if called before the GeneratorArgs has been initialized by NewGenerator.
 func (a *GeneratorArgs) Rng() *rand.Rand {
+	rng := a.RandSource()
+	if r, ok := rng.(*rand.Rand); ok {
+		return r
+	}
+	return rand.New(randSourceAdapter{rng})
+}
+
+// RandSource returns the RandSource used by generators, e.g. the one set in Rand.
+// Panics if called before the GeneratorArgs has been initialized by NewGenerator.
+func (a *GeneratorArgs) RandSource() RandSource {
 	if a.rng == nil {
 		panic("GeneratorArgs has not been initialized by NewGenerator yet")
 	}
@@ -178,9 +657,186 @@ func (a *GeneratorArgs) Rng() *rand.Rand {
 // Generator generates random strings.
 type Generator interface {
 	Generate() string
+	// String returns the simplified expression the generator generates strings from, e.g. for logging which
+	// generator produced a string.
 	String() string
 }
 
+// InspectableGenerator is a Generator that exposes the expression it generates strings from.
+// All generators returned by NewGenerator implement it, e.g.:
+//
+//	ast := generator.(regen.InspectableGenerator).AST()
+type InspectableGenerator interface {
+	Generator
+	// AST returns the simplified expression the generator was built from (i.e. syntax.Parse followed by Simplify).
+	// Backreferences appear as capture groups containing a placeholder rune.
+	// It must not be modified.
+	AST() *syntax.Regexp
+	// Walk calls fn for each generator in the tree of generators that generates strings, in depth-first order,
+	// starting with the generator itself. Unlike AST, expressions that are generated more than once (e.g. the
+	// copies of x made by Simplify for "x{3}") are visited each time, and the sub-expressions of backreferences
+	// and overridden ops aren't visited. The expressions must not be modified.
+	Walk(fn func(op syntax.Op, regexp *syntax.Regexp))
+}
+
+// CaptureGenerator is a Generator that can return or fix the output of capture groups.
+// All generators returned by NewGenerator implement it.
+type CaptureGenerator interface {
+	Generator
+	// GenerateCaptures is like Generate, but also returns the output of each capture group, numbered like
+	// the submatches returned by regexp.FindStringSubmatch: groups[0] is the whole string, and groups[i]
+	// is the output of the i-th capture group. A group that generated nothing (e.g. in a repeat that ran
+	// zero times, or an alternative that wasn't chosen) is empty. If a group generated more than once
+	// (e.g. in a repeat), its last output is returned.
+	GenerateCaptures() (full string, groups []string)
+	// GenerateNamed is like GenerateCaptures, but returns the output of each named capture group
+	// (e.g. `(?P<name>\w+)`) keyed by name. Unnamed groups are ignored.
+	// If more than one group has the same name, the last one in the expression wins.
+	GenerateNamed() (full string, groups map[string]string)
+	// GenerateWithFixed is like Generate, but capture groups numbered by the keys of fixed (like GenerateCaptures,
+	// starting at 1) generate the corresponding value instead, e.g. to fix the area code of a phone number.
+	// Backreferences to a fixed group repeat its value. It returns an error if there's no group for a key or a value
+	// doesn't match its group's expression, or ErrMaxTotalLengthExceeded if MaxTotalLength is exceeded.
+	// Groups replaced by Overrides aren't fixed.
+	GenerateWithFixed(fixed map[int]string) (string, error)
+	// GenerateWithNamed is like GenerateWithFixed, but fixes named capture groups (e.g. `(?P<year>\d{4})`) by
+	// name. If more than one group has the same name, they're all fixed. It returns an error if there's no group
+	// with a name.
+	GenerateWithNamed(fixed map[string]string) (string, error)
+}
+
+// LengthGenerator is a Generator that can generate strings of a particular length.
+// All generators returned by NewGenerator implement it.
+type LengthGenerator interface {
+	Generator
+	// GenerateWithLength generates a string that is exactly n runes long, or returns an error if the expression
+	// can't generate one, or n is longer than MaxGenerateWithLength. Unbounded repeats are still limited by
+	// MaxUnboundedRepeatCount. Backreferences and "." in ByteMode are not supported, and options that only affect
+	// how strings are chosen at random (e.g. AlternateWeight, RepeatDistribution, and CaptureGroupHandler) are
+	// ignored.
+	GenerateWithLength(n int) (string, error)
+	// GenerateShortest returns the shortest string the expression can generate, e.g. "x" for `(abc|x)+`, for
+	// boundary testing. Repeats generate their minimum number of times (MinUnboundedRepeatCount for "*"),
+	// alternations choose their shortest alternative (the first one, if there's a tie), and character classes
+	// and "." generate their smallest rune (or byte, in ByteMode). Lengths are counted in runes.
+	// Options that only affect how strings are chosen at random are ignored, as are WordBoundaries and
+	// CaptureGroupHandler. ErrMaxTotalLengthExceeded is returned if the string is longer than MaxTotalLength.
+	GenerateShortest() (string, error)
+	// GenerateLongest is like GenerateShortest, but returns the longest string, e.g. for stress-testing
+	// length limits: repeats generate their maximum number of times (MaxUnboundedRepeatCount for unbounded
+	// repeats), and alternations choose their longest alternative.
+	GenerateLongest() (string, error)
+}
+
+// TestDataGenerator is a Generator that can generate sets of strings for test suites.
+// All generators returned by NewGenerator implement it.
+type TestDataGenerator interface {
+	Generator
+	// GenerateNonMatching returns a string that almost matches the expression but is guaranteed not to, for
+	// testing that invalid input is rejected. It perturbs generated strings by substituting, deleting, or
+	// inserting a rune until one doesn't match, checked with the regexp package, and returns an error if none
+	// of MaxNonMatchingAttempts strings do (e.g. for "(?s).*", which matches everything). Backreferences aren't
+	// supported. The RNG is always used, even if Deterministic is set.
+	GenerateNonMatching() (string, error)
+	// GenerateCovering returns n strings that together choose every alternative of every alternation (including
+	// nested ones) at least once, if n is large enough. Since the parser turns alternations of single runes like
+	// "a|b|c" into character classes, every rune of each character class is also covered. Each string makes
+	// choices that no earlier string made wherever it can, so expressions inside alternatives that aren't chosen,
+	// or inside repeats that generate no instances, may need more strings to be covered. Once every choice of an
+	// expression has been made, it's chosen at random as usual.
+	GenerateCovering(n int) ([]string, error)
+}
+
+// SeedableGenerator is a Generator whose RNG can be replaced.
+// All generators returned by NewGenerator implement it.
+type SeedableGenerator interface {
+	Generator
+	// Reseed replaces the generator's RNG (including one set in GeneratorArgs.Rand) with a new one seeded from seed.
+	// The generator then generates the same sequence of strings as a new generator created with
+	// GeneratorArgs.RngSource set to rand.NewSource(seed).
+	// Reseed is not safe to call while the generator is being used by other goroutines.
+	Reseed(seed int64)
+	// Clone returns a copy of the generator that uses rng instead of the generator's RNG, so e.g. each goroutine
+	// can use its own copy without GeneratorArgs.Concurrent. The copy shares the generator's expression tree, so
+	// it's much cheaper than creating a new generator from the pattern. If rng is nil, a randomly-seeded RNG is
+	// used. rng is guarded or pooled like the original RNG if GeneratorArgs.Concurrent or RngPool is set.
+	// The generators and GeneratorArgs passed to CaptureGroupHandler, and generators created by Overrides, still
+	// use the original RNG, so clones using them aren't safe to use concurrently.
+	Clone(rng RandSource) Generator
+}
+
+// extendedGenerator is implemented by the generators created by this package, for the functions below and in
+// other files that take a Generator but need more than Generate.
+type extendedGenerator interface {
+	Generator
+	generateE() (string, error)
+	generateBytes() []byte
+	generateTo(w io.Writer) (int, error)
+	generateAppend(sb *strings.Builder)
+	generateContext(ctx context.Context) (string, error)
+	generateChecked() (string, error)
+	generateWithHoles(holes map[int]string) (string, error)
+	generateWithStats() (string, GenStats, error)
+	generateExplained() (string, []Decision)
+}
+
+// ErrUnsupportedGenerator is returned by functions that take a Generator but need one created by this package,
+// e.g. GenerateWithStats, when they're passed a different implementation.
+var ErrUnsupportedGenerator = errors.New("generator wasn't created by this package")
+
+// GenerateE is like generator.Generate, but returns an error if generation fails, along with the output generated
+// up to that point: ErrMaxTotalLengthExceeded if MaxTotalLength is exceeded, or, if GeneratorArgs.Validate is set,
+// an error if the generated string doesn't match the expression. Generators not created by this package never
+// return an error.
+func GenerateE(generator Generator) (string, error) {
+	if gen, ok := generator.(extendedGenerator); ok {
+		return gen.generateE()
+	}
+	return generator.Generate(), nil
+}
+
+// GenerateBytes is like generator.Generate, but returns the generated bytes directly, without
+// the extra allocation and copy required to convert them to a string.
+func GenerateBytes(generator Generator) []byte {
+	if gen, ok := generator.(extendedGenerator); ok {
+		return gen.generateBytes()
+	}
+	return []byte(generator.Generate())
+}
+
+// GenerateTo writes a string generated by generator to w as it is generated, instead of building the whole string
+// in memory first. It returns the number of bytes written to w, and the first error returned by w, if any.
+// Generators not created by this package generate the whole string before it's written.
+func GenerateTo(generator Generator, w io.Writer) (int, error) {
+	if gen, ok := generator.(extendedGenerator); ok {
+		return gen.generateTo(w)
+	}
+	return io.WriteString(w, generator.Generate())
+}
+
+// GenerateAppend is like generator.Generate, but appends the generated string to sb, so many strings can be built
+// into one document without allocating each of them.
+func GenerateAppend(generator Generator, sb *strings.Builder) {
+	if gen, ok := generator.(extendedGenerator); ok {
+		gen.generateAppend(sb)
+		return
+	}
+	sb.WriteString(generator.Generate())
+}
+
+// GenerateContext is like generator.Generate, but stops generating and returns ctx.Err() if ctx is done
+// before generation finishes. ctx is only checked periodically, so generation may continue for a
+// short time after ctx is done. Generators not created by this package only check ctx before generating.
+func GenerateContext(ctx context.Context, generator Generator) (string, error) {
+	if gen, ok := generator.(extendedGenerator); ok {
+		return gen.generateContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return generator.Generate(), nil
+}
+
 /*
 Generate a random string that matches the regular expression pattern.
 If args is nil, default values are used.
@@ -196,30 +852,295 @@ func Generate(pattern string) (string, error) {
 	return generator.Generate(), nil
 }
 
+// MustGenerate is like Generate, but panics if pattern can't be parsed or generated, like regexp.MustCompile.
+// It simplifies tests and the initialization of global variables with known-good patterns.
+func MustGenerate(pattern string) string {
+	str, err := Generate(pattern)
+	if err != nil {
+		panic(`regen: Generate(` + strconv.Quote(pattern) + `): ` + err.Error())
+	}
+	return str
+}
+
+// GenerateN calls generator n times and returns the results.
+// The same generator (and so the same RNG) is used for every string, so a seeded generator
+// will always return the same sequence.
+// If n <= 0, an empty slice is returned.
+func GenerateN(generator Generator, n int) []string {
+	if n < 0 {
+		n = 0
+	}
+
+	results := make([]string, n)
+	for i := range results {
+		results[i] = generator.Generate()
+	}
+	return results
+}
+
+/*
+Iterate returns a channel that receives strings from generator as they're read, for streaming strings without
+generating them all up front like GenerateN. The channel is closed after n strings, or when ctx is done.
+If n < 0, strings are generated until ctx is done.
+
+The channel is unbuffered, so at most one string is generated that isn't read. Cancel ctx to stop generating
+if the channel isn't read until it's closed, or the goroutine generating the strings will leak.
+*/
+func Iterate(ctx context.Context, generator Generator, n int) <-chan string {
+	strs := make(chan string)
+	go func() {
+		defer close(strs)
+		for i := 0; n < 0 || i < n; i++ {
+			// select chooses randomly if both cases are ready, so check ctx first to stop promptly.
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case strs <- generator.Generate():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return strs
+}
+
 // NewGenerator creates a generator that returns random strings that match the regular expression in pattern.
 // If args is nil, default values are used.
 func NewGenerator(pattern string, inputArgs *GeneratorArgs) (generator Generator, err error) {
-	args := GeneratorArgs{}
+	regexp, args, err := parsePattern(pattern, inputArgs)
+	if err != nil {
+		return
+	}
+
+	var gen *internalGenerator
+	gen, err = newGenerator(regexp, args)
+	if err != nil {
+		return
+	}
+
+	return gen, nil
+}
+
+// MustNewGenerator is like NewGenerator, but panics if pattern can't be parsed or generated, like
+// regexp.MustCompile. It simplifies tests and the initialization of global variables with known-good patterns.
+func MustNewGenerator(pattern string, args *GeneratorArgs) Generator {
+	generator, err := NewGenerator(pattern, args)
+	if err != nil {
+		panic(`regen: NewGenerator(` + strconv.Quote(pattern) + `): ` + err.Error())
+	}
+	return generator
+}
+
+/*
+NewPOSIXGenerator is like NewGenerator, but restricts pattern to POSIX ERE (egrep) syntax, like
+regexp.CompilePOSIX, for patterns written for grep -E and similar tools. The flags in syntax.Perl are cleared from
+args.Flags, so e.g. "\d", "\pL", and "(?:x)" are rejected, and "^" and "$" are treated as line anchors.
+Other flags, like syntax.FoldCase and syntax.DotNL, are kept. If args is nil, default values are used.
+
+POSIX leftmost-longest matching only changes which part of a string is matched, not which strings match, so it
+doesn't affect the strings generated. Basic regular expressions (BRE), where e.g. grouping is written "\(x\)", aren't
+supported: convert them to ERE first.
+*/
+func NewPOSIXGenerator(pattern string, inputArgs *GeneratorArgs) (Generator, error) {
+	args := &GeneratorArgs{}
+	if inputArgs != nil {
+		*args = *inputArgs
+	}
+	args.Flags &^= syntax.Perl
+	return NewGenerator(pattern, args)
+}
+
+/*
+NewGeneratorFromRegexp is like NewGenerator, but creates a generator from an expression that has already been
+parsed, e.g. one built or transformed programmatically, without converting it back to a pattern. If args is nil,
+default values are used.
+
+args.Flags is ignored: the flags of each node of regexp (e.g. syntax.FoldCase and syntax.NonGreedy) are used
+instead, so regexp should already reflect the desired flags, as if parsed by syntax.Parse. Capture groups are
+numbered by their Cap fields. Backreferences aren't supported, since syntax.Regexp can't represent them.
+regexp isn't modified, but it must not be modified while the generator is in use.
+*/
+func NewGeneratorFromRegexp(regexp *syntax.Regexp, inputArgs *GeneratorArgs) (Generator, error) {
+	if regexp == nil {
+		return nil, generatorError(nil, "regexp is nil")
+	}
+	if err := checkSubExpressions(regexp); err != nil {
+		return nil, err
+	}
+
+	args := &GeneratorArgs{}
+	if inputArgs != nil {
+		*args = *inputArgs
+	}
+	if err := args.initialize(); err != nil {
+		return nil, err
+	}
+
+	if args.Validate {
+		var err error
+		if args.validator, err = compileValidator(regexp, false); err != nil {
+			return nil, err
+		}
+	}
+	if args.MinRepeatOverride > 0 {
+		regexp = cloneRegexp(regexp)
+		raiseRepeatMinimums(regexp, args)
+	}
+	args.numCaptureGroups = regexp.MaxCap()
+	args.captureNames = regexp.CapNames()
+	if err := checkNonEmpty(regexp, args); err != nil {
+		return nil, err
+	}
+
+	gen, err := newGenerator(regexp, args)
+	if err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// checkSubExpressions returns an error if a repeat or capture group in regexp doesn't have exactly one
+// sub-expression, which would make Simplify panic, or if a character class is malformed, which would make String
+// panic.
+func checkSubExpressions(regexp *syntax.Regexp) error {
+	switch regexp.Op {
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat, syntax.OpCapture:
+		if err := enforceSingleSub(regexp); err != nil {
+			return err
+		}
+	case syntax.OpCharClass:
+		if err := checkCharClassRunes(regexp.Rune); err != nil {
+			return generatorError(err, "invalid character class")
+		}
+	}
+	for _, sub := range regexp.Sub {
+		if err := checkSubExpressions(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneRegexp returns a copy of regexp and its sub-expressions that can be modified without modifying regexp.
+func cloneRegexp(regexp *syntax.Regexp) *syntax.Regexp {
+	clone := *regexp
+	clone.Sub = make([]*syntax.Regexp, len(regexp.Sub))
+	for i, sub := range regexp.Sub {
+		clone.Sub[i] = cloneRegexp(sub)
+	}
+	return &clone
+}
+
+// parsePatternWithRng is like parsePattern, but the returned args use rng, which is shared with other generators,
+// instead of a new RNG, so nothing is drawn from inputArgs.RngSource.
+func parsePatternWithRng(pattern string, inputArgs *GeneratorArgs, rng RandSource) (*syntax.Regexp, *GeneratorArgs, error) {
+	rngArgs := &GeneratorArgs{}
+	if inputArgs != nil {
+		*rngArgs = *inputArgs
+	}
+	// initialize doesn't create an RNG if Rand is set.
+	rngArgs.Rand = rng
+
+	regexp, args, err := parsePattern(pattern, rngArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	// initialize may have guarded or pooled rng, but it already is if it needs to be.
+	args.rng = rng
+	return regexp, args, nil
+}
+
+// parsePattern parses pattern for NewGenerator and CanGenerate, and initializes a copy of inputArgs for it.
+func parsePattern(pattern string, inputArgs *GeneratorArgs) (regexp *syntax.Regexp, args *GeneratorArgs, err error) {
+	args = &GeneratorArgs{}
 
 	// Copy inputArgs so the caller can't change them.
 	if inputArgs != nil {
-		args = *inputArgs
+		*args = *inputArgs
 	}
 	if err = args.initialize(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var regexp *syntax.Regexp
-	regexp, err = syntax.Parse(pattern, args.Flags)
+	pattern = expandQuotes(pattern, args.Flags)
+	if args.IgnoreUnsupportedAssertions {
+		if pattern, err = removeUnsupportedAssertions(pattern, args.Flags); err != nil {
+			return nil, nil, err
+		}
+	}
+	if args.AllowDescendingRanges {
+		pattern = ascendRanges(pattern, args.Flags)
+	}
+
+	var hasBackreferences bool
+	pattern, hasBackreferences, err = replaceBackreferences(pattern, args.Flags)
 	if err != nil {
-		return
+		return nil, nil, err
 	}
 
-	var gen *internalGenerator
-	gen, err = newGenerator(regexp, &args)
+	regexp, err = syntax.Parse(pattern, args.Flags)
 	if err != nil {
-		return
+		return nil, nil, err
 	}
 
-	return gen, nil
+	if args.Validate {
+		if args.validator, err = compileValidator(regexp, hasBackreferences); err != nil {
+			return nil, nil, err
+		}
+	}
+	if args.MinRepeatOverride > 0 {
+		raiseRepeatMinimums(regexp, args)
+	}
+
+	if hasBackreferences {
+		renumberCaptureGroups(regexp)
+		args.hasBackreferences = true
+	}
+	args.numCaptureGroups = regexp.MaxCap()
+	args.captureNames = regexp.CapNames()
+	if err = checkNonEmpty(regexp, args); err != nil {
+		return nil, nil, err
+	}
+	return regexp, args, nil
+}
+
+// checkNonEmpty returns an error if args.NonEmpty is set and regexp can only generate the empty string.
+func checkNonEmpty(regexp *syntax.Regexp, args *GeneratorArgs) error {
+	if !args.NonEmpty || args.CaptureGroupHandler != nil || len(args.Overrides) > 0 {
+		return nil
+	}
+	if length, _ := estimateMaxLength(regexp, args); length == 0 {
+		return generatorError(nil, "NonEmpty is set, but /%s/ can only generate the empty string", regexp)
+	}
+	return nil
+}
+
+// raiseRepeatMinimums rewrites the repeat expressions in regexp in place to generate at least
+// args.MinRepeatOverride instances, or their maximum if it's smaller.
+func raiseRepeatMinimums(regexp *syntax.Regexp, args *GeneratorArgs) {
+	for _, sub := range regexp.Sub {
+		raiseRepeatMinimums(sub, args)
+	}
+
+	min := args.MinRepeatOverride
+	switch regexp.Op {
+	case syntax.OpQuest:
+		regexp.Op, regexp.Min, regexp.Max = syntax.OpRepeat, 1, 1
+	case syntax.OpStar:
+		if min > int(args.MinUnboundedRepeatCount) {
+			regexp.Op, regexp.Min, regexp.Max = syntax.OpRepeat, min, noBound
+		}
+	case syntax.OpPlus:
+		if min > 1 {
+			regexp.Op, regexp.Min, regexp.Max = syntax.OpRepeat, min, noBound
+		}
+	case syntax.OpRepeat:
+		if regexp.Max != noBound && min > regexp.Max {
+			min = regexp.Max
+		}
+		if min > regexp.Min {
+			regexp.Min = min
+		}
+	}
 }
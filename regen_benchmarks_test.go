@@ -17,7 +17,10 @@ limitations under the License.
 package regen
 
 import (
+	"io/ioutil"
 	"math/rand"
+	"regexp/syntax"
+	"strings"
 	"testing"
 )
 
@@ -63,6 +66,7 @@ func BenchmarkComplexGeneration(b *testing.B) {
 	if err != nil {
 		panic(err)
 	}
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -70,6 +74,67 @@ func BenchmarkComplexGeneration(b *testing.B) {
 	}
 }
 
+func BenchmarkComplexGenerationBytes(b *testing.B) {
+	args := &GeneratorArgs{
+		RngSource: rngSource,
+	}
+	generator, err := NewGenerator(BigFancyRegexp, args)
+	if err != nil {
+		panic(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GenerateBytes(generator)
+	}
+}
+
+// Capture groups are generated into their own buffers, which are nested in BigFancyRegexp.
+func BenchmarkComplexGenerationCaptures(b *testing.B) {
+	args := &GeneratorArgs{
+		RngSource: rngSource,
+	}
+	generator, err := NewGenerator(BigFancyRegexp, args)
+	if err != nil {
+		panic(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.(CaptureGenerator).GenerateCaptures()
+	}
+}
+
+func benchmarkConcurrentGeneration(b *testing.B, args *GeneratorArgs) {
+	generator, err := NewGenerator(BigFancyRegexp, args)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			generator.Generate()
+		}
+	})
+}
+
+func BenchmarkComplexGenerationConcurrentMutex(b *testing.B) {
+	benchmarkConcurrentGeneration(b, &GeneratorArgs{
+		RngSource:  rngSource,
+		Concurrent: true,
+	})
+}
+
+func BenchmarkComplexGenerationConcurrentPool(b *testing.B) {
+	benchmarkConcurrentGeneration(b, &GeneratorArgs{
+		RngSource: rngSource,
+		RngPool:   true,
+	})
+}
+
 func BenchmarkLargeRepeatGenerateSerial(b *testing.B) {
 	generator, err := NewGenerator(`a{999}`, &GeneratorArgs{
 		RngSource: rand.NewSource(0),
@@ -83,3 +148,89 @@ func BenchmarkLargeRepeatGenerateSerial(b *testing.B) {
 		generator.Generate()
 	}
 }
+
+// \pL has hundreds of ranges.
+func BenchmarkManyRangeCharClassGeneration(b *testing.B) {
+	args := &GeneratorArgs{
+		RngSource: rngSource,
+		Flags:     syntax.Perl,
+	}
+	generator, err := NewGenerator(`\pL{100}`, args)
+	if err != nil {
+		panic(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.Generate()
+	}
+}
+
+// Char classes write each rune directly to the output, so generating a long run of them shouldn't allocate more
+// than the output buffer. Repeat counts over 1000 can't be parsed, so this generates [a-z]{4096} with a star.
+func BenchmarkLongCharClassRepeatGeneration(b *testing.B) {
+	generator, err := NewGenerator(`[a-z]*`, &GeneratorArgs{
+		RngSource:               rand.NewSource(0),
+		MinUnboundedRepeatCount: 4096,
+		MaxUnboundedRepeatCount: 4096,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generator.Generate()
+	}
+}
+
+func BenchmarkConcatenatedGeneration(b *testing.B) {
+	generator, err := NewGenerator(`[a-z]{3,10}`, &GeneratorArgs{RngSource: rand.NewSource(0)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		document := ""
+		for j := 0; j < 100; j++ {
+			document += generator.Generate()
+		}
+	}
+}
+
+func BenchmarkConcatenatedGenerateAppend(b *testing.B) {
+	generator, err := NewGenerator(`[a-z]{3,10}`, &GeneratorArgs{RngSource: rand.NewSource(0)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var document strings.Builder
+		for j := 0; j < 100; j++ {
+			GenerateAppend(generator, &document)
+		}
+	}
+}
+
+func BenchmarkLongCharClassRepeatGenerateTo(b *testing.B) {
+	generator, err := NewGenerator(`[a-z]*`, &GeneratorArgs{
+		RngSource:               rand.NewSource(0),
+		MinUnboundedRepeatCount: 4096,
+		MaxUnboundedRepeatCount: 4096,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GenerateTo(generator, ioutil.Discard)
+	}
+}
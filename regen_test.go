@@ -17,12 +17,21 @@ limitations under the License.
 package regen
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"regexp"
 	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/gxui/math"
 	. "github.com/smartystreets/goconvey/convey"
@@ -119,14 +128,13 @@ func TestGeneratorArgs(t *testing.T) {
 			So(err, ShouldBeNil)
 		})
 
-		Convey("Unicode groups not supported", func() {
+		Convey("Unicode groups supported", func() {
 			args := &GeneratorArgs{
 				Flags: syntax.UnicodeGroups,
 			}
 
 			err := args.initialize()
-			So(err, ShouldNotBeNil)
-			So(err.Error(), ShouldEqual, "UnicodeGroups not supported")
+			So(err, ShouldBeNil)
 		})
 
 		Convey("Panics if repeat bounds are invalid", func() {
@@ -165,6 +173,20 @@ func TestGeneratorArgs(t *testing.T) {
 			So(args.Rng(), ShouldNotBeNil)
 		})
 	})
+
+	Convey("RandSource", t, func() {
+		Convey("Panics if called before initialization", func() {
+			args := GeneratorArgs{}
+			So(func() { args.RandSource() }, ShouldPanic)
+		})
+
+		Convey("Returns the same RNG as Rng by default", func() {
+			args := GeneratorArgs{}
+			err := args.initialize()
+			So(err, ShouldBeNil)
+			So(args.RandSource(), ShouldEqual, args.Rng())
+		})
+	})
 }
 
 func TestNewGenerator(t *testing.T) {
@@ -184,13 +206,398 @@ func TestNewGenerator(t *testing.T) {
 			So(err, ShouldBeNil)
 		})
 
-		Convey("Forwards errors from args initialization", func() {
-			args := &GeneratorArgs{
-				Flags: syntax.UnicodeGroups,
+		Convey("Returns parse errors", func() {
+			_, err := NewGenerator("[", nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Exposes the simplified AST", func() {
+			for _, pattern := range []string{`a{2,4}`, `(foo|bar)+\d*`, `x?y{3,}`, `[a-c]|d`} {
+				generator, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl})
+				So(err, ShouldBeNil)
+				So(generator, ShouldImplement, (*InspectableGenerator)(nil))
+
+				parsed, err := syntax.Parse(pattern, syntax.Perl)
+				So(err, ShouldBeNil)
+				ast := generator.(InspectableGenerator).AST()
+				So(ast.Equal(parsed.Simplify()), ShouldBeTrue)
+			}
+		})
+
+		Convey("Implements the optional interfaces", func() {
+			generator, err := NewGenerator(`a`, nil)
+			So(err, ShouldBeNil)
+			multi, err := NewGeneratorFromPatterns([]string{`a`, `b`}, nil)
+			So(err, ShouldBeNil)
+			for _, generator := range []Generator{generator, multi} {
+				So(generator, ShouldImplement, (*InspectableGenerator)(nil))
+				So(generator, ShouldImplement, (*CaptureGenerator)(nil))
+				So(generator, ShouldImplement, (*LengthGenerator)(nil))
+				So(generator, ShouldImplement, (*TestDataGenerator)(nil))
+				So(generator, ShouldImplement, (*SeedableGenerator)(nil))
+				So(generator, ShouldImplement, (*extendedGenerator)(nil))
+			}
+		})
+
+		Convey("Describes the simplified pattern", func() {
+			generator, err := NewGenerator(`a{2,3}|[cb]`, nil)
+			So(err, ShouldBeNil)
+			So(fmt.Sprint(generator), ShouldEqual, `aaa?|[bc]`)
+			So(generator, ShouldImplement, (*fmt.Stringer)(nil))
+		})
+
+		Convey("Walks the generator tree", func() {
+			walk := func(generator Generator) (ops []syntax.Op, exprs []string) {
+				generator.(InspectableGenerator).Walk(func(op syntax.Op, regexp *syntax.Regexp) {
+					ops = append(ops, op)
+					exprs = append(exprs, regexp.String())
+				})
+				return
+			}
+
+			generator, err := NewGenerator(`a(b|c)*`, nil)
+			So(err, ShouldBeNil)
+			ops, exprs := walk(generator)
+			So(ops, ShouldResemble, []syntax.Op{
+				syntax.OpConcat, syntax.OpLiteral, syntax.OpStar, syntax.OpCapture, syntax.OpCharClass,
+			})
+			So(exprs, ShouldResemble, []string{`a([bc])*`, `a`, `([bc])*`, `([bc])`, `[bc]`})
+
+			generator, err = NewGenerator(`(ab){2}`, nil)
+			So(err, ShouldBeNil)
+			ops, _ = walk(generator)
+			So(ops, ShouldResemble, []syntax.Op{
+				syntax.OpConcat, syntax.OpCapture, syntax.OpLiteral, syntax.OpCapture, syntax.OpLiteral,
+			})
+
+			generator, err = NewGeneratorFromPatterns([]string{`a`, `b+`}, nil)
+			So(err, ShouldBeNil)
+			ops, _ = walk(generator)
+			So(ops, ShouldResemble, []syntax.Op{
+				syntax.OpAlternate, syntax.OpLiteral, syntax.OpPlus, syntax.OpLiteral,
+			})
+		})
+	})
+}
+
+func TestGenerateN(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateN", t, func() {
+		newGenerator := func() Generator {
+			generator, err := NewGenerator("[a-z]{5}", &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates n strings", func() {
+			results := GenerateN(newGenerator(), 10)
+			So(results, ShouldHaveLength, 10)
+			for _, result := range results {
+				So(result, ShouldHaveLength, 5)
+			}
+		})
+
+		Convey("Is reproducible with the same seed", func() {
+			So(GenerateN(newGenerator(), 10), ShouldResemble, GenerateN(newGenerator(), 10))
+		})
+
+		Convey("Returns an empty slice if n <= 0", func() {
+			for _, n := range []int{0, -1} {
+				results := GenerateN(newGenerator(), n)
+				So(results, ShouldNotBeNil)
+				So(results, ShouldBeEmpty)
+			}
+		})
+	})
+}
+
+// countingGenerator counts the number of times Generate is called.
+type countingGenerator struct {
+	Generator
+	mu    sync.Mutex
+	count int
+}
+
+func (gen *countingGenerator) Generate() string {
+	gen.mu.Lock()
+	gen.count++
+	gen.mu.Unlock()
+	return gen.Generator.Generate()
+}
+
+func TestOtherGenerators(t *testing.T) {
+	t.Parallel()
+
+	Convey("Functions that take a Generator", t, func() {
+		generator, err := NewGenerator(`abc`, nil)
+		So(err, ShouldBeNil)
+		other := &countingGenerator{Generator: generator}
+
+		Convey("Fall back to Generate", func() {
+			str, err := GenerateE(other)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "abc")
+
+			str, err = GenerateChecked(other)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "abc")
+
+			So(string(GenerateBytes(other)), ShouldEqual, "abc")
+
+			var buffer bytes.Buffer
+			n, err := GenerateTo(other, &buffer)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+			So(buffer.String(), ShouldEqual, "abc")
+
+			var sb strings.Builder
+			GenerateAppend(other, &sb)
+			So(sb.String(), ShouldEqual, "abc")
+
+			str, err = GenerateContext(context.Background(), other)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "abc")
+
+			So(other.count, ShouldEqual, 6)
+		})
+
+		Convey("Check the context before generating", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := GenerateContext(ctx, other)
+			So(err, ShouldEqual, context.Canceled)
+			So(other.count, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestMust(t *testing.T) {
+	t.Parallel()
+
+	Convey("Must", t, func() {
+		Convey("MustGenerate", func() {
+			So(MustGenerate(`a[b]c`), ShouldEqual, "abc")
+			So(func() { MustGenerate(`a(`) }, ShouldPanic)
+			So(func() { MustGenerate(`\d`) }, ShouldPanicWith, "regen: Generate(\"\\\\d\"): "+
+				"error parsing regexp: invalid escape sequence: `\\d`")
+		})
+
+		Convey("MustNewGenerator", func() {
+			generator := MustNewGenerator(`\d{3}`, &GeneratorArgs{Flags: syntax.Perl})
+			So(generator.Generate(), ShouldHaveLength, 3)
+			So(func() { MustNewGenerator(`a(`, nil) }, ShouldPanic)
+			So(func() { MustNewGenerator(`[ab]`, &GeneratorArgs{ExcludeRunes: []rune("ab")}) }, ShouldPanic)
+		})
+	})
+}
+
+func TestNewPOSIXGenerator(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewPOSIXGenerator", t, func() {
+		Convey("Generates strings that match POSIX EREs", func() {
+			patterns := []string{
+				`[[:upper:]][[:lower:]]{2,8}@[[:alnum:]]+\.(com|org)`,
+				`(ab|a)(c|bcd)*(d*)`,
+				`[[:digit:]]{3}-[[:xdigit:]]+|x+y*z?`,
+				`^[^[:space:]]+$`,
+			}
+			for _, pattern := range patterns {
+				generator, err := NewPOSIXGenerator(pattern, &GeneratorArgs{RngSource: rand.NewSource(0)})
+				So(err, ShouldBeNil)
+				matcher := regexp.MustCompilePOSIX(`^(` + pattern + `)$`)
+				for i := 0; i < 100; i++ {
+					str := generator.Generate()
+					So(matcher.MatchString(str), ShouldBeTrue)
+				}
 			}
+		})
+
+		Convey("Rejects Perl syntax", func() {
+			for _, pattern := range []string{`\d`, `(?:a)`, `\pL`} {
+				_, err := NewPOSIXGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl})
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Keeps other flags", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl | syntax.FoldCase}
+			generator, err := NewPOSIXGenerator(`abcdefghij`, args)
+			So(err, ShouldBeNil)
+			So(strings.ToLower(generator.Generate()), ShouldEqual, "abcdefghij")
+			So(generator.Generate(), ShouldNotEqual, "abcdefghij")
+			So(args.Flags, ShouldEqual, syntax.Perl|syntax.FoldCase)
+		})
+	})
+}
+
+func TestNewGeneratorFromRegexp(t *testing.T) {
+	t.Parallel()
+
+	Convey("NewGeneratorFromRegexp", t, func() {
+		literal := func(s string, flags syntax.Flags) *syntax.Regexp {
+			return &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune(s), Flags: flags}
+		}
+
+		Convey("Generates from a constructed expression", func() {
+			// (?:foo|bar)[0-9]{2}
+			regexp := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+				{Op: syntax.OpAlternate, Sub: []*syntax.Regexp{literal("foo", 0), literal("bar", 0)}},
+				{Op: syntax.OpRepeat, Min: 2, Max: 2, Sub: []*syntax.Regexp{
+					{Op: syntax.OpCharClass, Rune: []rune{'0', '9'}},
+				}},
+			}}
+			generator, err := NewGeneratorFromRegexp(regexp, &GeneratorArgs{Validate: true})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize/10; i++ {
+				_, err := GenerateChecked(generator)
+				So(err, ShouldBeNil)
+			}
+		})
+
+		Convey("Uses the flags of each node", func() {
+			regexp := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+				literal("a", 0), literal("b", syntax.FoldCase),
+			}}
+			generator, err := NewGeneratorFromRegexp(regexp, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.FoldCase,
+			})
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize/10; i++ {
+				seen[generator.Generate()] = true
+			}
+			So(seen, ShouldResemble, map[string]bool{"ab": true, "aB": true})
+		})
 
-			_, err := NewGenerator("", args)
+		Convey("Numbers capture groups by Cap", func() {
+			regexp := &syntax.Regexp{Op: syntax.OpCapture, Cap: 1, Name: "x", Sub: []*syntax.Regexp{literal("y", 0)}}
+			generator, err := NewGeneratorFromRegexp(regexp, nil)
+			So(err, ShouldBeNil)
+			_, groups := generator.(CaptureGenerator).GenerateNamed()
+			So(groups, ShouldResemble, map[string]string{"x": "y"})
+		})
+
+		Convey("Doesn't modify the expression", func() {
+			parsed, err := syntax.Parse(`a?b{1,3}`, syntax.Perl)
+			So(err, ShouldBeNil)
+			generator, err := NewGeneratorFromRegexp(parsed, &GeneratorArgs{MinRepeatOverride: 2})
+			So(err, ShouldBeNil)
+			So(generator.Generate(), ShouldStartWith, "abb")
+			So(parsed.String(), ShouldEqual, `a?b{1,3}`)
+		})
+
+		Convey("Returns error for invalid expressions", func() {
+			_, err := NewGeneratorFromRegexp(nil, nil)
 			So(err, ShouldNotBeNil)
+
+			generator, err := NewGeneratorFromRegexp(&syntax.Regexp{Op: syntax.OpPlus}, nil)
+			So(err, ShouldHaveSameTypeAs, &SubExpressionCountError{})
+			So(generator, ShouldBeNil)
+
+			_, err = NewGeneratorFromRegexp(&syntax.Regexp{Op: syntax.Op(200)}, nil)
+			So(err, ShouldHaveSameTypeAs, &UnsupportedOpError{})
+		})
+
+		Convey("Returns error for malformed character classes", func() {
+			for _, runes := range [][]rune{{'a'}, {'z', 'a'}, {'a', 'c', 'x'}} {
+				regexp := &syntax.Regexp{Op: syntax.OpCharClass, Rune: runes}
+				generator, err := NewGeneratorFromRegexp(regexp, nil)
+				So(err, ShouldNotBeNil)
+				So(generator, ShouldBeNil)
+
+				_, err = NewGeneratorFromRegexp(regexp, &GeneratorArgs{Validate: true})
+				So(err, ShouldNotBeNil)
+
+				_, err = opCharClass(regexp, &GeneratorArgs{})
+				So(err, ShouldNotBeNil)
+
+				concat := &syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+					{Op: syntax.OpLiteral, Rune: []rune{'x'}}, regexp,
+				}}
+				_, err = NewGeneratorFromRegexp(concat, nil)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestIterate(t *testing.T) {
+	t.Parallel()
+
+	Convey("Iterate", t, func() {
+		newGenerator := func() Generator {
+			generator, err := NewGenerator("[a-z]{5}", &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates n strings", func() {
+			var results []string
+			for str := range Iterate(context.Background(), newGenerator(), 10) {
+				results = append(results, str)
+			}
+			So(results, ShouldResemble, GenerateN(newGenerator(), 10))
+		})
+
+		Convey("Closes immediately if n is 0", func() {
+			_, ok := <-Iterate(context.Background(), newGenerator(), 0)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Generates until cancelled if n < 0", func() {
+			generator := &countingGenerator{Generator: newGenerator()}
+			ctx, cancel := context.WithCancel(context.Background())
+			strs := Iterate(ctx, generator, -1)
+			for i := 0; i < 100; i++ {
+				So(<-strs, ShouldHaveLength, 5)
+			}
+			cancel()
+
+			// Drain the string that may have been generated before the context was cancelled.
+			for range strs {
+			}
+			generator.mu.Lock()
+			defer generator.mu.Unlock()
+			So(generator.count, ShouldBeBetweenOrEqual, 100, 101)
+		})
+	})
+}
+
+func TestInvalidAST(t *testing.T) {
+	t.Parallel()
+
+	Convey("Invalid AST", t, func() {
+		args := &GeneratorArgs{}
+		So(args.initialize(), ShouldBeNil)
+
+		Convey("Returns error for mismatched op", func() {
+			regexp, err := syntax.Parse(`a*`, 0)
+			So(err, ShouldBeNil)
+
+			var generator *internalGenerator
+			So(func() { generator, err = opLiteral(regexp, args) }, ShouldNotPanic)
+			So(generator, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &tGeneratorError{})
+		})
+
+		Convey("Returns error for unsupported op", func() {
+			generator, err := newGenerator(&syntax.Regexp{Op: syntax.OpNoMatch}, args)
+			So(generator, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &UnsupportedOpError{})
+		})
+
+		Convey("Returns error for wrong number of sub-expressions", func() {
+			generator, err := opStar(&syntax.Regexp{Op: syntax.OpStar}, args)
+			So(generator, ShouldBeNil)
+			So(err, ShouldHaveSameTypeAs, &SubExpressionCountError{})
 		})
 	})
 }
@@ -214,6 +621,79 @@ func TestGenLiterals(t *testing.T) {
 			"a",
 			"abc",
 		)
+
+		Convey("FoldCase", func() {
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.FoldCase,
+			}
+			ConveyGeneratesStringMatching(args, "hello", "(?i)^hello$")
+			ConveyGeneratesStringMatching(args, "a1b-C", "(?i)^a1b-C$")
+			ConveyGeneratesStringMatching(args, "k", "(?i)^k$")
+
+			generator, err := NewGenerator("hello", args)
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				So(strings.EqualFold(str, "hello"), ShouldBeTrue)
+				seen[str] = true
+			}
+			So(seen, ShouldContainKey, "hello")
+			So(seen, ShouldContainKey, "HELLO")
+			So(len(seen), ShouldBeGreaterThan, 2)
+		})
+
+		Convey("Inline FoldCase", func() {
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			}
+			generator, err := NewGenerator("x(?i:ab)y", args)
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				seen[generator.Generate()] = true
+			}
+			So(seen, ShouldResemble, map[string]bool{"xaby": true, "xaBy": true, "xAby": true, "xABy": true})
+		})
+
+		Convey("Mid-pattern FoldCase", func() {
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			}
+			generator, err := NewGenerator("foo(?i)bar[x-z](?-i)baz", args)
+			So(err, ShouldBeNil)
+
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				So(str, ShouldStartWith, "foo")
+				So(str, ShouldEndWith, "baz")
+				So(strings.EqualFold(str[3:6], "bar"), ShouldBeTrue)
+				So(strings.ContainsAny(str[6:7], "xyzXYZ"), ShouldBeTrue)
+				seen[str[3:7]] = true
+			}
+			// 8 case variants of "bar", each followed by one of 6 runes.
+			So(seen, ShouldHaveLength, 8*6)
+		})
+
+		Convey("Literal flag", func() {
+			for _, pattern := range []string{
+				`a.b*`, `^(a|b)+$`, `\d{2,3}[x-z]?`, `(a)\1`, `(?i)x`, `\Q*\E`, `(`, `\`, `a{1001}`,
+			} {
+				generator, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Literal, Validate: true})
+				So(err, ShouldBeNil)
+				for i := 0; i < SampleSize/10; i++ {
+					str, err := GenerateChecked(generator)
+					So(err, ShouldBeNil)
+					So(str, ShouldEqual, pattern)
+				}
+			}
+		})
 	})
 }
 
@@ -232,6 +712,107 @@ func TestGenDotNotNl(t *testing.T) {
 				So(generator.Generate(), ShouldNotContainSubstring, "\n")
 			}
 		})
+
+		Convey("Generation classes don't contain newlines", func() {
+			regexp := &syntax.Regexp{Op: syntax.OpAnyCharNotNL}
+			for _, args := range []*GeneratorArgs{{}, {RawAnyChar: true}, {PrintableOnly: true}} {
+				for _, r := range anyCharGenerationClass(regexp, args).Ranges {
+					So('\n' < r.Start || '\n' > r.end(), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Newlines are only generated with DotNL", func() {
+			for _, rawAnyChar := range []bool{false, true} {
+				generator, err := NewGenerator(`.{100}`, &GeneratorArgs{AllowRunes: []rune("a\n"), RawAnyChar: rawAnyChar})
+				So(err, ShouldBeNil)
+				So(generator.Generate(), ShouldEqual, strings.Repeat("a", 100))
+
+				generator, err = NewGenerator(`.{100}`, &GeneratorArgs{
+					Flags:      syntax.DotNL,
+					AllowRunes: []rune("a\n"),
+					RawAnyChar: rawAnyChar,
+				})
+				So(err, ShouldBeNil)
+				So(generator.Generate(), ShouldContainSubstring, "\n")
+			}
+		})
+	})
+}
+
+func TestGenAnyChar(t *testing.T) {
+	t.Parallel()
+
+	Convey("AnyChar", t, func() {
+		generate := func(pattern string, args *GeneratorArgs) []string {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return GenerateN(generator, SampleSize/10)
+		}
+
+		Convey("Classes don't contain surrogates", func() {
+			for _, class := range []*tCharClass{anyCharClass, anyCharNotNLClass, getPrintableCharClass()} {
+				for _, r := range class.Ranges {
+					end := r.Start + rune(r.Size) - 1
+					So(end < 0xD800 || r.Start > 0xDFFF, ShouldBeTrue)
+					So(end, ShouldBeLessThanOrEqualTo, unicode.MaxRune)
+				}
+			}
+		})
+
+		Convey("No surrogates are generated", func() {
+			for _, flags := range []syntax.Flags{0, syntax.DotNL} {
+				// Invalid runes are written as utf8.RuneError. It's also a valid rune, so use a fixed seed
+				// to make sure the test doesn't fail if it's actually generated.
+				invalid := 0
+				for _, str := range generate(`.{100}`, &GeneratorArgs{RngSource: rand.NewSource(0), Flags: flags}) {
+					for _, r := range str {
+						if r == utf8.RuneError || (r >= 0xD800 && r <= 0xDFFF) {
+							invalid++
+						}
+					}
+				}
+				So(invalid, ShouldEqual, 0)
+			}
+		})
+
+		Convey("Only valid UTF-8 is generated without RawAnyChar or ByteMode", func() {
+			for _, args := range []*GeneratorArgs{
+				{},
+				{Flags: syntax.DotNL},
+				{Flags: syntax.DotNL, PrintableOnly: true},
+				{Flags: syntax.DotNL, ExcludeRunes: []rune{'a'}},
+			} {
+				for _, str := range generate(`.{100}`, args) {
+					So(utf8.ValidString(str), ShouldBeTrue)
+				}
+			}
+		})
+
+		Convey("Only printable runes are generated with PrintableOnly", func() {
+			for _, flags := range []syntax.Flags{0, syntax.DotNL} {
+				for _, args := range []*GeneratorArgs{
+					{Flags: flags, PrintableOnly: true},
+					{Flags: flags, PrintableOnly: true, RawAnyChar: true},
+				} {
+					unprintable := 0
+					for _, str := range generate(`.{100}`, args) {
+						for _, r := range str {
+							if !unicode.IsPrint(r) {
+								unprintable++
+							}
+						}
+					}
+					So(unprintable, ShouldEqual, 0)
+				}
+			}
+		})
+
+		Convey("Invalid runes are generated with RawAnyChar", func() {
+			strs := generate(`.{100}`, &GeneratorArgs{Flags: syntax.DotNL, RawAnyChar: true})
+			// Almost every int31 is too big to be a rune, and is written as utf8.RuneError.
+			So(strings.Join(strs, ""), ShouldContainSubstring, string(utf8.RuneError))
+		})
 	})
 }
 
@@ -247,26 +828,150 @@ func TestGenStringStartEnd(t *testing.T) {
 		ConveyGeneratesStringMatching(args, `^abc$`, `^abc$`)
 		ConveyGeneratesStringMatching(args, `$abc^`, `^abc$`)
 		ConveyGeneratesStringMatching(args, `a^b$c`, `^abc$`)
-	})
-}
 
-func TestGenQuestionMark(t *testing.T) {
-	t.Parallel()
+		Convey("Alternatives starting with anchors are only chosen where they match", func() {
+			conveyMatches := func(pattern string, flags syntax.Flags, expected string, args *GeneratorArgs) {
+				args.RngSource = rand.NewSource(0)
+				args.Flags = flags
+				generator, err := NewGenerator(pattern, args)
+				So(err, ShouldBeNil)
 
-	Convey("QuestionMark", t, func() {
-		ConveyGeneratesStringMatchingItself(nil,
-			"a?",
-			"(abc)?",
-			"[ab]?",
-			".?")
-	})
-}
+				// regexp.Compile uses syntax.Perl, which includes OneLine.
+				if flags&syntax.OneLine == 0 {
+					expected = `(?m)` + expected
+				}
+				matcher := regexp.MustCompile(`\A(?:` + expected + `)\z`)
+				seen := make(map[string]bool)
+				for i := 0; i < SampleSize; i++ {
+					str := generator.Generate()
+					So(matcher.MatchString(str), ShouldBeTrue)
+					seen[str] = true
+				}
+				So(len(seen), ShouldBeGreaterThan, 1)
+			}
 
-func TestGenPlus(t *testing.T) {
-	t.Parallel()
+			conveyMatches(`x?(^a|b)`, syntax.OneLine, `x?(^a|b)`, &GeneratorArgs{})
+			conveyMatches(`x?(\Aa|b)`, syntax.Perl, `x?(\Aa|b)`, &GeneratorArgs{})
+			conveyMatches(`x?(^a|b)`, 0, `x?(^a|b)`, &GeneratorArgs{})
+			conveyMatches(`(x\n)?(^a|b)`, 0, `(x\n)?(^a|b)`, &GeneratorArgs{})
+			conveyMatches(`x?((^a)c|b|^d)`, syntax.OneLine, `x?((^a)c|b|^d)`, &GeneratorArgs{
+				AlternateWeight: func(index, total int) int {
+					return []int{10, 1, 10}[index]
+				},
+			})
+		})
 
-	Convey("Plus", t, func() {
-		ConveyGeneratesStringMatchingItself(nil, "a+")
+		Convey("Alternatives starting with anchors are chosen at the start", func() {
+			generator, err := NewGenerator(`x?(^a|b)`, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+			seen := make(map[string]bool)
+			for i := 0; i < SampleSize; i++ {
+				seen[generator.Generate()] = true
+			}
+			So(seen, ShouldResemble, map[string]bool{"a": true, "b": true, "xb": true})
+		})
+	})
+}
+
+func TestGenWordBoundaries(t *testing.T) {
+	t.Parallel()
+
+	Convey("Word boundaries", t, func() {
+		args := &GeneratorArgs{
+			RngSource:      rand.NewSource(0),
+			Flags:          syntax.Perl,
+			WordBoundaries: true,
+		}
+
+		ConveyGeneratesMatchingStrings := func(args *GeneratorArgs, patterns ...string) {
+			for _, pattern := range patterns {
+				ConveyGeneratesStringMatching(args, pattern, `^(?:`+pattern+`)$`)
+			}
+		}
+
+		Convey("Generates runes that satisfy boundaries", func() {
+			ConveyGeneratesMatchingStrings(args,
+				`foo\b.`,
+				`[a-z ]{3}\b[a-z ]{3}`,
+				`[a-z ]{3}\B[a-z ]{3}`,
+				`\b[a-z !]`,
+				`\B[a-z !]`,
+				`(cat|dog)\b[a-z0-9 !]{2}`,
+				`x?\b.\B.`,
+				`(?s:a\b.)`,
+			)
+		})
+
+		Convey("Generates runes that satisfy boundaries from any char variants", func() {
+			ConveyGeneratesMatchingStrings(&GeneratorArgs{
+				Flags:          syntax.Perl,
+				WordBoundaries: true,
+				ByteMode:       true,
+				ASCIIOnly:      true,
+			}, `a\b.`, `a\B.`, `.\b.`)
+			// The patterns must have different names from the ones above.
+			ConveyGeneratesMatchingStrings(&GeneratorArgs{
+				Flags:          syntax.Perl | syntax.DotNL,
+				WordBoundaries: true,
+				RawAnyChar:     true,
+			}, `b\b.`, `b\B.`, `..\b.`)
+		})
+
+		Convey("Ignores boundaries by default", func() {
+			generator, err := NewGenerator(`[a-z ]{3}\b[a-z ]{3}`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
+
+			matcher := regexp.MustCompile(`^(?:[a-z ]{3}\b[a-z ]{3})$`)
+			mismatches := 0
+			for i := 0; i < SampleSize; i++ {
+				if !matcher.MatchString(generator.Generate()) {
+					mismatches++
+				}
+			}
+			So(mismatches, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Can't change literals", func() {
+			ConveyGeneratesStringMatching(args, `a\bb`, `^ab$`)
+		})
+
+		Convey("Doesn't look ahead at literals after boundaries", func() {
+			generator, err := NewGenerator(`[a-z ]\bfoo`, args)
+			So(err, ShouldBeNil)
+
+			matcher := regexp.MustCompile(`^(?:[a-z ]\bfoo)$`)
+			mismatches := 0
+			for i := 0; i < SampleSize; i++ {
+				if !matcher.MatchString(generator.Generate()) {
+					mismatches++
+				}
+			}
+			So(mismatches, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Treats multi-byte runes written by literals as non-word runes", func() {
+			ConveyGeneratesMatchingStrings(args, `é\B[a-zé !]`)
+		})
+	})
+}
+
+func TestGenQuestionMark(t *testing.T) {
+	t.Parallel()
+
+	Convey("QuestionMark", t, func() {
+		ConveyGeneratesStringMatchingItself(nil,
+			"a?",
+			"(abc)?",
+			"[ab]?",
+			".?")
+	})
+}
+
+func TestGenPlus(t *testing.T) {
+	t.Parallel()
+
+	Convey("Plus", t, func() {
+		ConveyGeneratesStringMatchingItself(nil, "a+")
 	})
 }
 
@@ -317,200 +1022,1895 @@ func TestGenStar(t *testing.T) {
 				RngSource:               rand.NewSource(0),
 				MaxUnboundedRepeatCount: 200,
 			}
-			counts := generateLenHistogram(regexp, 200, args)
+			counts := generateLenHistogram(regexp, 200, args)
+
+			So(len(counts), ShouldEqual, 200+1)
+			So(counts[200], ShouldBeGreaterThan, 0)
+		})
+	})
+}
+
+func TestRepeatDistribution(t *testing.T) {
+	t.Parallel()
+
+	Convey("RepeatDistribution", t, func() {
+		meanLen := func(pattern string, args *GeneratorArgs) float64 {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			total := 0
+			for i := 0; i < SampleSize; i++ {
+				total += len(generator.Generate())
+			}
+			return float64(total) / SampleSize
+		}
+
+		Convey("Geometric generates fewer instances than uniform", func() {
+			uniform := meanLen(`a*`, &GeneratorArgs{RepeatDistribution: UniformRepeatDistribution})
+			geometric := meanLen(`a*`, &GeneratorArgs{RepeatDistribution: GeometricRepeatDistribution})
+			So(uniform, ShouldBeGreaterThan, 1000)
+			So(geometric, ShouldBeLessThan, 5)
+		})
+
+		Convey("Geometric respects bounds", func() {
+			args := &GeneratorArgs{
+				RepeatDistribution:      GeometricRepeatDistribution,
+				MaxUnboundedRepeatCount: 2,
+			}
+			ConveyGeneratesStringMatching(args, `a{2,4}`, `^a{2,4}$`)
+			ConveyGeneratesStringMatching(args, `a+`, `^a{1,2}$`)
+		})
+	})
+}
+
+func TestGenNonGreedy(t *testing.T) {
+	t.Parallel()
+
+	Convey("NonGreedy", t, func() {
+		args := &GeneratorArgs{
+			RngSource: rand.NewSource(0),
+			Flags:     syntax.Perl,
+		}
+		ConveyGeneratesStringMatchingItself(args,
+			`a*?`,
+			`a+?`,
+			`a??`,
+			`a{2,5}?`,
+			`a{2,}?`,
+		)
+
+		meanLen := func(pattern string) float64 {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			total := 0
+			for i := 0; i < SampleSize; i++ {
+				total += len(generator.Generate())
+			}
+			return float64(total) / SampleSize
+		}
+
+		Convey("Generates fewer instances than greedy", func() {
+			So(meanLen(`a*`), ShouldBeGreaterThan, 1000)
+			So(meanLen(`a*?`), ShouldBeLessThan, 5)
+			So(meanLen(`a+?`), ShouldBeLessThan, 5)
+			So(meanLen(`a{10,20}?`), ShouldBeLessThan, 15)
+		})
+
+		Convey("Only affects the non-greedy repeat", func() {
+			So(meanLen(`a*?b*`), ShouldBeGreaterThan, 1000)
+		})
+	})
+}
+
+func TestGenCharClassNotNl(t *testing.T) {
+	t.Parallel()
+
+	Convey("CharClassNotNl", t, func() {
+		ConveyGeneratesStringMatchingItself(nil,
+			"[a]",
+			"[abc]",
+			"[a-d]",
+			"[ac]",
+			"[0-9]",
+			"[a-z0-9]",
+		)
+
+		Convey("No newlines are generated", func() {
+			// Try to narrow down the generation space. Still not a very strong assertion.
+			generator, _ := NewGenerator("[^a-zA-Z0-9]", nil)
+			for i := 0; i < SampleSize; i++ {
+				assert.NotEqual(t, "\n", generator.Generate())
+			}
+		})
+	})
+}
+
+func TestGenNegativeCharClass(t *testing.T) {
+	t.Parallel()
+
+	Convey("NegativeCharClass", t, func() {
+		ConveyGeneratesStringMatchingItself(nil, "[^a-zA-Z0-9]")
+	})
+}
+
+func TestGenAlternate(t *testing.T) {
+	t.Parallel()
+
+	Convey("Alternate", t, func() {
+		ConveyGeneratesStringMatchingItself(nil,
+			"a|b",
+			"abc|def|ghi",
+			"[ab]|[cd]",
+			"foo|bar|baz", // rewrites to foo|ba[rz]
+		)
+	})
+}
+
+func TestGenWeightedAlternate(t *testing.T) {
+	t.Parallel()
+
+	Convey("Weighted alternate", t, func() {
+		weights := func(weights ...int) AlternateWeight {
+			return func(index, total int) int {
+				So(total, ShouldEqual, len(weights))
+				return weights[index]
+			}
+		}
+
+		Convey("Chooses alternatives in proportion to weights", func() {
+			generator, err := NewGenerator(`foo|bar`, &GeneratorArgs{
+				RngSource:       rand.NewSource(0),
+				AlternateWeight: weights(9, 1),
+			})
+			So(err, ShouldBeNil)
+
+			counts := make(map[string]int)
+			for i := 0; i < SampleSize*10; i++ {
+				counts[generator.Generate()]++
+			}
+			So(counts, ShouldHaveLength, 2)
+			So(float64(counts["foo"])/(SampleSize*10), ShouldAlmostEqual, 0.9, 0.03)
+		})
+
+		Convey("Never chooses alternatives with weight 0", func() {
+			ConveyGeneratesStringMatching(&GeneratorArgs{AlternateWeight: weights(0, 1, 0)}, `foo|bar|qux`, `^bar$`)
+		})
+
+		Convey("Chooses first alternative with weight when deterministic", func() {
+			ConveyGeneratesStringMatching(&GeneratorArgs{
+				AlternateWeight: weights(0, 1, 1),
+				Deterministic:   true,
+			}, `foo|bar|qux`, `^bar$`)
+		})
+
+		Convey("Returns error for invalid weights", func() {
+			_, err := NewGenerator(`foo|bar`, &GeneratorArgs{AlternateWeight: weights(1, -1)})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGenerator(`foo|bar`, &GeneratorArgs{AlternateWeight: weights(0, 0)})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenDedupeAlternates(t *testing.T) {
+	t.Parallel()
+
+	Convey("DedupeAlternates", t, func() {
+		count := func(pattern string, args *GeneratorArgs) map[string]int {
+			args.RngSource = rand.NewSource(0)
+			args.Flags = syntax.Perl
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			counts := make(map[string]int)
+			for i := 0; i < SampleSize*10; i++ {
+				counts[generator.Generate()]++
+			}
+			return counts
+		}
+
+		Convey("Chooses identical alternatives as often as one", func() {
+			counts := count(`foo|bar|foo`, &GeneratorArgs{DedupeAlternates: true})
+			So(counts, ShouldHaveLength, 2)
+			So(float64(counts["foo"])/(SampleSize*10), ShouldAlmostEqual, 0.5, 0.03)
+
+			counts = count(`(?:x+|y|x{1,})z`, &GeneratorArgs{DedupeAlternates: true, MaxUnboundedRepeatCount: 1})
+			So(float64(counts["xz"])/(SampleSize*10), ShouldAlmostEqual, 0.5, 0.03)
+		})
+
+		Convey("Chooses identical alternatives more often by default", func() {
+			counts := count(`foo|bar|foo`, &GeneratorArgs{})
+			So(float64(counts["foo"])/(SampleSize*10), ShouldAlmostEqual, 2.0/3, 0.03)
+		})
+
+		Convey("Keeps capture groups with different numbers", func() {
+			generator, err := NewGenerator(`(a)|(a)`, &GeneratorArgs{DedupeAlternates: true})
+			So(err, ShouldBeNil)
+			So(generator.(InspectableGenerator).AST().Sub, ShouldHaveLength, 2)
+		})
+
+		Convey("Weights the remaining alternatives", func() {
+			counts := count(`foo|bar|foo|bar`, &GeneratorArgs{
+				DedupeAlternates: true,
+				AlternateWeight: func(index, total int) int {
+					So(total, ShouldEqual, 2)
+					return []int{1, 3}[index]
+				},
+			})
+			So(float64(counts["bar"])/(SampleSize*10), ShouldAlmostEqual, 0.75, 0.03)
+		})
+	})
+}
+
+func TestGenAvoidEmptyAlternates(t *testing.T) {
+	t.Parallel()
+
+	Convey("AvoidEmptyAlternates", t, func() {
+		count := func(pattern string, avoid bool) map[string]int {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource:            rand.NewSource(0),
+				Flags:                syntax.Perl,
+				AvoidEmptyAlternates: avoid,
+			})
+			So(err, ShouldBeNil)
+
+			counts := make(map[string]int)
+			for i := 0; i < SampleSize; i++ {
+				counts[generator.Generate()]++
+			}
+			return counts
+		}
+
+		Convey("Never chooses empty alternatives", func() {
+			counts := count(`(a|b|)`, true)
+			So(counts, ShouldNotContainKey, "")
+			So(counts, ShouldContainKey, "a")
+			So(counts, ShouldContainKey, "b")
+
+			counts = count(`x(?:|y{0}|z)`, true)
+			So(counts, ShouldResemble, map[string]int{"xz": SampleSize})
+		})
+
+		Convey("Chooses empty alternatives by default", func() {
+			So(count(`(a|b|)`, false), ShouldContainKey, "")
+		})
+
+		Convey("Generates empty strings if every alternative is empty", func() {
+			So(count(`(?:|y{0})`, true), ShouldResemble, map[string]int{"": SampleSize})
+		})
+	})
+}
+
+func TestGenMaxAlternateDepth(t *testing.T) {
+	t.Parallel()
+
+	Convey("MaxAlternateDepth", t, func() {
+		count := func(pattern string, args *GeneratorArgs) map[string]int {
+			args.RngSource = rand.NewSource(0)
+			args.Flags = syntax.Perl
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			counts := make(map[string]int)
+			for i := 0; i < SampleSize; i++ {
+				counts[generator.Generate()]++
+			}
+			return counts
+		}
+		keys := func(counts map[string]int) []string {
+			var strs []string
+			for str := range counts {
+				strs = append(strs, str)
+			}
+			sort.Strings(strs)
+			return strs
+		}
+
+		const nested = `(?:xx|(?:foo|ba|(?:quux|q1))-(?:12|3))`
+
+		Convey("Chooses the shortest alternative of deeper alternations", func() {
+			counts := count(nested, &GeneratorArgs{MaxAlternateDepth: 1})
+			So(keys(counts), ShouldResemble, []string{"ba-3", "xx"})
+
+			counts = count(nested, &GeneratorArgs{MaxAlternateDepth: 2})
+			So(keys(counts), ShouldResemble, []string{
+				"ba-12", "ba-3", "foo-12", "foo-3", "q1-12", "q1-3", "xx",
+			})
+
+			counts = count(`(a|(bb|c)d)`, &GeneratorArgs{MaxAlternateDepth: 1})
+			So(keys(counts), ShouldResemble, []string{"a", "cd"})
+		})
+
+		Convey("Chooses the first of equally short alternatives", func() {
+			counts := count(`x(?:foo|bar|(12|3))+`, &GeneratorArgs{MaxAlternateDepth: 1, MaxUnboundedRepeatCount: 1})
+			So(keys(counts), ShouldResemble, []string{"x3", "xbar", "xfoo"})
+
+			counts = count(`(?:a(?:foo|bar)|b(?:qu|x{2}))`, &GeneratorArgs{MaxAlternateDepth: 1})
+			So(keys(counts), ShouldResemble, []string{"afoo", "bqu"})
+		})
+
+		Convey("Applies AvoidEmptyAlternates first", func() {
+			counts := count(`x|a(?:foo||ba)`, &GeneratorArgs{MaxAlternateDepth: 1})
+			So(keys(counts), ShouldResemble, []string{"a", "x"})
+
+			counts = count(`x|a(?:foo||ba)`, &GeneratorArgs{MaxAlternateDepth: 1, AvoidEmptyAlternates: true})
+			So(keys(counts), ShouldResemble, []string{"aba", "x"})
+		})
+
+		Convey("Is unlimited by default", func() {
+			So(keys(count(nested, &GeneratorArgs{})), ShouldHaveLength, 9)
+		})
+
+		Convey("CanGenerate only checks the alternatives that are generated", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl, AllowRunes: []rune("ab"), MaxAlternateDepth: 1}
+			So(CanGenerate(`a|(b|[cd]{2})`, args), ShouldBeNil)
+			_, err := NewGenerator(`a|(b|[cd]{2})`, args)
+			So(err, ShouldBeNil)
+
+			args.MaxAlternateDepth = 0
+			So(CanGenerate(`a|(b|[cd]{2})`, args), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenCapture(t *testing.T) {
+	t.Parallel()
+
+	Convey("Capture", t, func() {
+		ConveyGeneratesStringMatching(nil, "(abc)", "^abc$")
+		ConveyGeneratesStringMatching(nil, "()", "^$")
+	})
+}
+
+func TestMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	Convey("MaxDepth", t, func() {
+		nested := func(n int, expr string) string {
+			return strings.Repeat("(", n) + expr + strings.Repeat(")", n)
+		}
+
+		Convey("Returns error for expressions nested too deeply", func() {
+			args := &GeneratorArgs{MaxDepth: 100}
+			for _, pattern := range []string{nested(150, "a"), "a{0,200}", nested(10, "(a|b+){0,100}")} {
+				generator, err := NewGenerator(pattern, args)
+				So(generator, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "nested more than 100 levels deep")
+			}
+		})
+
+		Convey("Accepts expressions within the limit", func() {
+			args := &GeneratorArgs{MaxDepth: 100}
+			ConveyGeneratesStringMatching(args, nested(90, "a"), "^a$")
+			ConveyGeneratesStringMatching(args, "b{0,40}", "^b{0,40}$")
+		})
+
+		Convey("Accepts any expression the parser accepts by default", func() {
+			for _, pattern := range []string{nested(999, "a"), nested(998, "a{0,1000}")} {
+				_, err := NewGenerator(pattern, nil)
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestOverrides(t *testing.T) {
+	t.Parallel()
+
+	Convey("Overrides", t, func() {
+		upperLiterals := func(regexp *syntax.Regexp, args *GeneratorArgs) (Generator, error) {
+			return NewGenerator(strings.ToUpper(string(regexp.Rune)), &GeneratorArgs{Flags: syntax.Literal})
+		}
+
+		Convey("Replace built-in generators", func() {
+			args := &GeneratorArgs{Overrides: map[syntax.Op]GeneratorFactory{syntax.OpLiteral: upperLiterals}}
+			ConveyGeneratesStringMatching(args, "abc[x-z]", "^ABC[x-z]$")
+			ConveyGeneratesStringMatching(args, "(foo|bar|qux)+", "^(FOO|BAR|QUX)+$")
+		})
+
+		Convey("Can choose which expressions to override", func() {
+			args := &GeneratorArgs{
+				Flags: syntax.Perl,
+				Overrides: map[syntax.Op]GeneratorFactory{
+					syntax.OpCapture: func(regexp *syntax.Regexp, args *GeneratorArgs) (Generator, error) {
+						if regexp.Name == "email" {
+							return NewGenerator(`[a-z]{5}@example\.com`, nil)
+						}
+						return NewGenerator(regexp.Sub[0].String(), args)
+					},
+				},
+			}
+			generator, err := NewGenerator(`(?P<email>\w+)-(\d)-\2`, args)
+			So(err, ShouldBeNil)
+			matcher := regexp.MustCompile(`^[a-z]{5}@example\.com-\d-\d$`)
+			for i := 0; i < SampleSize/10; i++ {
+				So(matcher.MatchString(generator.Generate()), ShouldBeTrue)
+				_, groups := generator.(CaptureGenerator).GenerateCaptures()
+				So(groups[0], ShouldEndWith, "-"+groups[2]+"-"+groups[2])
+			}
+		})
+
+		Convey("Returns errors from overrides", func() {
+			args := &GeneratorArgs{Overrides: map[syntax.Op]GeneratorFactory{
+				syntax.OpLiteral: func(regexp *syntax.Regexp, args *GeneratorArgs) (Generator, error) {
+					return nil, errors.New("override failed")
+				},
+			}}
+			_, err := NewGenerator("a", args)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "override failed")
+			So(CanGenerate("a", args), ShouldBeNil)
+
+			args.Overrides[syntax.OpLiteral] = func(regexp *syntax.Regexp, args *GeneratorArgs) (Generator, error) {
+				return nil, nil
+			}
+			_, err = NewGenerator("a", args)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenConcat(t *testing.T) {
+	t.Parallel()
+
+	Convey("Concat", t, func() {
+		ConveyGeneratesStringMatchingItself(nil, "[ab][cd]")
+	})
+}
+
+func TestGenRepeat(t *testing.T) {
+	t.Parallel()
+
+	Convey("Repeat", t, func() {
+
+		Convey("Unbounded", func() {
+			ConveyGeneratesStringMatchingItself(nil, `a{1,}`)
+
+			Convey("HitsDefaultMax", func() {
+				regexp := "a{0,}"
+				args := &GeneratorArgs{
+					RngSource: rand.NewSource(0),
+				}
+				counts := generateLenHistogram(regexp, DefaultMaxUnboundedRepeatCount, args)
+
+				So(len(counts), ShouldEqual, DefaultMaxUnboundedRepeatCount+1)
+				So(counts[DefaultMaxUnboundedRepeatCount], ShouldBeGreaterThan, 0)
+			})
+
+			Convey("HitsCustomMax", func() {
+				regexp := "a{0,}"
+				args := &GeneratorArgs{
+					RngSource:               rand.NewSource(0),
+					MaxUnboundedRepeatCount: 200,
+				}
+				counts := generateLenHistogram(regexp, 200, args)
+
+				So(len(counts), ShouldEqual, 200+1)
+				So(counts[200], ShouldBeGreaterThan, 0)
+			})
+
+			Convey("StaysUnderSmallMax", func() {
+				args := &GeneratorArgs{
+					RngSource:               rand.NewSource(0),
+					MaxUnboundedRepeatCount: 8,
+				}
+				counts := generateLenHistogram("a*", 8, args)
+
+				So(len(counts), ShouldEqual, 8+1)
+				So(counts[8], ShouldBeGreaterThan, 0)
+			})
+
+			Convey("AddsMaxToMin", func() {
+				args := &GeneratorArgs{
+					RngSource:               rand.NewSource(0),
+					MaxUnboundedRepeatCount: 8,
+				}
+				counts := generateLenHistogram("a{3,}", 3-1+8, args)
+
+				So(len(counts), ShouldEqual, 3-1+8+1)
+				So(counts[3-1+8], ShouldBeGreaterThan, 0)
+				So(counts[3], ShouldBeGreaterThan, 0)
+				for i := 0; i < 3; i++ {
+					So(counts[i], ShouldEqual, 0)
+				}
+			})
+		})
+
+		Convey("HitsMin", func() {
+			regexp := "a{0,3}"
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			}
+			counts := generateLenHistogram(regexp, 3, args)
+
+			So(len(counts), ShouldEqual, 3+1)
+			So(counts[0], ShouldBeGreaterThan, 0)
+		})
+
+		Convey("HitsMax", func() {
+			regexp := "a{0,3}"
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			}
+			counts := generateLenHistogram(regexp, 3, args)
+
+			So(len(counts), ShouldEqual, 3+1)
+			So(counts[3], ShouldBeGreaterThan, 0)
+		})
+
+		Convey("IsWithinBounds", func() {
+			regexp := "a{5,10}"
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			}
+			counts := generateLenHistogram(regexp, 10, args)
+
+			So(len(counts), ShouldEqual, 11)
+
+			for i := 0; i < 11; i++ {
+				if i < 5 {
+					So(counts[i], ShouldEqual, 0)
+				} else if i < 11 {
+					So(counts[i], ShouldBeGreaterThan, 0)
+				}
+			}
+		})
+	})
+}
+
+func TestGenCharClasses(t *testing.T) {
+	t.Parallel()
+
+	Convey("CharClasses", t, func() {
+
+		Convey("Ascii", func() {
+			ConveyGeneratesStringMatchingItself(nil,
+				"[[:alnum:]]",
+				"[[:alpha:]]",
+				"[[:ascii:]]",
+				"[[:blank:]]",
+				"[[:cntrl:]]",
+				"[[:digit:]]",
+				"[[:graph:]]",
+				"[[:lower:]]",
+				"[[:print:]]",
+				"[[:punct:]]",
+				"[[:space:]]",
+				"[[:upper:]]",
+				"[[:word:]]",
+				"[[:xdigit:]]",
+				"[[:^alnum:]]",
+				"[[:^alpha:]]",
+				"[[:^ascii:]]",
+				"[[:^blank:]]",
+				"[[:^cntrl:]]",
+				"[[:^digit:]]",
+				"[[:^graph:]]",
+				"[[:^lower:]]",
+				"[[:^print:]]",
+				"[[:^punct:]]",
+				"[[:^space:]]",
+				"[[:^upper:]]",
+				"[[:^word:]]",
+				"[[:^xdigit:]]",
+			)
+		})
+
+		Convey("Repeated POSIX classes", func() {
+			ConveyGeneratesStringMatchingItself(nil,
+				"[[:alnum:]]{10}",
+				"[[:^digit:]]{5}",
+				"[[:space:][:punct:]]{5}",
+				"[^[:alpha:][:digit:]]{5}",
+			)
+		})
+
+		Convey("Null bytes", func() {
+			// Null bytes are never generated, so a range containing only the null byte is dropped.
+			ConveyGeneratesStringMatchingItself(nil,
+				`[\x00a]{5}`,
+				`[\x00[:digit:]]{5}`,
+			)
+		})
+
+		Convey("Perl", func() {
+			args := &GeneratorArgs{
+				Flags: syntax.Perl,
+			}
+
+			ConveyGeneratesStringMatchingItself(args,
+				`\d`,
+				`\s`,
+				`\w`,
+				`\D`,
+				`\S`,
+				`\W`,
+			)
+		})
+	})
+}
+
+func TestGenUnicodeGroups(t *testing.T) {
+	t.Parallel()
+
+	Convey("UnicodeGroups", t, func() {
+		args := &GeneratorArgs{
+			Flags: syntax.Perl,
+		}
+
+		ConveyGeneratesStringMatchingItself(args,
+			`\p{Greek}`,
+			`\p{Lu}`,
+			`\pN`,
+			`\P{Greek}`,
+			`[\p{Han}\p{Cyrillic}]`,
+		)
+
+		Convey("Generates only runes in the group", func() {
+			generator, err := NewGenerator(`\p{Greek}+`, args)
+			So(err, ShouldBeNil)
+
+			nonGreek := 0
+			for i := 0; i < SampleSize; i++ {
+				for _, r := range generator.Generate() {
+					if !unicode.Is(unicode.Greek, r) {
+						nonGreek++
+					}
+				}
+			}
+			So(nonGreek, ShouldEqual, 0)
+		})
+
+		Convey("Works without Perl flag", func() {
+			generator, err := NewGenerator(`\p{Lu}`, &GeneratorArgs{
+				Flags: syntax.UnicodeGroups,
+			})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize; i++ {
+				r, _ := utf8.DecodeRuneInString(generator.Generate())
+				So(unicode.IsUpper(r), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestGenAstralRunes(t *testing.T) {
+	t.Parallel()
+
+	Convey("Astral runes", t, func() {
+		ConveyGeneratesValidRunes := func(pattern string, start, end rune) {
+			generator, err := NewGenerator(pattern, nil)
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize/10; i++ {
+				str := generator.Generate()
+				So(utf8.ValidString(str), ShouldBeTrue)
+				for _, r := range str {
+					So(r, ShouldBeBetweenOrEqual, start, end)
+					So(utf8.RuneLen(r), ShouldEqual, 4)
+				}
+			}
+		}
+
+		Convey("Generates emoticons", func() {
+			ConveyGeneratesValidRunes(`[\x{1F600}-\x{1F64F}]{10}`, 0x1F600, 0x1F64F)
+			ConveyGeneratesStringMatchingItself(nil, `[\x{1F600}-\x{1F64F}]{10}`, `\x{1F600}+`)
+		})
+
+		Convey("Generates EmojiCharClass", func() {
+			ConveyGeneratesValidRunes(EmojiCharClass+`{10}`, 0x1F300, 0x1F9FF)
+			ConveyGeneratesStringMatchingItself(nil, EmojiCharClass+`{1,3}`)
+		})
+
+		Convey("Formats astral runes", func() {
+			So(runesToString(0x1F600, 'a'), ShouldEqual, "\U0001F600a")
+		})
+	})
+}
+
+func TestDeterministic(t *testing.T) {
+	t.Parallel()
+
+	Convey("Deterministic", t, func() {
+		generate := func(pattern string, seed int64) string {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource:     rand.NewSource(seed),
+				Deterministic: true,
+			})
+			So(err, ShouldBeNil)
+			return generator.Generate()
+		}
+
+		Convey("Picks first alternative, min repeats, and first rune", func() {
+			So(generate(`(a|b|c){2,5}`, 0), ShouldEqual, "aa")
+			So(generate(`foo|bar`, 0), ShouldEqual, "foo")
+			So(generate(`[x-z]+`, 0), ShouldEqual, "x")
+			So(generate(`a*b?`, 0), ShouldEqual, "")
+		})
+
+		Convey("Does not depend on the seed", func() {
+			pattern := `(foo|bar)[0-9]{2,8}-[a-z]+`
+			expected := generate(pattern, 0)
+			for seed := int64(1); seed < 10; seed++ {
+				So(generate(pattern, seed), ShouldEqual, expected)
+			}
+		})
+
+		Convey("Does not consume the RNG", func() {
+			var generatorArgs *GeneratorArgs
+			generator, err := NewGenerator(`(a|b)+[0-9]*.`, &GeneratorArgs{
+				RngSource:     rand.NewSource(0),
+				Deterministic: true,
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					generatorArgs = args
+					return generator.Generate()
+				},
+			})
+			So(err, ShouldBeNil)
+			generator.Generate()
+
+			// Both RNGs were seeded identically, so if generation didn't consume any random
+			// numbers they should still be in sync.
+			expectedArgs := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+			}
+			So(expectedArgs.initialize(), ShouldBeNil)
+			So(generatorArgs.Rng().Int63(), ShouldEqual, expectedArgs.Rng().Int63())
+		})
+	})
+}
+
+// maxRandSource always returns the largest possible value.
+type maxRandSource struct{}
+
+func (maxRandSource) Intn(n int) int       { return n - 1 }
+func (maxRandSource) Int31() int32         { return 1<<31 - 1 }
+func (maxRandSource) Int31n(n int32) int32 { return n - 1 }
+func (maxRandSource) Int63() int64         { return 1<<63 - 1 }
+
+func TestRandSource(t *testing.T) {
+	t.Parallel()
+
+	Convey("RandSource", t, func() {
+		Convey("Is implemented by rand.Rand", func() {
+			var source RandSource = rand.New(rand.NewSource(0))
+			So(source, ShouldNotBeNil)
+		})
+
+		Convey("Is used by generators", func() {
+			generator, err := NewGenerator(`(a|b|c)[a-z]{2,4}x*`, &GeneratorArgs{
+				Rand:                    maxRandSource{},
+				MaxUnboundedRepeatCount: 3,
+			})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 10; i++ {
+				So(generator.Generate(), ShouldEqual, "czzzzxxx")
+			}
+		})
+
+		Convey("Is returned by RandSource and Rng", func() {
+			var generatorArgs *GeneratorArgs
+			generator, err := NewGenerator(`(a)`, &GeneratorArgs{
+				Rand: maxRandSource{},
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					generatorArgs = args
+					return ""
+				},
+			})
+			So(err, ShouldBeNil)
+			generator.Generate()
+
+			So(generatorArgs.RandSource(), ShouldEqual, maxRandSource{})
+			So(generatorArgs.Rng().Int63(), ShouldEqual, maxRandSource{}.Int63())
+			So(func() { generatorArgs.Rng().Seed(1) }, ShouldPanic)
+		})
+	})
+}
+
+func TestReseed(t *testing.T) {
+	t.Parallel()
+
+	Convey("Reseed", t, func() {
+		pattern := `(foo|bar)[a-z]{3,8}\d*`
+		generator, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl})
+		So(err, ShouldBeNil)
+
+		Convey("Replays the same strings", func() {
+			generator.(SeedableGenerator).Reseed(42)
+			expected := GenerateN(generator, 3)
+			generator.(SeedableGenerator).Reseed(42)
+			So(GenerateN(generator, 3), ShouldResemble, expected)
+		})
+
+		Convey("Generates the same strings as a new generator", func() {
+			seeded, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource: rand.NewSource(42),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+
+			generator.(SeedableGenerator).Reseed(42)
+			So(GenerateN(generator, 3), ShouldResemble, GenerateN(seeded, 3))
+		})
+
+		Convey("Replaces a custom RandSource", func() {
+			generator, err := NewGenerator(`[a-z]{10}`, &GeneratorArgs{Rand: maxRandSource{}})
+			So(err, ShouldBeNil)
+			So(generator.Generate(), ShouldEqual, "zzzzzzzzzz")
+
+			generator.(SeedableGenerator).Reseed(42)
+			So(generator.Generate(), ShouldNotEqual, "zzzzzzzzzz")
+		})
+	})
+}
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+
+	Convey("Clone", t, func() {
+		pattern := `(foo|bar)[a-z]{3,8}\d*`
+		generator, err := NewGenerator(pattern, &GeneratorArgs{
+			RngSource: rand.NewSource(0),
+			Flags:     syntax.Perl,
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Generates the same strings as a new generator with the same RNG", func() {
+			seeded, err := NewGenerator(pattern, &GeneratorArgs{
+				Rand:  rand.New(rand.NewSource(42)),
+				Flags: syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+
+			clone := generator.(SeedableGenerator).Clone(rand.New(rand.NewSource(42)))
+			So(clone.String(), ShouldEqual, generator.String())
+			So(GenerateN(clone, 10), ShouldResemble, GenerateN(seeded, 10))
+		})
+
+		Convey("Doesn't change the original", func() {
+			original, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+
+			clone := generator.(SeedableGenerator).Clone(nil)
+			GenerateN(clone, 10)
+			clone.(SeedableGenerator).Reseed(1)
+			So(GenerateN(generator, 10), ShouldResemble, GenerateN(original, 10))
+		})
+
+		Convey("Clones can be used concurrently", func() {
+			for _, generator := range []Generator{
+				generator,
+				MustNewGenerator(`(a)\1[b-z]+`, nil),
+			} {
+				multi, err := NewGeneratorFromPatterns([]string{`a+`, `b|c`}, nil)
+				So(err, ShouldBeNil)
+
+				var wg sync.WaitGroup
+				results := make([][]string, 8)
+				for i := range results {
+					wg.Add(1)
+					go func(i int, clone Generator, multiClone Generator) {
+						defer wg.Done()
+						results[i] = append(GenerateN(clone, 100), GenerateN(multiClone, 100)...)
+					}(i, generator.(SeedableGenerator).Clone(rand.New(rand.NewSource(int64(i)))), multi.(SeedableGenerator).Clone(nil))
+				}
+				wg.Wait()
+
+				matcher := regexp.MustCompile(`^(?:a+|b|c)$`)
+				for _, strs := range results {
+					So(strs, ShouldHaveLength, 200)
+					for _, str := range strs[100:] {
+						So(matcher.MatchString(str), ShouldBeTrue)
+					}
+				}
+			}
+		})
+	})
+}
+
+func TestSeedString(t *testing.T) {
+	t.Parallel()
+
+	Convey("SeedString", t, func() {
+		generate := func(args *GeneratorArgs) []string {
+			args.Flags = syntax.Perl
+			generator, err := NewGenerator(`[a-z]{5}\d{5}`, args)
+			So(err, ShouldBeNil)
+			return GenerateN(generator, 5)
+		}
+
+		Convey("Same string generates the same output", func() {
+			So(generate(&GeneratorArgs{SeedString: t.Name()}), ShouldResemble,
+				generate(&GeneratorArgs{SeedString: t.Name()}))
+		})
+
+		Convey("Different strings generate different output", func() {
+			So(generate(&GeneratorArgs{SeedString: "TestFoo"}), ShouldNotResemble,
+				generate(&GeneratorArgs{SeedString: "TestBar"}))
+		})
+
+		Convey("RngSource takes precedence", func() {
+			So(generate(&GeneratorArgs{SeedString: "TestFoo", RngSource: rand.NewSource(1)}), ShouldResemble,
+				generate(&GeneratorArgs{SeedString: "TestBar", RngSource: rand.NewSource(1)}))
+		})
+
+		Convey("Rand takes precedence", func() {
+			So(generate(&GeneratorArgs{SeedString: "TestFoo", Rand: maxRandSource{}}), ShouldResemble,
+				generate(&GeneratorArgs{Rand: maxRandSource{}}))
+		})
+	})
+}
+
+func TestConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// Run with -race to check for data races.
+	ConveyGeneratesConcurrently := func(args *GeneratorArgs) {
+		generator, err := NewGenerator(`(foo|bar)[a-z]{3,8}\d*`, args)
+		So(err, ShouldBeNil)
+
+		const numGoroutines = 16
+		results := make(chan string, numGoroutines*SampleSize/10)
+		var wg sync.WaitGroup
+		for i := 0; i < numGoroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < SampleSize/10; j++ {
+					results <- generator.Generate()
+				}
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		expected := regexp.MustCompile(`^(foo|bar)[a-z]{3,8}\d*$`)
+		mismatches := 0
+		for result := range results {
+			if !expected.MatchString(result) {
+				mismatches++
+			}
+		}
+		So(mismatches, ShouldEqual, 0)
+	}
+
+	Convey("Concurrent", t, func() {
+		ConveyGeneratesConcurrently(&GeneratorArgs{
+			Flags:      syntax.Perl,
+			Concurrent: true,
+		})
+	})
+
+	Convey("RngPool", t, func() {
+		ConveyGeneratesConcurrently(&GeneratorArgs{
+			Flags:   syntax.Perl,
+			RngPool: true,
+		})
+	})
+}
+
+func TestGenerateBytes(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateBytes", t, func() {
+		newGenerator := func() Generator {
+			generator, err := NewGenerator(`[a-z]{3}(foo|bar)+\d*`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.Perl,
+			})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Generates the same output as Generate", func() {
+			stringGenerator := newGenerator()
+			bytesGenerator := newGenerator()
+
+			for i := 0; i < SampleSize; i++ {
+				So(string(GenerateBytes(bytesGenerator)), ShouldEqual, stringGenerator.Generate())
+			}
+		})
+	})
+}
+
+func TestGenerateTo(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateTo", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Writes the same output as Generate", func() {
+			pattern := `[a-z]{3}(foo|bar)+[0-9]*`
+			stringGenerator := newGenerator(pattern, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			writerGenerator := newGenerator(pattern, &GeneratorArgs{RngSource: rand.NewSource(0)})
+
+			for i := 0; i < SampleSize; i++ {
+				var buffer bytes.Buffer
+				n, err := GenerateTo(writerGenerator, &buffer)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, buffer.Len())
+				So(buffer.String(), ShouldEqual, stringGenerator.Generate())
+			}
+		})
+
+		Convey("Returns write errors", func() {
+			generator := newGenerator(`a*`, &GeneratorArgs{
+				MinUnboundedRepeatCount: 100000,
+				MaxUnboundedRepeatCount: 100000,
+			})
+			w := &limitedWriter{limit: 10}
+
+			n, err := GenerateTo(generator, w)
+			So(err, ShouldEqual, errLimitReached)
+			So(n, ShouldEqual, 10)
+			So(w.written, ShouldEqual, 10)
+		})
+	})
+}
+
+func TestGenerateAppend(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateAppend", t, func() {
+		Convey("Appends the same output as Generate", func() {
+			pattern := `[a-z]{3}(foo|bar)+[0-9]*`
+			stringGenerator, err := NewGenerator(pattern, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+			builderGenerator, err := NewGenerator(pattern, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+
+			var sb, expected strings.Builder
+			for i := 0; i < SampleSize; i++ {
+				GenerateAppend(builderGenerator, &sb)
+				sb.WriteByte('\n')
+				expected.WriteString(stringGenerator.Generate() + "\n")
+			}
+			So(sb.String(), ShouldEqual, expected.String())
+		})
+
+		Convey("Appends the output generated before MaxTotalLength is exceeded", func() {
+			generator, err := NewGenerator(`a{20}`, &GeneratorArgs{MaxTotalLength: 5})
+			So(err, ShouldBeNil)
+
+			var sb strings.Builder
+			sb.WriteString("x")
+			GenerateAppend(generator, &sb)
+			So(sb.String(), ShouldEqual, "xaaaaa")
+		})
+
+		Convey("Multiple patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`foo`, `bar`}, nil)
+			So(err, ShouldBeNil)
+
+			var sb strings.Builder
+			GenerateAppend(generator, &sb)
+			So(sb.String(), ShouldBeIn, "foo", "bar")
+		})
+	})
+}
+
+func TestGenerateContext(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateContext", t, func() {
+		Convey("Generates strings", func() {
+			generator, err := NewGenerator(`[a-z]{3}(foo|bar)+`, nil)
+			So(err, ShouldBeNil)
+
+			str, err := GenerateContext(context.Background(), generator)
+			So(err, ShouldBeNil)
+			So(str, ShouldNotBeEmpty)
+		})
+
+		Convey("Returns error if context is already done", func() {
+			generator, err := NewGenerator(`a`, nil)
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err = GenerateContext(ctx, generator)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("Stops generating when context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			callCount := 0
+			generator, err := NewGenerator(`(a){1000}`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					callCount++
+					if callCount == 10 {
+						cancel()
+					}
+					return generator.Generate()
+				},
+			})
+			So(err, ShouldBeNil)
+
+			_, err = GenerateContext(ctx, generator)
+			So(err, ShouldEqual, context.Canceled)
+			So(callCount, ShouldBeLessThan, 1000)
+		})
+
+		Convey("Stops generating in unbounded repeats", func() {
+			// Would generate 10^10 characters if not cancelled.
+			generator, err := NewGenerator(`(a*)*`, &GeneratorArgs{
+				MinUnboundedRepeatCount: 100000,
+				MaxUnboundedRepeatCount: 100000,
+			})
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+
+			_, err = GenerateContext(ctx, generator)
+			So(err, ShouldEqual, context.DeadlineExceeded)
+		})
+	})
+}
+
+func TestByteMode(t *testing.T) {
+	t.Parallel()
+
+	Convey("ByteMode", t, func() {
+		Convey("Generates one byte per dot", func() {
+			args := &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				Flags:     syntax.DotNL,
+				ByteMode:  true,
+			}
+			counts := generateLenHistogram(`.{1,3}`, 3, args)
+
+			So(len(counts), ShouldEqual, 3+1)
+			So(counts[0], ShouldEqual, 0)
+			for i := 1; i <= 3; i++ {
+				So(counts[i], ShouldBeGreaterThan, 0)
+			}
+		})
+
+		Convey("Generates invalid UTF-8", func() {
+			generator, err := NewGenerator(`.{10}`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				ByteMode:  true,
+			})
+			So(err, ShouldBeNil)
+
+			invalidCount := 0
+			for i := 0; i < SampleSize; i++ {
+				if !utf8.ValidString(generator.Generate()) {
+					invalidCount++
+				}
+			}
+			So(invalidCount, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("No newlines are generated without DotNL", func() {
+			generator, err := NewGenerator(`.{100}`, &GeneratorArgs{
+				RngSource: rand.NewSource(0),
+				ByteMode:  true,
+			})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < SampleSize; i++ {
+				So(generator.Generate(), ShouldNotContainSubstring, "\n")
+			}
+		})
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	Convey("Timeout", t, func() {
+		Convey("Stops generating huge repeats", func() {
+			generator, err := NewGenerator(`[a-z]*`, &GeneratorArgs{
+				MinUnboundedRepeatCount: 1 << 30,
+				MaxUnboundedRepeatCount: 1 << 30,
+				Timeout:                 time.Millisecond,
+			})
+			So(err, ShouldBeNil)
+
+			start := time.Now()
+			_, err = GenerateE(generator)
+			So(err, ShouldEqual, ErrTimeout)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+
+			var buffer bytes.Buffer
+			_, err = GenerateTo(generator, &buffer)
+			So(err, ShouldEqual, ErrTimeout)
+			So(len(generator.Generate()), ShouldBeLessThan, 1<<30)
+		})
+
+		Convey("Applies to each string", func() {
+			generator, err := NewGenerator(`[a-z]{10}`, &GeneratorArgs{Timeout: time.Second})
+			So(err, ShouldBeNil)
+			for i := 0; i < SampleSize; i++ {
+				str, err := GenerateE(generator)
+				So(err, ShouldBeNil)
+				So(str, ShouldHaveLength, 10)
+			}
+		})
+
+		Convey("Is checked with a context", func() {
+			generator, err := NewGenerator(`(a*)*`, &GeneratorArgs{
+				MaxUnboundedRepeatCount: 1 << 20,
+				Timeout:                 time.Millisecond,
+			})
+			So(err, ShouldBeNil)
+			_, err = GenerateContext(context.Background(), generator)
+			So(err, ShouldEqual, ErrTimeout)
+		})
+	})
+}
+
+func TestMaxTotalLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("MaxTotalLength", t, func() {
+		newGenerator := func(pattern string) Generator {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{MaxTotalLength: 1000})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Returns error when exceeded", func() {
+			// The parser doesn't allow repeats of more than 1000, so this is .{5000}.
+			generator := newGenerator(strings.Repeat(`.{1000}`, 5))
+
+			_, err := GenerateContext(context.Background(), generator)
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+
+			var buffer bytes.Buffer
+			n, err := GenerateTo(generator, &buffer)
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+			So(n, ShouldBeLessThanOrEqualTo, 1000)
+		})
+
+		Convey("Truncates output of Generate", func() {
+			So(len(newGenerator(strings.Repeat(`.{1000}`, 5)).Generate()), ShouldBeBetweenOrEqual, 1000-utf8.UTFMax, 1000)
+			So(newGenerator(`a{500}b{600}`).Generate(), ShouldEqual, strings.Repeat("a", 500)+strings.Repeat("b", 500))
+		})
+
+		Convey("Allows strings up to the limit", func() {
+			str, err := GenerateContext(context.Background(), newGenerator(`a{1000}`))
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, 1000)
+		})
+
+		Convey("Counts capture groups once", func() {
+			_, err := GenerateContext(context.Background(), newGenerator(`(a{400})\1`))
+			So(err, ShouldBeNil)
+
+			_, err = GenerateContext(context.Background(), newGenerator(`(a{400})\1\1`))
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+		})
+	})
+}
+
+func TestSoftMaxLength(t *testing.T) {
+	t.Parallel()
+
+	Convey("SoftMaxLength", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Stops repeating near the limit", func() {
+			generator := newGenerator(`.*`, &GeneratorArgs{
+				RngSource:               rand.NewSource(0),
+				MaxUnboundedRepeatCount: 4096,
+				SoftMaxLength:           100,
+			})
+			stats := Sample(generator, SampleSize)
+			// The last rune may end after the limit.
+			for _, str := range GenerateN(generator, SampleSize) {
+				So(len(str), ShouldBeLessThan, 100+utf8.UTFMax)
+			}
+			// Most strings would be thousands of runes long without a limit.
+			So(stats.Mean, ShouldBeGreaterThan, 20)
+		})
+
+		Convey("Applies to nested repeats", func() {
+			generator := newGenerator(`(?:[a-z]{1,10} ?){1,100}`, &GeneratorArgs{
+				Flags:         syntax.Perl,
+				RngSource:     rand.NewSource(0),
+				SoftMaxLength: 50,
+			})
+			for _, str := range GenerateN(generator, SampleSize) {
+				So(len(str), ShouldBeLessThanOrEqualTo, 50+10)
+			}
+		})
+
+		Convey("Generates required parts", func() {
+			So(newGenerator(`a{20}b+`, &GeneratorArgs{SoftMaxLength: 10}).Generate(), ShouldEqual,
+				strings.Repeat("a", 20)+"b")
+			So(newGenerator(`abc(d)*`, &GeneratorArgs{SoftMaxLength: 1}).Generate(), ShouldEqual, "abc")
+		})
+
+		Convey("Doesn't return an error", func() {
+			str, err := GenerateE(newGenerator(`x{100}`, &GeneratorArgs{SoftMaxLength: 10}))
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, 100)
+		})
+	})
+}
+
+func TestMinRepeatOverride(t *testing.T) {
+	t.Parallel()
+
+	Convey("MinRepeatOverride", t, func() {
+		newGenerator := func(pattern string, override int) Generator {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{MinRepeatOverride: override})
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Always generates optional expressions", func() {
+			generator := newGenerator(`a?`, 1)
+			for i := 0; i < SampleSize; i++ {
+				So(generator.Generate(), ShouldEqual, "a")
+			}
+		})
+
+		Convey("Raises the minimum of unbounded repeats", func() {
+			for _, pattern := range []string{`a*`, `a+`} {
+				generator := newGenerator(pattern, 3)
+				for i := 0; i < SampleSize; i++ {
+					So(len(generator.Generate()), ShouldBeGreaterThanOrEqualTo, 3)
+				}
+			}
+		})
+
+		Convey("Clamps to the maximum of bounded repeats", func() {
+			So(newGenerator(`a{1,2}`, 3).Generate(), ShouldEqual, "aa")
+			So(newGenerator(`(ab){0,3}`, 5).Generate(), ShouldEqual, "ababab")
+		})
+
+		Convey("Doesn't lower minimums", func() {
+			generator := newGenerator(`a{5,8}`, 2)
+			for i := 0; i < SampleSize; i++ {
+				So(len(generator.Generate()), ShouldBeBetweenOrEqual, 5, 8)
+			}
+		})
+
+		Convey("Applies to GenerateShortest", func() {
+			str, err := newGenerator(`a*b?c{0,5}`, 2).(LengthGenerator).GenerateShortest()
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "aabcc")
+		})
+	})
+}
+
+func TestASCIIOnly(t *testing.T) {
+	t.Parallel()
+
+	Convey("ASCIIOnly", t, func() {
+		ConveyGeneratesASCII := func(pattern string, args *GeneratorArgs) {
+			args.ASCIIOnly = true
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			nonASCII := 0
+			for i := 0; i < SampleSize; i++ {
+				for _, b := range GenerateBytes(generator) {
+					if b >= 128 {
+						nonASCII++
+					}
+				}
+			}
+			So(nonASCII, ShouldEqual, 0)
+		}
+
+		Convey("Generates ASCII from classes", func() {
+			ConveyGeneratesASCII(`\w{10}`, &GeneratorArgs{Flags: syntax.Perl})
+			ConveyGeneratesASCII(`[^a-z]{10}`, &GeneratorArgs{})
+			ConveyGeneratesASCII(`\pL{10}`, &GeneratorArgs{Flags: syntax.Perl})
+		})
+
+		Convey("Generates ASCII from any char", func() {
+			ConveyGeneratesASCII(`.{10}`, &GeneratorArgs{})
+			ConveyGeneratesASCII(`.{10}`, &GeneratorArgs{Flags: syntax.DotNL})
+			ConveyGeneratesASCII(`.{10}`, &GeneratorArgs{Flags: syntax.DotNL, RawAnyChar: true})
+			ConveyGeneratesASCII(`.{10}`, &GeneratorArgs{Flags: syntax.DotNL, ByteMode: true})
+		})
+
+		Convey("Still generates matching strings", func() {
+			ConveyGeneratesStringMatchingItself(&GeneratorArgs{ASCIIOnly: true}, `[^a-z]{10}`, `.{10}`, `[a-zé]{10}`)
+		})
+
+		Convey("Returns error for classes without ASCII characters", func() {
+			_, err := NewGenerator(`a[^\x00-\x7f]`, &GeneratorArgs{ASCIIOnly: true})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestExcludeRunes(t *testing.T) {
+	t.Parallel()
+
+	Convey("ExcludeRunes", t, func() {
+		ConveyExcludes := func(pattern string, args *GeneratorArgs) {
+			args.ExcludeRunes = []rune{'\'', '"', 0}
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			excluded := 0
+			for i := 0; i < SampleSize; i++ {
+				str := generator.Generate()
+				excluded += strings.Count(str, "'") + strings.Count(str, `"`) + strings.Count(str, "\x00")
+			}
+			So(excluded, ShouldEqual, 0)
+		}
+
+		Convey("Excludes runes from any char", func() {
+			ConveyExcludes(`.{20}`, &GeneratorArgs{ASCIIOnly: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{Flags: syntax.DotNL, ASCIIOnly: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{PrintableOnly: true, ASCIIOnly: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{ByteMode: true, ASCIIOnly: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{Flags: syntax.DotNL, ByteMode: true, ASCIIOnly: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{RawAnyChar: true, Deterministic: true})
+			ConveyExcludes(`.{20}`, &GeneratorArgs{Flags: syntax.DotNL, RawAnyChar: true, Deterministic: true})
+		})
+
+		Convey("Excludes runes from classes", func() {
+			ConveyExcludes(`[\x00-\x{2f}]{20}`, &GeneratorArgs{})
+			ConveyExcludes(`[^a-z]{20}`, &GeneratorArgs{ASCIIOnly: true})
+			ConveyExcludes(`[\W]{20}`, &GeneratorArgs{Flags: syntax.Perl, ASCIIOnly: true})
+		})
+
+		Convey("Still generates matching strings", func() {
+			args := &GeneratorArgs{ExcludeRunes: []rune{'b', 'x'}}
+			ConveyGeneratesStringMatchingItself(args, `[a-c]{10}`, `[^a]{10}`, `.{10}`, `x`)
+		})
+
+		Convey("Returns error for classes that only contain excluded runes", func() {
+			_, err := NewGenerator(`a['"]`, &GeneratorArgs{ExcludeRunes: []rune{'\'', '"'}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "only contains excluded runes")
+
+			_, err = NewGenerator(`[a-c]`, &GeneratorArgs{ExcludeRunes: []rune{'a', 'b', 'c'}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestForbiddenSubstrings(t *testing.T) {
+	t.Parallel()
+
+	Convey("ForbiddenSubstrings", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Never generates forbidden substrings", func() {
+			generator := newGenerator(`[a-z]{20}`, &GeneratorArgs{
+				RngSource:           rand.NewSource(0),
+				ForbiddenSubstrings: []string{"xyz", "a"},
+			})
+			for i := 0; i < SampleSize*10; i++ {
+				str, err := GenerateE(generator)
+				So(err, ShouldBeNil)
+				So(str, ShouldHaveLength, 20)
+				So(str, ShouldNotContainSubstring, "xyz")
+				So(str, ShouldNotContainSubstring, "a")
+			}
+		})
+
+		Convey("Regenerates capture groups", func() {
+			generator := newGenerator(`([ab])\1`, &GeneratorArgs{
+				RngSource:           rand.NewSource(0),
+				ForbiddenSubstrings: []string{"a"},
+			})
+			for i := 0; i < SampleSize/10; i++ {
+				str, groups := generator.(CaptureGenerator).GenerateCaptures()
+				So(str, ShouldEqual, "bb")
+				So(groups, ShouldResemble, []string{"bb", "b"})
+			}
+		})
+
+		Convey("Applies to GenerateTo", func() {
+			generator := newGenerator(`[ab]{2}`, &GeneratorArgs{
+				RngSource:           rand.NewSource(0),
+				ForbiddenSubstrings: []string{"a"},
+			})
+			var buffer bytes.Buffer
+			n, err := GenerateTo(generator, &buffer)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(buffer.String(), ShouldEqual, "bb")
+		})
+
+		Convey("Returns error if every string contains one", func() {
+			generator := newGenerator(`ab[cd]`, &GeneratorArgs{ForbiddenSubstrings: []string{"b"}})
+			str, err := GenerateE(generator)
+			So(err, ShouldEqual, ErrForbiddenSubstring)
+			So(str, ShouldStartWith, "ab")
+			So(generator.Generate(), ShouldStartWith, "ab")
+		})
+
+		Convey("Returns error for empty substrings", func() {
+			_, err := NewGenerator(`a`, &GeneratorArgs{ForbiddenSubstrings: []string{"x", ""}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNonEmpty(t *testing.T) {
+	t.Parallel()
+
+	Convey("NonEmpty", t, func() {
+		Convey("Never generates the empty string", func() {
+			for _, pattern := range []string{`a?`, `(foo)?`, `(|x)`, `[ab]*`, `(a|)(b|)`} {
+				generator, err := NewGenerator(pattern, &GeneratorArgs{
+					RngSource:               rand.NewSource(0),
+					NonEmpty:                true,
+					MaxUnboundedRepeatCount: 1,
+				})
+				So(err, ShouldBeNil)
+				for i := 0; i < SampleSize; i++ {
+					str, err := GenerateE(generator)
+					So(err, ShouldBeNil)
+					So(str, ShouldNotBeEmpty)
+				}
+			}
+		})
+
+		Convey("Applies to GenerateTo", func() {
+			generator, err := NewGenerator(`a?`, &GeneratorArgs{NonEmpty: true})
+			So(err, ShouldBeNil)
+			var buffer bytes.Buffer
+			n, err := GenerateTo(generator, &buffer)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(buffer.String(), ShouldEqual, "a")
+		})
+
+		Convey("Returns error for expressions that only generate the empty string", func() {
+			for _, pattern := range []string{``, `^$`, `()*`, `(?:\b)+`} {
+				_, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl, NonEmpty: true})
+				So(err, ShouldNotBeNil)
+				So(CanGenerate(pattern, &GeneratorArgs{Flags: syntax.Perl, NonEmpty: true}), ShouldNotBeNil)
+			}
 
-			So(len(counts), ShouldEqual, 200+1)
-			So(counts[200], ShouldBeGreaterThan, 0)
+			_, err := NewGeneratorFromRegexp(&syntax.Regexp{Op: syntax.OpEmptyMatch}, &GeneratorArgs{NonEmpty: true})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewGenerator(``, nil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Returns error if every string is empty", func() {
+			generator, err := NewGenerator(`(a)`, &GeneratorArgs{
+				NonEmpty: true,
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator,
+					args *GeneratorArgs) string {
+					return ""
+				},
+			})
+			So(err, ShouldBeNil)
+			str, err := GenerateE(generator)
+			So(err, ShouldEqual, ErrEmptyString)
+			So(str, ShouldBeEmpty)
+			So(generator.Generate(), ShouldBeEmpty)
 		})
 	})
 }
 
-func TestGenCharClassNotNl(t *testing.T) {
+func TestAllowRunes(t *testing.T) {
 	t.Parallel()
 
-	Convey("CharClassNotNl", t, func() {
-		ConveyGeneratesStringMatchingItself(nil,
-			"[a]",
-			"[abc]",
-			"[a-d]",
-			"[ac]",
-			"[0-9]",
-			"[a-z0-9]",
-		)
+	Convey("AllowRunes", t, func() {
+		// Base62 without vowels.
+		alphabet := []rune("0123456789bcdfghjklmnpqrstvwxyzBCDFGHJKLMNPQRSTVWXYZ")
 
-		Convey("No newlines are generated", func() {
-			// Try to narrow down the generation space. Still not a very strong assertion.
-			generator, _ := NewGenerator("[^a-zA-Z0-9]", nil)
+		ConveyGeneratesOnlyAllowed := func(pattern string, args *GeneratorArgs) {
+			args.AllowRunes = alphabet
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+
+			notAllowed := 0
 			for i := 0; i < SampleSize; i++ {
-				assert.NotEqual(t, "\n", generator.Generate())
+				for _, r := range generator.Generate() {
+					if !strings.ContainsRune(string(alphabet), r) {
+						notAllowed++
+					}
+				}
 			}
+			So(notAllowed, ShouldEqual, 0)
+		}
+
+		Convey("Restricts classes", func() {
+			ConveyGeneratesOnlyAllowed(`[a-z0-9]{8}`, &GeneratorArgs{})
+			ConveyGeneratesOnlyAllowed(`[^0-9]{8}`, &GeneratorArgs{})
+			ConveyGeneratesOnlyAllowed(`\w{8}`, &GeneratorArgs{Flags: syntax.Perl})
 		})
-	})
-}
 
-func TestGenNegativeCharClass(t *testing.T) {
-	t.Parallel()
+		Convey("Restricts any char", func() {
+			ConveyGeneratesOnlyAllowed(`.{8}`, &GeneratorArgs{})
+			ConveyGeneratesOnlyAllowed(`.{8}`, &GeneratorArgs{Flags: syntax.DotNL, RawAnyChar: true})
+			ConveyGeneratesOnlyAllowed(`.{8}`, &GeneratorArgs{RawAnyChar: true})
+			ConveyGeneratesOnlyAllowed(`.{8}`, &GeneratorArgs{ByteMode: true})
+			ConveyGeneratesOnlyAllowed(`.{8}`, &GeneratorArgs{PrintableOnly: true})
+		})
 
-	Convey("NegativeCharClass", t, func() {
-		ConveyGeneratesStringMatchingItself(nil, "[^a-zA-Z0-9]")
-	})
-}
+		Convey("Combines with ExcludeRunes", func() {
+			args := &GeneratorArgs{AllowRunes: []rune("abc"), ExcludeRunes: []rune("b")}
+			ConveyGeneratesStringMatching(args, `[a-z]{10}`, `^[ac]{10}$`)
+		})
 
-func TestGenAlternate(t *testing.T) {
-	t.Parallel()
+		Convey("Returns error for classes without allowed runes", func() {
+			_, err := NewGenerator(`x[aeiou]`, &GeneratorArgs{AllowRunes: alphabet})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "allowed runes")
 
-	Convey("Alternate", t, func() {
-		ConveyGeneratesStringMatchingItself(nil,
-			"a|b",
-			"abc|def|ghi",
-			"[ab]|[cd]",
-			"foo|bar|baz", // rewrites to foo|ba[rz]
-		)
+			_, err = NewGenerator(`.`, &GeneratorArgs{ByteMode: true, AllowRunes: []rune{'\n', 'ā'}})
+			So(err, ShouldNotBeNil)
+		})
 	})
 }
 
-func TestGenCapture(t *testing.T) {
+func TestDigitScript(t *testing.T) {
 	t.Parallel()
 
-	Convey("Capture", t, func() {
-		ConveyGeneratesStringMatching(nil, "(abc)", "^abc$")
-		ConveyGeneratesStringMatching(nil, "()", "^$")
-	})
-}
+	Convey("DigitScript", t, func() {
+		newGenerator := func(pattern string, script DigitScript) Generator {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl, DigitScript: script})
+			So(err, ShouldBeNil)
+			return generator
+		}
 
-func TestGenConcat(t *testing.T) {
-	t.Parallel()
+		Convey("Generates digits in the script", func() {
+			for _, pattern := range []string{`\d{5}`, `[0-9]{5}`, `[[:digit:]]{5}`} {
+				generator := newGenerator(pattern, DevanagariDigits)
+				for i := 0; i < SampleSize/10; i++ {
+					str := generator.Generate()
+					So(utf8.RuneCountInString(str), ShouldEqual, 5)
+					for _, r := range str {
+						So(r, ShouldBeBetweenOrEqual, '\u0966', '\u096f')
+					}
+				}
+			}
+		})
 
-	Convey("Concat", t, func() {
-		ConveyGeneratesStringMatchingItself(nil, "[ab][cd]")
+		Convey("Covers every digit", func() {
+			for _, script := range []DigitScript{ArabicIndicDigits, ThaiDigits, FullwidthDigits} {
+				seen := make(map[rune]bool)
+				for _, r := range newGenerator(`\d{1000}`, script).Generate() {
+					So(unicode.IsDigit(r), ShouldBeTrue)
+					seen[r] = true
+				}
+				So(seen, ShouldHaveLength, 10)
+			}
+		})
+
+		Convey("Doesn't affect other classes", func() {
+			So(newGenerator(`[0-8]`, DevanagariDigits).Generate(), ShouldBeBetweenOrEqual, "0", "8")
+			So(newGenerator(`[0-9a]{100}`, DevanagariDigits).Generate(), ShouldNotContainSubstring, "\u0966")
+			So(newGenerator(`\d`, ASCIIDigits).Generate(), ShouldBeBetweenOrEqual, "0", "9")
+		})
+
+		Convey("Returns error for invalid scripts", func() {
+			for _, args := range []*GeneratorArgs{
+				{DigitScript: 'a'},
+				{DigitScript: '\u0967'},
+				{DigitScript: DevanagariDigits, Validate: true},
+			} {
+				args.Flags = syntax.Perl
+				_, err := NewGenerator(`\d`, args)
+				So(err, ShouldNotBeNil)
+			}
+		})
 	})
 }
 
-func TestGenRepeat(t *testing.T) {
+func TestMaxDistinctRunesPerClass(t *testing.T) {
 	t.Parallel()
 
-	Convey("Repeat", t, func() {
-
-		Convey("Unbounded", func() {
-			ConveyGeneratesStringMatchingItself(nil, `a{1,}`)
+	Convey("MaxDistinctRunesPerClass", t, func() {
+		distinctRunes := func(pattern string, args *GeneratorArgs) map[rune]bool {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
 
-			Convey("HitsDefaultMax", func() {
-				regexp := "a{0,}"
-				args := &GeneratorArgs{
-					RngSource: rand.NewSource(0),
+			seen := make(map[rune]bool)
+			for i := 0; i < SampleSize; i++ {
+				for _, r := range generator.Generate() {
+					seen[r] = true
 				}
-				counts := generateLenHistogram(regexp, DefaultMaxUnboundedRepeatCount, args)
+			}
+			return seen
+		}
 
-				So(len(counts), ShouldEqual, DefaultMaxUnboundedRepeatCount+1)
-				So(counts[DefaultMaxUnboundedRepeatCount], ShouldBeGreaterThan, 0)
-			})
+		Convey("Limits the runes generated by large classes", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl, MaxDistinctRunesPerClass: 64}
+			So(len(distinctRunes(`\pL+`, args)), ShouldBeBetweenOrEqual, 2, 64)
+			So(len(distinctRunes(`\pL{5}`, args)), ShouldBeBetweenOrEqual, 2, 64)
+			So(len(distinctRunes(`.{5}`, args)), ShouldBeBetweenOrEqual, 2, 64)
+			So(len(distinctRunes(`[^a]{5}`, &GeneratorArgs{MaxDistinctRunesPerClass: 1})), ShouldEqual, 1)
+		})
 
-			Convey("HitsCustomMax", func() {
-				regexp := "a{0,}"
-				args := &GeneratorArgs{
-					RngSource:               rand.NewSource(0),
-					MaxUnboundedRepeatCount: 200,
-				}
-				counts := generateLenHistogram(regexp, 200, args)
+		Convey("Generates matching strings", func() {
+			args := &GeneratorArgs{Flags: syntax.Perl, MaxDistinctRunesPerClass: 3}
+			ConveyGeneratesStringMatchingItself(args, `\pL{10}`, `[^a-z]+`, `[a-c]+`)
+		})
 
-				So(len(counts), ShouldEqual, 200+1)
-				So(counts[200], ShouldBeGreaterThan, 0)
-			})
+		Convey("Doesn't change small classes", func() {
+			seen := distinctRunes(`[a-c]{10}`, &GeneratorArgs{MaxDistinctRunesPerClass: 64})
+			So(seen, ShouldResemble, map[rune]bool{'a': true, 'b': true, 'c': true})
 		})
 
-		Convey("HitsMin", func() {
-			regexp := "a{0,3}"
-			args := &GeneratorArgs{
-				RngSource: rand.NewSource(0),
+		Convey("Samples classes when the generator is created", func() {
+			newArgs := func(seed int64) *GeneratorArgs {
+				return &GeneratorArgs{Flags: syntax.Perl, MaxDistinctRunesPerClass: 8, RngSource: rand.NewSource(seed)}
 			}
-			counts := generateLenHistogram(regexp, 3, args)
+			So(distinctRunes(`\pL+`, newArgs(1)), ShouldResemble, distinctRunes(`\pL+`, newArgs(1)))
+			So(distinctRunes(`\pL+`, newArgs(1)), ShouldNotResemble, distinctRunes(`\pL+`, newArgs(2)))
+		})
 
-			So(len(counts), ShouldEqual, 3+1)
-			So(counts[0], ShouldBeGreaterThan, 0)
+		Convey("Is unlimited by default", func() {
+			So(len(distinctRunes(`\pL{5}`, &GeneratorArgs{Flags: syntax.Perl})), ShouldBeGreaterThan, 64)
 		})
+	})
+}
 
-		Convey("HitsMax", func() {
-			regexp := "a{0,3}"
-			args := &GeneratorArgs{
-				RngSource: rand.NewSource(0),
-			}
-			counts := generateLenHistogram(regexp, 3, args)
+func TestGenerateCaptures(t *testing.T) {
+	t.Parallel()
 
-			So(len(counts), ShouldEqual, 3+1)
-			So(counts[3], ShouldBeGreaterThan, 0)
+	Convey("GenerateCaptures", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Returns the output of each group", func() {
+			generator := newGenerator(`(\d{3})-(\d{4})`, &GeneratorArgs{Flags: syntax.Perl})
+			for i := 0; i < SampleSize; i++ {
+				full, groups := generator.(CaptureGenerator).GenerateCaptures()
+				So(groups, ShouldHaveLength, 3)
+				So(groups[0], ShouldEqual, full)
+				So(groups[1], ShouldHaveLength, 3)
+				So(groups[2], ShouldHaveLength, 4)
+				So(groups[1]+"-"+groups[2], ShouldEqual, full)
+			}
 		})
 
-		Convey("IsWithinBounds", func() {
-			regexp := "a{5,10}"
-			args := &GeneratorArgs{
-				RngSource: rand.NewSource(0),
+		Convey("Matches regexp submatches", func() {
+			pattern := `(a|b)((c)d)`
+			expected := regexp.MustCompile("^" + pattern + "$")
+			generator := newGenerator(pattern, nil)
+			for i := 0; i < SampleSize; i++ {
+				full, groups := generator.(CaptureGenerator).GenerateCaptures()
+				So(groups, ShouldResemble, expected.FindStringSubmatch(full))
 			}
-			counts := generateLenHistogram(regexp, 10, args)
+		})
 
-			So(len(counts), ShouldEqual, 11)
+		Convey("Returns empty strings for groups that generated nothing", func() {
+			generator := newGenerator(`x(a)*`, &GeneratorArgs{
+				MaxUnboundedRepeatCount: 1,
+				Deterministic:           true,
+			})
+			full, groups := generator.(CaptureGenerator).GenerateCaptures()
+			So(full, ShouldEqual, "x")
+			So(groups, ShouldResemble, []string{"x", ""})
+		})
 
-			for i := 0; i < 11; i++ {
-				if i < 5 {
-					So(counts[i], ShouldEqual, 0)
-				} else if i < 11 {
-					So(counts[i], ShouldBeGreaterThan, 0)
+		Convey("Returns the last instance of repeated groups", func() {
+			for seed := int64(0); seed < 100; seed++ {
+				generator := newGenerator(`(\d)+`, &GeneratorArgs{RngSource: rand.NewSource(seed), Flags: syntax.Perl})
+				full, groups := generator.(CaptureGenerator).GenerateCaptures()
+				So(groups, ShouldResemble, []string{full, full[len(full)-1:]})
+
+				generator = newGenerator(`(\d)*`, &GeneratorArgs{
+					RngSource:               rand.NewSource(seed),
+					Flags:                   syntax.Perl,
+					MaxUnboundedRepeatCount: 2,
+				})
+				full, groups = generator.(CaptureGenerator).GenerateCaptures()
+				if full == "" {
+					So(groups, ShouldResemble, []string{"", ""})
+				} else {
+					So(groups, ShouldResemble, []string{full, full[len(full)-1:]})
+				}
+			}
+		})
+
+		Convey("Matches regexp submatches of nested repeated groups", func() {
+			for _, pattern := range []string{`((\d)x)*y`, `(a(b)?)+`, `(?:(a)|(b))+`, `((a)|b)*c`} {
+				expected := regexp.MustCompile(`^(?:` + pattern + `)$`)
+				for seed := int64(0); seed < 100; seed++ {
+					generator := newGenerator(pattern, &GeneratorArgs{
+						RngSource:               rand.NewSource(seed),
+						Flags:                   syntax.Perl,
+						MaxUnboundedRepeatCount: 4,
+					})
+					full, groups := generator.(CaptureGenerator).GenerateCaptures()
+					So(groups, ShouldResemble, expected.FindStringSubmatch(full))
 				}
 			}
 		})
+
+		Convey("Returns the output of the capture group handler", func() {
+			generator := newGenerator(`(a)b`, &GeneratorArgs{
+				CaptureGroupHandler: func(index int, name string, group *syntax.Regexp, generator Generator, args *GeneratorArgs) string {
+					return "z"
+				},
+			})
+			full, groups := generator.(CaptureGenerator).GenerateCaptures()
+			So(full, ShouldEqual, "zb")
+			So(groups, ShouldResemble, []string{"zb", "z"})
+		})
+
+		Convey("Returns only the whole string without groups", func() {
+			full, groups := newGenerator(`abc`, nil).(CaptureGenerator).GenerateCaptures()
+			So(groups, ShouldResemble, []string{full})
+		})
 	})
 }
 
-func TestGenCharClasses(t *testing.T) {
+func TestGenerateNamed(t *testing.T) {
 	t.Parallel()
 
-	Convey("CharClasses", t, func() {
+	Convey("GenerateNamed", t, func() {
+		Convey("Returns the output of each named group", func() {
+			areaPattern := regexp.MustCompile(`^\d{3}$`)
+			linePattern := regexp.MustCompile(`^\d{4}$`)
+			generator, err := NewGenerator(`(?P<area>\d{3})-(\d{2})-(?P<line>\d{4})`, &GeneratorArgs{Flags: syntax.Perl})
+			So(err, ShouldBeNil)
 
-		Convey("Ascii", func() {
-			ConveyGeneratesStringMatchingItself(nil,
-				"[[:alnum:]]",
-				"[[:alpha:]]",
-				"[[:ascii:]]",
-				"[[:blank:]]",
-				"[[:cntrl:]]",
-				"[[:digit:]]",
-				"[[:graph:]]",
-				"[[:lower:]]",
-				"[[:print:]]",
-				"[[:punct:]]",
-				"[[:space:]]",
-				"[[:upper:]]",
-				"[[:word:]]",
-				"[[:xdigit:]]",
-				"[[:^alnum:]]",
-				"[[:^alpha:]]",
-				"[[:^ascii:]]",
-				"[[:^blank:]]",
-				"[[:^cntrl:]]",
-				"[[:^digit:]]",
-				"[[:^graph:]]",
-				"[[:^lower:]]",
-				"[[:^print:]]",
-				"[[:^punct:]]",
-				"[[:^space:]]",
-				"[[:^upper:]]",
-				"[[:^word:]]",
-				"[[:^xdigit:]]",
-			)
+			for i := 0; i < SampleSize; i++ {
+				full, groups := generator.(CaptureGenerator).GenerateNamed()
+				So(groups, ShouldHaveLength, 2)
+				So(areaPattern.MatchString(groups["area"]), ShouldBeTrue)
+				So(linePattern.MatchString(groups["line"]), ShouldBeTrue)
+				So(full, ShouldStartWith, groups["area"]+"-")
+				So(full, ShouldEndWith, "-"+groups["line"])
+			}
 		})
 
-		Convey("Perl", func() {
-			args := &GeneratorArgs{
-				Flags: syntax.Perl,
-			}
+		Convey("Includes named groups that generated nothing", func() {
+			generator, err := NewGenerator(`x(?P<name>a)*`, &GeneratorArgs{
+				Flags:         syntax.PerlX,
+				Deterministic: true,
+			})
+			So(err, ShouldBeNil)
 
-			ConveyGeneratesStringMatchingItself(args,
-				`\d`,
-				`\s`,
-				`\w`,
-				`\D`,
-				`\S`,
-				`\W`,
-			)
+			full, groups := generator.(CaptureGenerator).GenerateNamed()
+			So(full, ShouldEqual, "x")
+			So(groups, ShouldResemble, map[string]string{"name": ""})
+		})
+
+		Convey("Returns an empty map without named groups", func() {
+			generator, err := NewGenerator(`(a)b`, nil)
+			So(err, ShouldBeNil)
+
+			_, groups := generator.(CaptureGenerator).GenerateNamed()
+			So(groups, ShouldBeEmpty)
+		})
+
+		Convey("Returns the last group with a duplicate name", func() {
+			generator, err := NewGenerator(`(?P<name>a)(?P<name>b)`, &GeneratorArgs{Flags: syntax.PerlX})
+			So(err, ShouldBeNil)
+
+			_, groups := generator.(CaptureGenerator).GenerateNamed()
+			So(groups, ShouldResemble, map[string]string{"name": "b"})
 		})
 	})
 }
@@ -616,3 +3016,102 @@ func generateLenHistogram(regexp string, maxLen int, args *GeneratorArgs) (count
 
 	return
 }
+
+var errLimitReached = errors.New("limit reached")
+
+// limitedWriter accepts limit bytes, then fails all writes with errLimitReached.
+type limitedWriter struct {
+	limit   int
+	written int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		n := w.limit - w.written
+		w.written = w.limit
+		return n, errLimitReached
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestCharClassStrategy(t *testing.T) {
+	t.Parallel()
+
+	Convey("CharClassStrategy", t, func() {
+		Convey("Sequential cycles through the class", func() {
+			generator, err := NewGenerator(`x[a-e]`, &GeneratorArgs{CharClassStrategy: SequentialCharClassStrategy})
+			So(err, ShouldBeNil)
+			So(GenerateN(generator, 7), ShouldResemble, []string{"xa", "xb", "xc", "xd", "xe", "xa", "xb"})
+		})
+
+		Convey("Sequential generates every rune once per cycle", func() {
+			generator, err := NewGenerator(`[a-z]`, &GeneratorArgs{CharClassStrategy: SequentialCharClassStrategy})
+			So(err, ShouldBeNil)
+			seen := make(map[string]bool)
+			for _, str := range GenerateN(generator, 26) {
+				So(seen, ShouldNotContainKey, str)
+				seen[str] = true
+			}
+		})
+
+		Convey("Each class has its own position", func() {
+			generator, err := NewGenerator(`[ab][xyz]`, &GeneratorArgs{CharClassStrategy: SequentialCharClassStrategy})
+			So(err, ShouldBeNil)
+			So(GenerateN(generator, 3), ShouldResemble, []string{"ax", "by", "az"})
+		})
+	})
+}
+
+func TestRuneWeights(t *testing.T) {
+	t.Parallel()
+
+	Convey("RuneWeights", t, func() {
+		count := func(pattern string, weights map[rune]int) map[rune]int {
+			generator, err := NewGenerator(pattern, &GeneratorArgs{
+				RngSource:   rand.NewSource(0),
+				RuneWeights: weights,
+			})
+			So(err, ShouldBeNil)
+
+			counts := make(map[rune]int)
+			for i := 0; i < SampleSize*10; i++ {
+				for _, r := range generator.Generate() {
+					counts[r]++
+				}
+			}
+			return counts
+		}
+
+		Convey("Weighted runes dominate", func() {
+			// 'e' has weight 75 out of a total of 100 for [a-z].
+			counts := count(`[a-z]`, map[rune]int{'e': 75})
+			So(float64(counts['e'])/(SampleSize*10), ShouldAlmostEqual, 0.75, 0.03)
+			So(counts, ShouldContainKey, 'a')
+			So(counts, ShouldContainKey, 'z')
+		})
+
+		Convey("Unlisted runes are uniform", func() {
+			counts := count(`[abc]`, map[rune]int{'x': 100, 'b': 2})
+			So(float64(counts['a'])/(SampleSize*10), ShouldAlmostEqual, 0.25, 0.03)
+			So(float64(counts['b'])/(SampleSize*10), ShouldAlmostEqual, 0.5, 0.03)
+			So(float64(counts['c'])/(SampleSize*10), ShouldAlmostEqual, 0.25, 0.03)
+		})
+
+		Convey("Runes with weight 0 are never generated", func() {
+			counts := count(`[a-e]`, map[rune]int{'a': 0, 'c': 0, 'e': 0})
+			So(counts, ShouldHaveLength, 2)
+			So(counts, ShouldContainKey, 'b')
+			So(counts, ShouldContainKey, 'd')
+		})
+
+		Convey("Returns errors", func() {
+			_, err := NewGenerator(`[ab]`, &GeneratorArgs{RuneWeights: map[rune]int{'a': 0, 'b': 0}})
+			So(err, ShouldNotBeNil)
+			So(CanGenerate(`[ab]`, &GeneratorArgs{RuneWeights: map[rune]int{'a': 0, 'b': 0}}), ShouldNotBeNil)
+
+			_, err = NewGenerator(`[ab]`, &GeneratorArgs{RuneWeights: map[rune]int{'a': -1}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
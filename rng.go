@@ -16,6 +16,13 @@ limitations under the License.
 
 package regen
 
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+)
+
 /*
 The default Source implementation is very slow to seed. Replaced with a
 64-bit xor-shift source from http://vigna.di.unimi.it/ftp/papers/xorshift.pdf.
@@ -30,6 +37,12 @@ To create a source with the default seed:
 */
 type xorShift64Source uint64
 
+// newXorShift64Rand returns a rand.Rand that uses a xorShift64Source seeded with seed.
+func newXorShift64Rand(seed int64) *rand.Rand {
+	rngSource := xorShift64Source(seed)
+	return rand.New(&rngSource)
+}
+
 func (src *xorShift64Source) Seed(seed int64) {
 	*src = xorShift64Source(seed)
 }
@@ -46,3 +59,143 @@ func (src *xorShift64Source) Int63() int64 {
 
 	return int64((*src * 2685821657736338717) >> 1)
 }
+
+// cryptoSource is a rand.Source that reads from crypto/rand, for GeneratePassword.
+type cryptoSource struct{}
+
+// newCryptoRand returns a rand.Rand that uses a cryptoSource.
+func newCryptoRand() *rand.Rand {
+	return rand.New(cryptoSource{})
+}
+
+func (cryptoSource) Int63() int64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]) >> 1)
+}
+
+func (cryptoSource) Seed(seed int64) {
+	panic("a cryptoSource can't be seeded")
+}
+
+// pooledRandSource is a RandSource that uses a different RNG for each concurrent call, from a sync.Pool.
+type pooledRandSource struct {
+	pool sync.Pool
+}
+
+// newPooledRandSource returns a pooledRandSource whose RNGs are seeded from seeds.
+func newPooledRandSource(seeds RandSource) *pooledRandSource {
+	lockedSeeds := &lockedRandSource{source: seeds}
+	src := &pooledRandSource{}
+	src.pool.New = func() interface{} {
+		return newXorShift64Rand(lockedSeeds.Int63())
+	}
+	return src
+}
+
+// get takes an RNG from the pool. It must be returned with put when it's no longer used.
+func (src *pooledRandSource) get() RandSource {
+	return src.pool.Get().(RandSource)
+}
+
+func (src *pooledRandSource) put(rng RandSource) {
+	src.pool.Put(rng)
+}
+
+// The RandSource methods take an RNG from the pool for each call. Generators use get and put instead, to take a
+// single RNG for each string.
+
+func (src *pooledRandSource) Intn(n int) int {
+	rng := src.get()
+	defer src.put(rng)
+	return rng.Intn(n)
+}
+
+func (src *pooledRandSource) Int31() int32 {
+	rng := src.get()
+	defer src.put(rng)
+	return rng.Int31()
+}
+
+func (src *pooledRandSource) Int31n(n int32) int32 {
+	rng := src.get()
+	defer src.put(rng)
+	return rng.Int31n(n)
+}
+
+func (src *pooledRandSource) Int63() int64 {
+	rng := src.get()
+	defer src.put(rng)
+	return rng.Int63()
+}
+
+// lockedRandSource is a RandSource that can be used by multiple goroutines.
+type lockedRandSource struct {
+	lock   sync.Mutex
+	source RandSource
+}
+
+func (src *lockedRandSource) Intn(n int) int {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	return src.source.Intn(n)
+}
+
+func (src *lockedRandSource) Int31() int32 {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	return src.source.Int31()
+}
+
+func (src *lockedRandSource) Int31n(n int32) int32 {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	return src.source.Int31n(n)
+}
+
+func (src *lockedRandSource) Int63() int64 {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	return src.source.Int63()
+}
+
+// randSourceAdapter is a rand.Source that draws from a RandSource, for GeneratorArgs.Rng.
+type randSourceAdapter struct {
+	source RandSource
+}
+
+func (src randSourceAdapter) Int63() int64 {
+	return src.source.Int63()
+}
+
+func (src randSourceAdapter) Seed(seed int64) {
+	panic("the Rand returned by GeneratorArgs.Rng can't be seeded when it wraps a RandSource")
+}
+
+// countingRandSource is a RandSource that counts the calls to each of its methods, for GenerateWithStats.
+type countingRandSource struct {
+	source RandSource
+	stats  *GenStats
+}
+
+func (src *countingRandSource) Intn(n int) int {
+	src.stats.IntnCalls++
+	return src.source.Intn(n)
+}
+
+func (src *countingRandSource) Int31() int32 {
+	src.stats.Int31Calls++
+	return src.source.Int31()
+}
+
+func (src *countingRandSource) Int31n(n int32) int32 {
+	src.stats.Int31nCalls++
+	return src.source.Int31n(n)
+}
+
+func (src *countingRandSource) Int63() int64 {
+	src.stats.Int63Calls++
+	return src.source.Int63()
+}
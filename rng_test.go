@@ -45,3 +45,20 @@ func TestXorShift64(t *testing.T) {
 		So(nonZeroCount, ShouldBeGreaterThan, 0)
 	})
 }
+
+func TestCryptoSource(t *testing.T) {
+	Convey("Int63 should never return negative numbers.", t, func() {
+		rng := newCryptoRand()
+		values := make(map[int64]bool)
+		for i := 0; i < SampleSize; i++ {
+			val := rng.Int63()
+			So(val, ShouldBeGreaterThanOrEqualTo, 0)
+			values[val] = true
+		}
+		So(len(values), ShouldBeGreaterThan, 1)
+	})
+
+	Convey("Can't be seeded", t, func() {
+		So(func() { newCryptoRand().Seed(1) }, ShouldPanic)
+	})
+}
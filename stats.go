@@ -0,0 +1,115 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// GenStats describes the work done to generate a single string. See GenerateWithStats.
+type GenStats struct {
+	// The number of calls to each RandSource method.
+	IntnCalls   int
+	Int31Calls  int
+	Int31nCalls int
+	Int63Calls  int
+
+	// The length of the generated string, in runes and bytes.
+	Runes int
+	Bytes int
+}
+
+// Draws returns the total number of random numbers drawn from the RNG.
+func (s GenStats) Draws() int {
+	return s.IntnCalls + s.Int31Calls + s.Int31nCalls + s.Int63Calls
+}
+
+// GenerateWithStats is like generator.Generate, but also returns how many random numbers were drawn from the RNG
+// to generate the string, and how long it is, e.g. to find out why a pattern is slow to generate.
+// Like GenerateE, it returns an error if generation fails, along with the output generated up to that point and the
+// stats for it, or ErrUnsupportedGenerator if generator wasn't created by this package.
+func GenerateWithStats(generator Generator) (string, GenStats, error) {
+	gen, ok := generator.(extendedGenerator)
+	if !ok {
+		return "", GenStats{}, ErrUnsupportedGenerator
+	}
+	return gen.generateWithStats()
+}
+
+func (gen *internalGenerator) generateWithStats() (string, GenStats, error) {
+	var buffer bytes.Buffer
+	var stats GenStats
+	state := gen.newState(&buffer, nil)
+	// Count the calls to the RNG used for this string, which may be from a pool.
+	rng := state.rng
+	state.rng = &countingRandSource{source: rng, stats: &stats}
+	err := gen.generateUnreleased(state)
+	state.rng = rng
+	state.release()
+
+	str := buffer.String()
+	stats.Runes = utf8.RuneCountInString(str)
+	stats.Bytes = len(str)
+	if err == nil {
+		err = gen.check(str)
+	}
+	return str, stats, err
+}
+
+// Stats describes the lengths, in runes, of a sample of generated strings. See Sample.
+type Stats struct {
+	// The number of strings sampled.
+	Count int
+	// The shortest and longest lengths. Both are 0 if Count is 0.
+	Min, Max int
+	// The mean length, or 0 if Count is 0.
+	Mean float64
+	// Histogram maps each length to the number of strings with that length.
+	Histogram map[int]int
+}
+
+/*
+Sample generates n strings with gen and returns statistics about their lengths. It's intended for testing and
+tuning options that affect lengths, like RepeatDistribution and MaxUnboundedRepeatCount, e.g.:
+
+	generator, _ := regen.NewGenerator(`a+`, &regen.GeneratorArgs{RepeatDistribution: regen.GeometricRepeatDistribution})
+	stats := regen.Sample(generator, 1000)
+	fmt.Println(stats.Mean, stats.Histogram)
+
+If n <= 0, no strings are generated.
+*/
+func Sample(gen Generator, n int) Stats {
+	stats := Stats{Histogram: make(map[int]int)}
+	total := 0
+	for i := 0; i < n; i++ {
+		length := utf8.RuneCountInString(gen.Generate())
+		if stats.Count == 0 || length < stats.Min {
+			stats.Min = length
+		}
+		if length > stats.Max {
+			stats.Max = length
+		}
+		stats.Histogram[length]++
+		stats.Count++
+		total += length
+	}
+	if stats.Count > 0 {
+		stats.Mean = float64(total) / float64(stats.Count)
+	}
+	return stats
+}
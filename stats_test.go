@@ -0,0 +1,144 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+
+	Convey("Sample", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Only sees lengths the pattern can generate", func() {
+			stats := Sample(newGenerator(`a{2,4}`, nil), SampleSize)
+			So(stats.Count, ShouldEqual, SampleSize)
+			So(stats.Min, ShouldEqual, 2)
+			So(stats.Max, ShouldEqual, 4)
+			So(stats.Histogram, ShouldHaveLength, 3)
+			total := 0
+			for length, count := range stats.Histogram {
+				So(length, ShouldBeBetweenOrEqual, 2, 4)
+				total += count
+			}
+			So(total, ShouldEqual, SampleSize)
+			So(stats.Mean, ShouldBeBetween, 2, 4)
+		})
+
+		Convey("Counts runes", func() {
+			stats := Sample(newGenerator(`é{3}`, nil), 10)
+			So(stats.Min, ShouldEqual, 3)
+			So(stats.Max, ShouldEqual, 3)
+			So(stats.Mean, ShouldEqual, 3)
+			So(stats.Histogram, ShouldResemble, map[int]int{3: 10})
+		})
+
+		Convey("Shows the difference between repeat distributions", func() {
+			uniform := Sample(newGenerator(`a*`, nil), SampleSize)
+			geometric := Sample(newGenerator(`a*`, &GeneratorArgs{RepeatDistribution: GeometricRepeatDistribution}),
+				SampleSize)
+			So(geometric.Mean, ShouldBeLessThan, uniform.Mean)
+		})
+
+		Convey("Handles empty samples", func() {
+			stats := Sample(newGenerator(`a`, nil), 0)
+			So(stats, ShouldResemble, Stats{Histogram: map[int]int{}})
+		})
+	})
+}
+
+func TestGenerateWithStats(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateWithStats", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+		generateWithStats := func(generator Generator) (string, GenStats) {
+			str, stats, err := GenerateWithStats(generator)
+			So(err, ShouldBeNil)
+			return str, stats
+		}
+
+		Convey("Counts RNG draws", func() {
+			str, stats := generateWithStats(newGenerator(`[a-z]{5}`, &GeneratorArgs{RngSource: rand.NewSource(1)}))
+			So(str, ShouldHaveLength, 5)
+			So(stats, ShouldResemble, GenStats{Int31nCalls: 5, Runes: 5, Bytes: 5})
+			So(stats.Draws(), ShouldEqual, 5)
+
+			_, stats = generateWithStats(newGenerator(`(foo|bar|qux)`, &GeneratorArgs{RngSource: rand.NewSource(1)}))
+			So(stats.Draws(), ShouldEqual, 1)
+
+			_, stats = generateWithStats(newGenerator(`abc`, nil))
+			So(stats.Draws(), ShouldEqual, 0)
+		})
+
+		Convey("Returns the same string as Generate", func() {
+			str, stats := generateWithStats(newGenerator(`é[a-z]+`, &GeneratorArgs{RngSource: rand.NewSource(7)}))
+			So(str, ShouldEqual, newGenerator(`é[a-z]+`, &GeneratorArgs{RngSource: rand.NewSource(7)}).Generate())
+			So(stats.Runes, ShouldEqual, len([]rune(str)))
+			So(stats.Bytes, ShouldEqual, len(str))
+			// One draw for the repeat count, and one for each letter.
+			So(stats.Draws(), ShouldEqual, stats.Runes)
+		})
+
+		Convey("Doesn't draw in deterministic mode", func() {
+			_, stats := generateWithStats(newGenerator(`[a-z]+(x|y)`, &GeneratorArgs{Deterministic: true}))
+			So(stats.Draws(), ShouldEqual, 0)
+		})
+
+		Convey("Works with a pool of RNGs", func() {
+			generator := newGenerator(`[a-z]{3}`, &GeneratorArgs{RngPool: true})
+			for i := 0; i < 10; i++ {
+				_, stats := generateWithStats(generator)
+				So(stats.Int31nCalls, ShouldEqual, 3)
+			}
+		})
+
+		Convey("Returns generation errors with the stats so far", func() {
+			generator := newGenerator(`[a-z]{10}`, &GeneratorArgs{MaxTotalLength: 5})
+			str, stats, err := GenerateWithStats(generator)
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+			So(str, ShouldHaveLength, 5)
+			So(stats.Bytes, ShouldEqual, 5)
+			So(stats.Int31nCalls, ShouldBeGreaterThanOrEqualTo, 5)
+
+			multi, err := NewGeneratorFromPatterns([]string{`[a-z]{10}`}, &GeneratorArgs{MaxTotalLength: 5})
+			So(err, ShouldBeNil)
+			_, _, err = GenerateWithStats(multi)
+			So(err, ShouldEqual, ErrMaxTotalLengthExceeded)
+		})
+
+		Convey("Returns an error for other generators", func() {
+			generator := &countingGenerator{Generator: newGenerator(`abc`, nil)}
+			_, _, err := GenerateWithStats(generator)
+			So(err, ShouldEqual, ErrUnsupportedGenerator)
+			So(generator.count, ShouldEqual, 0)
+		})
+	})
+}
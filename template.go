@@ -0,0 +1,81 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"bytes"
+	"strings"
+)
+
+/*
+GenerateTemplate returns tmpl with each "{{name}}" placeholder replaced by a string generated from
+patterns[name], e.g. to compose a log line out of fragments that each match their own pattern. Whitespace around
+name is ignored, and a name can be used more than once, generating a new string each time. If args is nil, default
+values are used.
+
+Each pattern is parsed on its own with args, but all of them share one RNG, and the placeholders are generated in
+the order they appear in tmpl, so the result is reproducible with the same args.RngSource. An error is returned if
+a placeholder isn't closed or has no pattern, or if a pattern can't be parsed.
+*/
+func GenerateTemplate(tmpl string, patterns map[string]string, inputArgs *GeneratorArgs) (string, error) {
+	shared := &GeneratorArgs{}
+	if inputArgs != nil {
+		*shared = *inputArgs
+	}
+	if err := shared.initialize(); err != nil {
+		return "", err
+	}
+
+	generators := make(map[string]*internalGenerator)
+	var result bytes.Buffer
+	for {
+		start := strings.Index(tmpl, "{{")
+		if start < 0 {
+			result.WriteString(tmpl)
+			return result.String(), nil
+		}
+		result.WriteString(tmpl[:start])
+		tmpl = tmpl[start+len("{{"):]
+
+		end := strings.Index(tmpl, "}}")
+		if end < 0 {
+			return "", generatorError(nil, "unclosed placeholder in template: {{%s", tmpl)
+		}
+		name := strings.TrimSpace(tmpl[:end])
+		tmpl = tmpl[end+len("}}"):]
+
+		generator, ok := generators[name]
+		if !ok {
+			pattern, ok := patterns[name]
+			if !ok {
+				return "", generatorError(nil, "no pattern for placeholder {{%s}}", name)
+			}
+			regexp, args, err := parsePatternWithRng(pattern, inputArgs, shared.rng)
+			if err != nil {
+				return "", generatorError(err, "error parsing pattern %q", name)
+			}
+			if generator, err = newGenerator(regexp, args); err != nil {
+				return "", generatorError(err, "error creating generator for pattern %q", name)
+			}
+			generators[name] = generator
+		}
+
+		if err := generator.generate(generator.newState(&result, nil)); err != nil {
+			return "", err
+		}
+	}
+}
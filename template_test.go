@@ -0,0 +1,78 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateTemplate(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateTemplate", t, func() {
+		patterns := map[string]string{
+			"level": `INFO|WARN|ERROR`,
+			"id":    `[0-9a-f]{8}`,
+		}
+
+		Convey("Replaces placeholders with matching strings", func() {
+			matcher := regexp.MustCompile(`^\[(INFO|WARN|ERROR)\] request ([0-9a-f]{8}) done$`)
+			for i := 0; i < SampleSize; i++ {
+				str, err := GenerateTemplate("[{{level}}] request {{ id }} done", patterns, nil)
+				So(err, ShouldBeNil)
+				So(matcher.MatchString(str), ShouldBeTrue)
+			}
+		})
+
+		Convey("Generates each occurrence separately", func() {
+			str, err := GenerateTemplate("{{id}}{{id}}", patterns, &GeneratorArgs{RngSource: rand.NewSource(0)})
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, 16)
+			So(str[:8], ShouldNotEqual, str[8:])
+		})
+
+		Convey("Is reproducible with the same seed", func() {
+			tmpl := "{{level}} {{id}} {{level}}"
+			first, err := GenerateTemplate(tmpl, patterns, &GeneratorArgs{RngSource: rand.NewSource(1)})
+			So(err, ShouldBeNil)
+			second, err := GenerateTemplate(tmpl, patterns, &GeneratorArgs{RngSource: rand.NewSource(1)})
+			So(err, ShouldBeNil)
+			So(second, ShouldEqual, first)
+		})
+
+		Convey("Returns text without placeholders unchanged", func() {
+			str, err := GenerateTemplate("no placeholders", patterns, nil)
+			So(err, ShouldBeNil)
+			So(str, ShouldEqual, "no placeholders")
+		})
+
+		Convey("Returns errors", func() {
+			_, err := GenerateTemplate("{{missing}}", patterns, nil)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateTemplate("{{id", patterns, nil)
+			So(err, ShouldNotBeNil)
+
+			_, err = GenerateTemplate("{{bad}}", map[string]string{"bad": `a{2,1}`}, nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp"
+	"regexp/syntax"
+)
+
+// compileValidator returns a function that returns true if a string matches parsed, for GeneratorArgs.Validate.
+func compileValidator(parsed *syntax.Regexp, hasBackreferences bool) (func(str string) bool, error) {
+	if hasBackreferences {
+		// The standard regexp package doesn't support backreferences either.
+		return nil, generatorError(nil, "Validate doesn't support backreferences: /%s/", parsed)
+	}
+
+	// parsed.String() includes any flags needed to parse it the same way again.
+	validator, err := regexp.Compile(`\A(?:` + parsed.String() + `)\z`)
+	if err != nil {
+		return nil, generatorError(err, "failed to compile /%s/ for validation", parsed)
+	}
+	return validator.MatchString, nil
+}
+
+/*
+CanGenerate returns nil if NewGenerator can create a generator for pattern with args, or an error describing
+the first part of the expression that it can't generate. If args is nil, default values are used.
+
+It only parses the expression and checks each node, without building the generator, so it's cheaper than calling
+NewGenerator, e.g. to validate user input. args.CaptureGroupHandler and args.Overrides aren't called, so errors
+they would return aren't detected, and expressions with overridden ops aren't checked.
+*/
+func CanGenerate(pattern string, args *GeneratorArgs) error {
+	regexp, initializedArgs, err := parsePattern(pattern, args)
+	if err != nil {
+		return err
+	}
+	return checkGeneratable(regexp, initializedArgs, 1, make(map[*syntax.Regexp]int))
+}
+
+// checkGeneratable returns the error newGenerator would return for regexp at depth, if any. checked contains the
+// largest depth each expression has been checked at, since simplified repeats contain the same expression many
+// times. An expression only needs to be checked again if it's deeper, since it could exceed MaxDepth.
+func checkGeneratable(regexp *syntax.Regexp, args *GeneratorArgs, depth int, checked map[*syntax.Regexp]int) error {
+	if checkedDepth, ok := checked[regexp]; ok && checkedDepth >= depth {
+		return nil
+	}
+	checked[regexp] = depth
+	if depth > args.MaxDepth {
+		return maxDepthError(args)
+	}
+
+	simplified := regexp.Simplify()
+	if _, ok := args.Overrides[simplified.Op]; ok && !isBackreferencePlaceholderGroup(simplified, args) {
+		return nil
+	}
+	if _, ok := generatorFactories[simplified.Op]; !ok {
+		return &UnsupportedOpError{simplified.Op, simplified}
+	}
+
+	var err error
+	switch simplified.Op {
+	case syntax.OpCharClass:
+		var charClass *tCharClass
+		charClass, err = restrictCharClass(simplified, applyDigitScript(parseCharClass(simplified.Rune), args), args)
+		if err == nil && len(args.RuneWeights) > 0 {
+			_, err = newWeightedCharClass(simplified, charClass, args.RuneWeights)
+		}
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		if args.ByteMode {
+			_, err = anyByteValues(simplified, simplified.Op == syntax.OpAnyCharNotNL, args)
+		} else if !isRawAnyChar(simplified, args) {
+			_, err = restrictCharClass(simplified, anyCharGenerationClass(simplified, args), args)
+		}
+
+	case syntax.OpAlternate:
+		if args.DedupeAlternates {
+			simplified = dedupeAlternates(simplified)
+		}
+		if args.AvoidEmptyAlternates {
+			simplified = removeEmptyAlternates(simplified)
+		}
+		args.alternateDepth++
+		defer func() { args.alternateDepth-- }()
+		if args.MaxAlternateDepth > 0 && args.alternateDepth > args.MaxAlternateDepth {
+			// Only the shortest alternative is generated, as in opAlternate.
+			return checkGeneratable(shortestAlternative(simplified, args), args, depth+1, checked)
+		}
+		if args.AlternateWeight != nil {
+			_, err = alternateWeights(simplified, args.AlternateWeight)
+		}
+
+	case syntax.OpCapture:
+		if index, ok := backreferenceGroup(simplified); ok && args.hasBackreferences {
+			if index >= args.numCaptureGroups {
+				return generatorError(nil, "invalid backreference to group %d", index+1)
+			}
+			return nil
+		}
+		err = enforceSingleSub(simplified)
+
+	case syntax.OpQuest, syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		err = enforceSingleSub(simplified)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range simplified.Sub {
+		if err := checkGeneratable(sub, args, depth+1, checked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateChecked is like generator.Generate, but if GeneratorArgs.Validate was set, returns an error (and the
+// generated string) if the generated string doesn't match the expression. If Validate wasn't set, or generator
+// wasn't created by this package, it never returns an error.
+func GenerateChecked(generator Generator) (string, error) {
+	if gen, ok := generator.(extendedGenerator); ok {
+		return gen.generateChecked()
+	}
+	return generator.Generate(), nil
+}
+
+func (gen *internalGenerator) generateChecked() (string, error) {
+	str := gen.Generate()
+	return str, gen.check(str)
+}
+
+// check returns an error if args.Validate is set and str doesn't match the expression.
+func (gen *internalGenerator) check(str string) error {
+	if gen.args.validator != nil && !gen.args.validator(str) {
+		return generatorError(nil, "generated string %q doesn't match /%s/", str, gen)
+	}
+	return nil
+}
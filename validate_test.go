@@ -0,0 +1,170 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	Convey("Validate", t, func() {
+		newGenerator := func(pattern string, args *GeneratorArgs) Generator {
+			generator, err := NewGenerator(pattern, args)
+			So(err, ShouldBeNil)
+			return generator
+		}
+
+		Convey("Accepts matching strings", func() {
+			for _, pattern := range []string{`(?i)foo[a-z]+\d*`, `\pL{3}|x+`, `^abc$`, `(?s:.{5})`} {
+				generator := newGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl, Validate: true})
+				for i := 0; i < SampleSize/10; i++ {
+					_, err := GenerateChecked(generator)
+					So(err, ShouldBeNil)
+				}
+			}
+		})
+
+		Convey("Catches strings that don't match", func() {
+			// Anchors and word boundaries are ignored when generating, so these are generated as "ab".
+			for _, pattern := range []string{`a^b`, `a\bb`} {
+				generator := newGenerator(pattern, &GeneratorArgs{Flags: syntax.Perl, Validate: true})
+				str, err := GenerateChecked(generator)
+				So(str, ShouldEqual, "ab")
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Doesn't check without Validate", func() {
+			str, err := GenerateChecked(newGenerator(`a^b`, nil))
+			So(str, ShouldEqual, "ab")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Returns error for backreferences", func() {
+			_, err := NewGenerator(`(a)\1`, &GeneratorArgs{Validate: true})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGenerateE(t *testing.T) {
+	t.Parallel()
+
+	Convey("GenerateE", t, func() {
+		Convey("Returns strings without error", func() {
+			generator, err := NewGenerator(`[a-z]{3}[0-9]`, nil)
+			So(err, ShouldBeNil)
+			str, err := GenerateE(generator)
+			So(err, ShouldBeNil)
+			So(str, ShouldHaveLength, 4)
+		})
+
+		Convey("Returns error when MaxTotalLength is exceeded", func() {
+			generator, err := NewGenerator(strings.Repeat(`a{1000}`, 2), &GeneratorArgs{MaxTotalLength: 1500})
+			So(err, ShouldBeNil)
+			str, err := GenerateE(generator)
+			So(errors.Is(err, ErrMaxTotalLengthExceeded), ShouldBeTrue)
+			So(str, ShouldEqual, strings.Repeat("a", 1500))
+		})
+
+		Convey("Returns error when validation fails", func() {
+			generator, err := NewGenerator(`a^b`, &GeneratorArgs{Flags: syntax.Perl, Validate: true})
+			So(err, ShouldBeNil)
+			str, err := GenerateE(generator)
+			So(str, ShouldEqual, "ab")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Returns errors from multiple patterns", func() {
+			generator, err := NewGeneratorFromPatterns([]string{`a{1000}`, `b{1000}`}, &GeneratorArgs{MaxTotalLength: 10})
+			So(err, ShouldBeNil)
+			_, err = GenerateE(generator)
+			So(errors.Is(err, ErrMaxTotalLengthExceeded), ShouldBeTrue)
+		})
+	})
+}
+
+func TestCanGenerate(t *testing.T) {
+	t.Parallel()
+
+	Convey("CanGenerate", t, func() {
+		// Each case is checked against NewGenerator, so CanGenerate can't drift from what it supports.
+		ConveyAgreesWithNewGenerator := func(pattern string, args *GeneratorArgs, canGenerate bool) {
+			err := CanGenerate(pattern, args)
+			_, newErr := NewGenerator(pattern, args)
+			if canGenerate {
+				So(err, ShouldBeNil)
+				So(newErr, ShouldBeNil)
+			} else {
+				So(err, ShouldNotBeNil)
+				So(newErr, ShouldNotBeNil)
+				// NewGenerator wraps the error with the expressions containing the invalid one.
+				So(newErr.Error(), ShouldEndWith, err.Error())
+			}
+		}
+
+		Convey("Accepts supported patterns", func() {
+			perl := &GeneratorArgs{Flags: syntax.Perl}
+			for _, pattern := range []string{
+				``, `abc`, `[a-z]{3,5}`, `(foo|bar)+\d*`, `^\w+@\w+\.com$`, `\pL{2}`, `(?i)hello`, `(a)(b)\2\1`,
+				`(?P<name>x)?`, `\bword\B`, `.{1000}`,
+			} {
+				ConveyAgreesWithNewGenerator(pattern, perl, true)
+			}
+			ConveyAgreesWithNewGenerator(`.`, &GeneratorArgs{ByteMode: true}, true)
+			ConveyAgreesWithNewGenerator(`.`, &GeneratorArgs{RawAnyChar: true, Flags: syntax.DotNL}, true)
+			ConveyAgreesWithNewGenerator(`.`, nil, true)
+		})
+
+		Convey("Rejects patterns that don't parse", func() {
+			So(CanGenerate(`a(`, nil), ShouldNotBeNil)
+			So(CanGenerate(`\d`, nil), ShouldNotBeNil)
+		})
+
+		Convey("Rejects unsupported patterns", func() {
+			ConveyAgreesWithNewGenerator(`a[éè]`, &GeneratorArgs{ASCIIOnly: true}, false)
+			ConveyAgreesWithNewGenerator(`x|[aeiou]{2}`, &GeneratorArgs{AllowRunes: []rune("xyz")}, false)
+			ConveyAgreesWithNewGenerator(`[ab]`, &GeneratorArgs{ExcludeRunes: []rune("ab")}, false)
+			ConveyAgreesWithNewGenerator(`.`, &GeneratorArgs{ByteMode: true, AllowRunes: []rune("\n")}, false)
+			ConveyAgreesWithNewGenerator(`.`, &GeneratorArgs{PrintableOnly: true, AllowRunes: []rune("\t")}, false)
+			ConveyAgreesWithNewGenerator(`(a)\2`, nil, false)
+			ConveyAgreesWithNewGenerator(`foo|bar|qux`, &GeneratorArgs{
+				AlternateWeight: func(index, total int) int { return 0 },
+			}, false)
+			ConveyAgreesWithNewGenerator(`(a)\1`, &GeneratorArgs{Validate: true}, false)
+			ConveyAgreesWithNewGenerator(`((((a))))`, &GeneratorArgs{MaxDepth: 4}, false)
+			ConveyAgreesWithNewGenerator(`(ab){3}c{0,5}`, &GeneratorArgs{MaxDepth: 8}, false)
+		})
+
+		Convey("Checks repeated expressions once", func() {
+			calls := 0
+			args := &GeneratorArgs{AlternateWeight: func(index, total int) int {
+				calls++
+				return 1
+			}}
+			So(CanGenerate(`(ab|cd){100}`, args), ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+	})
+}
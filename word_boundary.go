@@ -0,0 +1,132 @@
+/*
+Copyright 2014 Zachary Klippenstein
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package regen
+
+import (
+	"regexp/syntax"
+)
+
+/*
+By default, word boundaries (\b and \B) don't generate anything, so e.g. "a\bb" generates "ab", which doesn't match.
+With GeneratorArgs.WordBoundaries set, the state keeps track of the last rune written, and a word boundary sets
+a requirement for the next rune: \b requires a word rune after a non-word rune (or at the start), and a non-word rune
+after a word rune, and \B requires the same kind of rune as the last one. Character classes and "." then only
+generate runes that meet the requirement, if they can. Literals can't be changed, so expressions like "a\bb" still
+generate strings that don't match. Runes are also generated before the boundary after them is seen, so the rune
+before a boundary isn't chosen to suit a literal after it, e.g. "[a-z ]\bfoo" can generate "afoo".
+*/
+
+// runeRequirement is the kind of rune that must be written next to satisfy a preceding word boundary.
+type runeRequirement int
+
+const (
+	anyRune runeRequirement = iota
+	wordRune
+	nonWordRune
+	numRuneRequirements
+)
+
+// asciiWordClass contains the runes that are word characters for \b and \B: [0-9A-Za-z_].
+var asciiWordClass = parseCharClass([]rune{'0', '9', 'A', 'Z', '_', '_', 'a', 'z'})
+
+// isWordRune returns true if r is a word character for \b and \B.
+// This is the same definition used by the syntax package.
+func isWordRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_'
+}
+
+// meets returns true if r meets the requirement.
+func (req runeRequirement) meets(r rune) bool {
+	switch req {
+	case wordRune:
+		return isWordRune(r)
+	case nonWordRune:
+		return !isWordRune(r)
+	}
+	return true
+}
+
+func opWordBoundary(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+	if err := enforceOp(regexp, syntax.OpWordBoundary); err != nil {
+		return nil, err
+	}
+	if !args.WordBoundaries {
+		return noop(regexp, args)
+	}
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		// Nothing written yet counts as a non-word rune.
+		if isWordRune(state.lastRune) {
+			state.nextRune = nonWordRune
+		} else {
+			state.nextRune = wordRune
+		}
+		return nil
+	}}, nil
+}
+
+func opNoWordBoundary(regexp *syntax.Regexp, args *GeneratorArgs) (*internalGenerator, error) {
+	if err := enforceOp(regexp, syntax.OpNoWordBoundary); err != nil {
+		return nil, err
+	}
+	if !args.WordBoundaries {
+		return noop(regexp, args)
+	}
+	return &internalGenerator{regexp.String(), regexp, args, nil, func(state *generatorState) error {
+		if isWordRune(state.lastRune) {
+			state.nextRune = wordRune
+		} else {
+			state.nextRune = nonWordRune
+		}
+		return nil
+	}}, nil
+}
+
+// boundaryCharClasses returns the runes from charClass that meet each runeRequirement, indexed by requirement.
+// If no runes in charClass meet a requirement, or args.WordBoundaries isn't set, charClass is used for it instead.
+func boundaryCharClasses(charClass *tCharClass, args *GeneratorArgs) [numRuneRequirements]*tCharClass {
+	classes := [numRuneRequirements]*tCharClass{charClass, charClass, charClass}
+	if !args.WordBoundaries {
+		return classes
+	}
+	if word := charClass.intersect(asciiWordClass); word.TotalSize > 0 {
+		classes[wordRune] = word
+	}
+	if nonWord := charClass.subtract(asciiWordClass); nonWord.TotalSize > 0 {
+		classes[nonWordRune] = nonWord
+	}
+	return classes
+}
+
+// boundaryByteValues is like boundaryCharClasses, but for the bytes generated by "." in ByteMode.
+func boundaryByteValues(values []byte, args *GeneratorArgs) [numRuneRequirements][]byte {
+	result := [numRuneRequirements][]byte{values, values, values}
+	if !args.WordBoundaries {
+		return result
+	}
+	for _, req := range []runeRequirement{wordRune, nonWordRune} {
+		var matching []byte
+		for _, b := range values {
+			if req.meets(rune(b)) {
+				matching = append(matching, b)
+			}
+		}
+		if len(matching) > 0 {
+			result[req] = matching
+		}
+	}
+	return result
+}